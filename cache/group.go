@@ -0,0 +1,54 @@
+package cache
+
+import (
+	"errors"
+	"sync"
+)
+
+// errPanicked is what callers waiting on a call see when its fn panicked.
+// The panic itself propagates in the goroutine that ran fn.
+var errPanicked = errors.New("cache: load panicked")
+
+type call struct {
+	wg  sync.WaitGroup
+	val interface{}
+	err error
+}
+
+// Group coalesces concurrent calls for the same key so that only one of
+// them does the work and the rest share its result.
+type Group struct {
+	mu    sync.Mutex
+	calls map[string]*call
+}
+
+// Do runs fn once for all concurrent callers with the same key.
+func (g *Group) Do(key string, fn func() (interface{}, error)) (interface{}, error) {
+	g.mu.Lock()
+	if g.calls == nil {
+		g.calls = make(map[string]*call)
+	}
+	if c, ok := g.calls[key]; ok {
+		g.mu.Unlock()
+		c.wg.Wait()
+		return c.val, c.err
+	}
+
+	c := &call{}
+	c.wg.Add(1)
+	g.calls[key] = c
+	g.mu.Unlock()
+
+	// Release waiters and forget the call even if fn panics; they then
+	// see errPanicked and the next caller starts afresh
+	c.err = errPanicked
+	defer func() {
+		g.mu.Lock()
+		delete(g.calls, key)
+		g.mu.Unlock()
+		c.wg.Done()
+	}()
+
+	c.val, c.err = fn()
+	return c.val, c.err
+}
@@ -0,0 +1,77 @@
+package cache
+
+import (
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestGroupCoalesces(t *testing.T) {
+	var g Group
+	var calls atomic.Int32
+	release := make(chan struct{})
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			v, err := g.Do("k", func() (interface{}, error) {
+				calls.Add(1)
+				<-release
+				return "v", nil
+			})
+			if v != "v" || err != nil {
+				t.Errorf("Do = %v, %v", v, err)
+			}
+		}()
+	}
+	time.Sleep(20 * time.Millisecond)
+	close(release)
+	wg.Wait()
+
+	if n := calls.Load(); n != 1 {
+		t.Errorf("fn ran %d times, want 1", n)
+	}
+}
+
+func TestGroupPanicReleasesWaiters(t *testing.T) {
+	var g Group
+	started := make(chan struct{})
+	release := make(chan struct{})
+
+	go func() {
+		defer func() { recover() }()
+		g.Do("k", func() (interface{}, error) {
+			close(started)
+			<-release
+			panic("boom")
+		})
+	}()
+	<-started
+
+	done := make(chan error)
+	go func() {
+		_, err := g.Do("k", func() (interface{}, error) { return nil, nil })
+		done <- err
+	}()
+	time.Sleep(20 * time.Millisecond)
+	close(release)
+
+	select {
+	case err := <-done:
+		if !errors.Is(err, errPanicked) {
+			t.Errorf("waiter got %v, want errPanicked", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("waiter still blocked after fn panicked")
+	}
+
+	// The key is usable again
+	v, err := g.Do("k", func() (interface{}, error) { return "v", nil })
+	if v != "v" || err != nil {
+		t.Errorf("Do after panic = %v, %v", v, err)
+	}
+}
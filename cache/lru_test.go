@@ -0,0 +1,103 @@
+package cache
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestLRUEvictsLeastRecentlyUsed(t *testing.T) {
+	c := NewLRU(30, time.Minute)
+	c.Set("a", 1, 10)
+	c.Set("b", 2, 10)
+	c.Set("c", 3, 10)
+	c.Get("a") // b is now the least recently used
+
+	c.Set("d", 4, 10)
+	if _, ok := c.Get("b"); ok {
+		t.Error("b was not evicted")
+	}
+	for _, key := range []string{"a", "c", "d"} {
+		if _, ok := c.Get(key); !ok {
+			t.Errorf("%s was evicted", key)
+		}
+	}
+
+	st := c.Stats()
+	if st.Evictions != 1 || st.Entries != 3 || st.Bytes != 30 {
+		t.Errorf("stats = %+v, want 1 eviction, 3 entries, 30 bytes", st)
+	}
+}
+
+func TestLRUEvictsUntilValueFits(t *testing.T) {
+	c := NewLRU(30, time.Minute)
+	for i := 0; i < 3; i++ {
+		c.Set(fmt.Sprint(i), i, 10)
+	}
+	c.Set("big", 0, 25)
+
+	st := c.Stats()
+	if st.Entries != 1 || st.Bytes != 25 || st.Evictions != 3 {
+		t.Errorf("stats = %+v, want only big left after 3 evictions", st)
+	}
+}
+
+func TestLRUByteAccounting(t *testing.T) {
+	c := NewLRU(100, time.Minute)
+
+	c.Set("a", 1, 10)
+	c.Set("a", 2, 40) // replacing counts only the new size
+	if v, _ := c.Get("a"); v != 2 {
+		t.Errorf("a = %v, want 2", v)
+	}
+	if b := c.Stats().Bytes; b != 40 {
+		t.Errorf("bytes = %d after replacing a, want 40", b)
+	}
+
+	c.Set("huge", 0, 101)
+	if _, ok := c.Get("huge"); ok {
+		t.Error("value larger than the cache was stored")
+	}
+
+	c.Set("list:1", 0, 20)
+	c.Set("list:2", 0, 20)
+	c.RemoveFunc(func(key string) bool { return strings.HasPrefix(key, "list:") })
+	if b := c.Stats().Bytes; b != 40 {
+		t.Errorf("bytes = %d after RemoveFunc, want 40", b)
+	}
+
+	c.Remove("a")
+	c.Remove("missing")
+	if st := c.Stats(); st.Bytes != 0 || st.Entries != 0 {
+		t.Errorf("stats = %+v after removing everything, want empty", st)
+	}
+
+	c.Set("b", 0, 30)
+	c.Purge()
+	if st := c.Stats(); st.Bytes != 0 || st.Entries != 0 {
+		t.Errorf("stats = %+v after Purge, want empty", st)
+	}
+}
+
+func TestLRUExpires(t *testing.T) {
+	c := NewLRU(100, 20*time.Millisecond)
+	c.Set("a", 1, 10)
+	if _, ok := c.Get("a"); !ok {
+		t.Fatal("a missing before its TTL")
+	}
+
+	time.Sleep(40 * time.Millisecond)
+	if _, ok := c.Get("a"); ok {
+		t.Error("a still cached after its TTL")
+	}
+
+	// Expired entries are dropped when found, freeing their bytes
+	st := c.Stats()
+	if st.Entries != 0 || st.Bytes != 0 {
+		t.Errorf("stats = %+v, want the expired entry gone", st)
+	}
+	if st.Hits != 1 || st.Misses != 1 {
+		t.Errorf("stats = %+v, want 1 hit and 1 miss", st)
+	}
+}
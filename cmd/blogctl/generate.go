@@ -0,0 +1,81 @@
+package main
+
+import (
+	"blog-api/models"
+	"blog-api/synth"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"time"
+)
+
+type generateResult struct {
+	Loaded     int     `json:"loaded"`
+	Seed       uint64  `json:"seed"`
+	DurationMS int64   `json:"duration_ms"`
+	PerSecond  float64 `json:"per_second"`
+}
+
+func runGenerate(ctx context.Context, e *env, args []string) error {
+	fs := e.flags("generate")
+	count := fs.Int("count", 100000, "how many posts to load")
+	batch := fs.Int("batch", 10000, "posts per transaction")
+	var opts synth.Options
+	fs.IntVar(&opts.Authors, "authors", 1000, "distinct authors")
+	fs.Float64Var(&opts.AuthorSkew, "author-skew", 1.1, "Zipf exponent for posts per author; 1 or less is uniform")
+	fs.IntVar(&opts.MinWords, "min-words", 50, "shortest post in words")
+	fs.IntVar(&opts.MaxWords, "max-words", 2000, "longest post in words")
+	fs.DurationVar(&opts.Spread, "spread", 365*24*time.Hour, "posts are created over this span up to now")
+	fs.Uint64Var(&opts.Seed, "seed", 0, "random seed for repeatable data (default random)")
+	if err := parse(fs, args, 0, 0); err != nil {
+		return err
+	}
+	if *count < 1 || *batch < 1 {
+		return fmt.Errorf("-count and -batch must be at least 1")
+	}
+	if opts.MaxWords < opts.MinWords {
+		return fmt.Errorf("-max-words must not be less than -min-words")
+	}
+
+	store, err := e.open(ctx, true)
+	if err != nil {
+		return err
+	}
+
+	gen := synth.New(opts)
+	start := time.Now()
+	loaded := 0
+	posts := make([]models.Post, 0, *batch)
+	for loaded < *count {
+		posts = posts[:0]
+		for len(posts) < *batch && loaded+len(posts) < *count {
+			posts = append(posts, gen.Next())
+		}
+		n, err := store.LoadPosts(ctx, posts)
+		if err != nil {
+			return fmt.Errorf("after %s: %w", plural(loaded, "post"), err)
+		}
+		loaded += n
+
+		if !e.json {
+			fmt.Fprintf(os.Stderr, "\rLoaded %d of %d posts", loaded, *count)
+		}
+	}
+	if !e.json {
+		fmt.Fprintln(os.Stderr)
+	}
+
+	elapsed := time.Since(start)
+	result := generateResult{
+		Loaded:     loaded,
+		Seed:       gen.Options().Seed,
+		DurationMS: elapsed.Milliseconds(),
+		PerSecond:  float64(loaded) / elapsed.Seconds(),
+	}
+	return e.print(result, func(w io.Writer) {
+		fmt.Fprintf(w, "Loaded %s in %s (%.0f/s, seed %d)\n",
+			plural(loaded, "post"), elapsed.Round(time.Millisecond), result.PerSecond, result.Seed)
+		fmt.Fprintln(w, "Run blogctl reindex-search to refresh the search index and planner statistics")
+	})
+}
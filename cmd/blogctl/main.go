@@ -0,0 +1,188 @@
+// Command blogctl administers a blog-api database. It reads the same
+// configuration as the server: config file, environment and flags.
+//
+//	blogctl [config flags] <command> [flags] [args]
+//
+// Commands:
+//
+//	migrate up [-to N]          apply pending migrations
+//	migrate down [-steps N]     revert the newest migrations
+//	migrate status              list migrations and whether they are applied
+//	seed [-count N]             create sample posts
+//	generate [-count N] [-authors N] [-author-skew S] [-min-words N]
+//	         [-max-words N] [-spread D] [-seed N]
+//	                            bulk-load synthetic posts for load testing
+//	                            (needs a superuser or a role that may set
+//	                            session_replication_role)
+//	import [-format F] [-dry-run] [-atomic] FILE
+//	                            import posts from NDJSON or CSV ("-" is stdin)
+//	export [-format F] [-o FILE] [-author A] [-search S]
+//	                            write posts as jsonl, csv or markdown
+//	reindex-search              rebuild the search index
+//	purge-trash [-older-than D] delete trashed posts for good
+//
+// Every command takes -json to print its result as JSON for scripts.
+// The server applies pending migrations itself when it starts, which
+// re-applies anything migrate down reverted. Set DB_AUTO_MIGRATE=false on
+// the server to leave migrations to blogctl.
+package main
+
+import (
+	"blog-api/config"
+	"blog-api/storage"
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"syscall"
+)
+
+type command struct {
+	name  string
+	usage string
+	run   func(ctx context.Context, e *env, args []string) error
+}
+
+var commands = []command{
+	{"migrate", "migrate up|down|status", runMigrate},
+	{"seed", "seed [-count N]", runSeed},
+	{"generate", "generate [-count N] [-batch N] [-authors N] [-author-skew S] [-min-words N] [-max-words N] [-spread D] [-seed N]", runGenerate},
+	{"import", "import [-format ndjson|csv] [-dry-run] [-atomic] FILE", runImport},
+	{"export", "export [-format jsonl|csv|markdown] [-o FILE] [-author A] [-search S]", runExport},
+	{"reindex-search", "reindex-search", runReindexSearch},
+	{"purge-trash", "purge-trash [-older-than DURATION]", runPurgeTrash},
+}
+
+// errUsage makes the command exit with status 2 without further output.
+var errUsage = errors.New("usage")
+
+// env is what commands share: configuration, the database and output.
+type env struct {
+	cfg   *config.Config
+	store *storage.PostgresStore
+	json  bool
+	out   io.Writer
+}
+
+func main() {
+	cfg, args, err := config.LoadArgs(os.Args[1:])
+	if errors.Is(err, flag.ErrHelp) {
+		usage()
+		os.Exit(2)
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "blogctl: %v\n", err)
+		os.Exit(2)
+	}
+	if len(args) == 0 {
+		usage()
+		os.Exit(2)
+	}
+
+	var cmd *command
+	for i := range commands {
+		if commands[i].name == args[0] {
+			cmd = &commands[i]
+		}
+	}
+	if cmd == nil {
+		fmt.Fprintf(os.Stderr, "blogctl: unknown command %q\n", args[0])
+		usage()
+		os.Exit(2)
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	e := &env{cfg: cfg, out: os.Stdout}
+	err = cmd.run(ctx, e, args[1:])
+	if e.store != nil {
+		e.store.Close()
+	}
+	if errors.Is(err, errUsage) {
+		fmt.Fprintf(os.Stderr, "usage: blogctl %s\n", cmd.usage)
+		os.Exit(2)
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "blogctl %s: %v\n", cmd.name, err)
+		os.Exit(1)
+	}
+}
+
+func usage() {
+	fmt.Fprintln(os.Stderr, "usage: blogctl [config flags] <command> [flags] [args]")
+	fmt.Fprintln(os.Stderr, "\ncommands:")
+	for _, c := range commands {
+		fmt.Fprintf(os.Stderr, "  %s\n", c.usage)
+	}
+	fmt.Fprintln(os.Stderr, "\nRun blogctl -h for the config flags, or blogctl <command> -h for a command's flags.")
+}
+
+// flags returns a flag set for a command with the shared -json flag.
+func (e *env) flags(name string) *flag.FlagSet {
+	fs := flag.NewFlagSet("blogctl "+name, flag.ContinueOnError)
+	fs.BoolVar(&e.json, "json", false, "print the result as JSON")
+	return fs
+}
+
+// parse parses a command's flags and checks how many arguments are left.
+func parse(fs *flag.FlagSet, args []string, minArgs, maxArgs int) error {
+	if err := fs.Parse(args); err != nil {
+		return errUsage
+	}
+	if n := fs.NArg(); n < minArgs || n > maxArgs {
+		return errUsage
+	}
+	return nil
+}
+
+// open connects to the primary database. Commands that use the current
+// schema pass checkSchema; migrate does not.
+func (e *env) open(ctx context.Context, checkSchema bool) (*storage.PostgresStore, error) {
+	if e.store == nil {
+		store, err := storage.NewPostgresStore(e.cfg.GetDBConnectionString(), storage.PostgresOptions{
+			MaxOpenConns:   4,
+			ConnectTimeout: e.cfg.DBConnectTimeout,
+			ReadRetries:    e.cfg.DBReadRetries,
+		})
+		if err != nil {
+			return nil, err
+		}
+		e.store = store
+	}
+
+	if checkSchema {
+		version, err := e.store.MigrationVersion(ctx)
+		if err != nil {
+			return nil, err
+		}
+		if version < storage.SchemaVersion {
+			return nil, fmt.Errorf("database schema is at version %d but this build needs %d; run blogctl migrate up",
+				version, storage.SchemaVersion)
+		}
+	}
+	return e.store, nil
+}
+
+// print writes result as JSON with -json, and calls text otherwise.
+func (e *env) print(result interface{}, text func(w io.Writer)) error {
+	if e.json {
+		enc := json.NewEncoder(e.out)
+		enc.SetIndent("", "  ")
+		return enc.Encode(result)
+	}
+	text(e.out)
+	return nil
+}
+
+// plural returns "1 post" or "2 posts".
+func plural(n int, noun string) string {
+	if n == 1 {
+		return fmt.Sprintf("%d %s", n, noun)
+	}
+	return fmt.Sprintf("%d %ss", n, noun)
+}
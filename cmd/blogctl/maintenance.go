@@ -0,0 +1,54 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"time"
+)
+
+func runReindexSearch(ctx context.Context, e *env, args []string) error {
+	fs := e.flags("reindex-search")
+	if err := parse(fs, args, 0, 0); err != nil {
+		return err
+	}
+
+	store, err := e.open(ctx, true)
+	if err != nil {
+		return err
+	}
+	start := time.Now()
+	if err := store.ReindexSearch(ctx); err != nil {
+		return err
+	}
+
+	elapsed := time.Since(start)
+	return e.print(map[string]interface{}{"reindexed": true, "duration_ms": elapsed.Milliseconds()}, func(w io.Writer) {
+		fmt.Fprintf(w, "Rebuilt the search index in %s\n", elapsed.Round(time.Millisecond))
+	})
+}
+
+func runPurgeTrash(ctx context.Context, e *env, args []string) error {
+	fs := e.flags("purge-trash")
+	olderThan := fs.Duration("older-than", e.cfg.TrashRetention, "purge posts trashed longer ago than this; 0 empties the trash")
+	if err := parse(fs, args, 0, 0); err != nil {
+		return err
+	}
+	if *olderThan < 0 {
+		return fmt.Errorf("-older-than must not be negative")
+	}
+
+	store, err := e.open(ctx, true)
+	if err != nil {
+		return err
+	}
+	before := time.Now().Add(-*olderThan)
+	n, err := store.PurgeTrash(ctx, before)
+	if err != nil {
+		return err
+	}
+
+	return e.print(map[string]interface{}{"purged": n, "before": before.UTC()}, func(w io.Writer) {
+		fmt.Fprintf(w, "Purged %s from the trash\n", plural(n, "post"))
+	})
+}
@@ -0,0 +1,190 @@
+package main
+
+import (
+	"blog-api/database/migrations"
+	"blog-api/storage"
+	"context"
+	"fmt"
+	"io"
+	"text/tabwriter"
+	"time"
+)
+
+type migrationInfo struct {
+	Version   int        `json:"version"`
+	Name      string     `json:"name"`
+	Applied   bool       `json:"applied"`
+	AppliedAt *time.Time `json:"applied_at,omitempty"`
+}
+
+type migrateResult struct {
+	From       int             `json:"from"`
+	To         int             `json:"to"`
+	Migrations []migrationInfo `json:"migrations"`
+}
+
+func runMigrate(ctx context.Context, e *env, args []string) error {
+	if len(args) == 0 {
+		return errUsage
+	}
+	switch args[0] {
+	case "up":
+		return runMigrateUp(ctx, e, args[1:])
+	case "down":
+		return runMigrateDown(ctx, e, args[1:])
+	case "status":
+		return runMigrateStatus(ctx, e, args[1:])
+	}
+	return errUsage
+}
+
+func runMigrateUp(ctx context.Context, e *env, args []string) error {
+	fs := e.flags("migrate up")
+	to := fs.Int("to", 0, "stop after this version (default: the newest)")
+	if err := parse(fs, args, 0, 0); err != nil {
+		return err
+	}
+
+	all, err := storage.LoadMigrations(migrations.FS)
+	if err != nil {
+		return err
+	}
+	store, err := e.open(ctx, false)
+	if err != nil {
+		return err
+	}
+	current, err := store.MigrationVersion(ctx)
+	if err != nil {
+		return err
+	}
+
+	result := migrateResult{From: current, To: current, Migrations: []migrationInfo{}}
+	applied, err := store.Migrate(ctx, all, *to)
+	for _, m := range applied {
+		result.Migrations = append(result.Migrations, migrationInfo{Version: m.Version, Name: m.Name, Applied: true})
+	}
+	if err != nil {
+		return err
+	}
+	if result.To, err = store.MigrationVersion(ctx); err != nil {
+		return err
+	}
+
+	return e.print(result, func(w io.Writer) {
+		if len(result.Migrations) == 0 {
+			fmt.Fprintf(w, "Schema is up to date at version %d\n", current)
+			return
+		}
+		for _, m := range result.Migrations {
+			fmt.Fprintf(w, "Applied %03d_%s\n", m.Version, m.Name)
+		}
+		fmt.Fprintf(w, "Schema is at version %d\n", result.To)
+	})
+}
+
+func runMigrateDown(ctx context.Context, e *env, args []string) error {
+	fs := e.flags("migrate down")
+	steps := fs.Int("steps", 1, "how many migrations to revert")
+	if err := parse(fs, args, 0, 0); err != nil {
+		return err
+	}
+	if *steps < 1 {
+		return fmt.Errorf("-steps must be at least 1")
+	}
+
+	all, err := storage.LoadMigrations(migrations.FS)
+	if err != nil {
+		return err
+	}
+	byVersion := make(map[int]storage.Migration, len(all))
+	for _, m := range all {
+		byVersion[m.Version] = m
+	}
+
+	store, err := e.open(ctx, false)
+	if err != nil {
+		return err
+	}
+	applied, err := store.AppliedMigrations(ctx)
+	if err != nil {
+		return err
+	}
+	current := storage.SchemaVersionOf(applied)
+
+	// Newest first
+	result := migrateResult{From: current, Migrations: []migrationInfo{}}
+	for i := len(applied) - 1; i >= 0 && len(result.Migrations) < *steps; i-- {
+		m, ok := byVersion[applied[i].Version]
+		if !ok {
+			return fmt.Errorf("migration %d has no file in this build", applied[i].Version)
+		}
+		if err := store.RevertMigration(ctx, m); err != nil {
+			return err
+		}
+		result.Migrations = append(result.Migrations, migrationInfo{Version: m.Version, Name: m.Name})
+	}
+	if result.To, err = store.MigrationVersion(ctx); err != nil {
+		return err
+	}
+
+	return e.print(result, func(w io.Writer) {
+		for _, m := range result.Migrations {
+			fmt.Fprintf(w, "Reverted %03d_%s\n", m.Version, m.Name)
+		}
+		fmt.Fprintf(w, "Schema is at version %d\n", result.To)
+	})
+}
+
+func runMigrateStatus(ctx context.Context, e *env, args []string) error {
+	fs := e.flags("migrate status")
+	if err := parse(fs, args, 0, 0); err != nil {
+		return err
+	}
+
+	all, err := storage.LoadMigrations(migrations.FS)
+	if err != nil {
+		return err
+	}
+	store, err := e.open(ctx, false)
+	if err != nil {
+		return err
+	}
+	applied, err := store.AppliedMigrations(ctx)
+	if err != nil {
+		return err
+	}
+
+	current := storage.SchemaVersionOf(applied)
+	appliedAt := map[int]time.Time{}
+	for _, m := range applied {
+		appliedAt[m.Version] = m.AppliedAt
+	}
+
+	status := struct {
+		Current    int             `json:"current"`
+		Expected   int             `json:"expected"`
+		Migrations []migrationInfo `json:"migrations"`
+	}{Current: current, Expected: storage.SchemaVersion}
+	for _, m := range all {
+		info := migrationInfo{Version: m.Version, Name: m.Name}
+		if at, ok := appliedAt[m.Version]; ok {
+			info.Applied = true
+			info.AppliedAt = &at
+		}
+		status.Migrations = append(status.Migrations, info)
+	}
+
+	return e.print(status, func(w io.Writer) {
+		tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+		fmt.Fprintln(tw, "VERSION\tNAME\tSTATUS")
+		for _, m := range status.Migrations {
+			state := "pending"
+			if m.AppliedAt != nil {
+				state = "applied " + m.AppliedAt.Local().Format(time.DateTime)
+			}
+			fmt.Fprintf(tw, "%03d\t%s\t%s\n", m.Version, m.Name, state)
+		}
+		tw.Flush()
+		fmt.Fprintf(w, "\nSchema is at version %d; this build expects %d\n", current, storage.SchemaVersion)
+	})
+}
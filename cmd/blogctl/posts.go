@@ -0,0 +1,185 @@
+package main
+
+import (
+	"blog-api/models"
+	"blog-api/portability"
+	"blog-api/synth"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+type seedResult struct {
+	Created int   `json:"created"`
+	IDs     []int `json:"ids"`
+}
+
+func runSeed(ctx context.Context, e *env, args []string) error {
+	fs := e.flags("seed")
+	count := fs.Int("count", 10, "how many posts to create")
+	if err := parse(fs, args, 0, 0); err != nil {
+		return err
+	}
+	if *count < 1 {
+		return fmt.Errorf("-count must be at least 1")
+	}
+
+	store, err := e.open(ctx, true)
+	if err != nil {
+		return err
+	}
+
+	// Unlike generate, seed goes through Create, so posts get ordinary
+	// slugs and timestamps and are announced like any other
+	gen := synth.New(synth.Options{Authors: 10, MaxWords: 400})
+	result := seedResult{IDs: []int{}}
+	for i := 0; i < *count; i++ {
+		sample := gen.Next()
+		post, err := store.Create(ctx, models.Post{Title: sample.Title, Content: sample.Content, Author: sample.Author})
+		if err != nil {
+			return err
+		}
+		result.Created++
+		result.IDs = append(result.IDs, post.ID)
+	}
+
+	return e.print(result, func(w io.Writer) {
+		fmt.Fprintf(w, "Created %s\n", plural(result.Created, "post"))
+	})
+}
+
+func runImport(ctx context.Context, e *env, args []string) error {
+	fs := e.flags("import")
+	format := fs.String("format", "", "ndjson or csv (default: from the file extension)")
+	dryRun := fs.Bool("dry-run", false, "only validate the rows")
+	atomic := fs.Bool("atomic", false, "import nothing unless every row succeeds")
+	if err := parse(fs, args, 1, 1); err != nil {
+		return err
+	}
+
+	path := fs.Arg(0)
+	if *format == "" {
+		switch strings.ToLower(filepath.Ext(path)) {
+		case ".csv":
+			*format = "csv"
+		case ".ndjson", ".jsonl":
+			*format = "ndjson"
+		default:
+			return fmt.Errorf("cannot tell the format of %s; pass -format", path)
+		}
+	}
+
+	var in io.Reader = os.Stdin
+	if path != "-" {
+		f, err := os.Open(path)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		in = f
+	}
+
+	store, err := e.open(ctx, true)
+	if err != nil {
+		return err
+	}
+	report, err := portability.Import(ctx, store, in, portability.ImportOptions{
+		Format: *format,
+		DryRun: *dryRun,
+		Atomic: *atomic,
+	})
+	if report == nil {
+		return err
+	}
+	importErr := err
+
+	err = e.print(report, func(w io.Writer) {
+		for _, res := range report.Results {
+			if res.Status == "failed" {
+				fmt.Fprintf(w, "Line %d: %s\n", res.Line, res.Error)
+			}
+		}
+		switch {
+		case report.DryRun:
+			fmt.Fprintf(w, "%d of %s valid\n", report.Succeeded, plural(report.Total, "row"))
+		case report.Committed:
+			fmt.Fprintf(w, "Imported %d of %s\n", report.Succeeded, plural(report.Total, "row"))
+		default:
+			fmt.Fprintf(w, "Imported nothing from %s\n", plural(report.Total, "row"))
+		}
+	})
+	if err != nil {
+		return err
+	}
+	if importErr != nil {
+		return fmt.Errorf("stopped after %s: %w", plural(report.Total, "row"), importErr)
+	}
+	if report.Failed > 0 {
+		return fmt.Errorf("%s failed", plural(report.Failed, "row"))
+	}
+	return nil
+}
+
+type exportResult struct {
+	Exported int    `json:"exported"`
+	Format   string `json:"format"`
+	File     string `json:"file,omitempty"`
+}
+
+func runExport(ctx context.Context, e *env, args []string) error {
+	fs := e.flags("export")
+	format := fs.String("format", "jsonl", "jsonl, csv or markdown (a tar.gz)")
+	output := fs.String("o", "", "write to this file instead of stdout")
+	query := models.DefaultPostQuery()
+	fs.StringVar(&query.Author, "author", "", "only posts by this author")
+	fs.StringVar(&query.Search, "search", "", "only posts whose title or content contains this")
+	fs.StringVar(&query.SortBy, "sort-by", query.SortBy, "created_at, updated_at or title")
+	fs.StringVar(&query.SortDir, "sort-dir", query.SortDir, "asc or desc")
+	if err := parse(fs, args, 0, 0); err != nil {
+		return err
+	}
+	if err := query.Validate(); err != nil {
+		return err
+	}
+	exportFormat, err := portability.LookupExportFormat(*format)
+	if err != nil {
+		return err
+	}
+
+	store, err := e.open(ctx, true)
+	if err != nil {
+		return err
+	}
+
+	// With the posts on stdout the summary goes to stderr
+	out := os.Stdout
+	e.out = os.Stderr
+	if *output != "" {
+		if out, err = os.Create(*output); err != nil {
+			return err
+		}
+		e.out = os.Stdout
+	}
+
+	n, err := portability.Export(ctx, store, query, exportFormat, out)
+	if out != os.Stdout {
+		if closeErr := out.Close(); err == nil {
+			err = closeErr
+		}
+	}
+	if err != nil {
+		return err
+	}
+
+	result := exportResult{Exported: n, Format: *format, File: *output}
+	return e.print(result, func(w io.Writer) {
+		if *output != "" {
+			fmt.Fprintf(w, "Exported %s to %s\n", plural(n, "post"), *output)
+		} else {
+			fmt.Fprintf(w, "Exported %s\n", plural(n, "post"))
+		}
+	})
+}
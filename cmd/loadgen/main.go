@@ -0,0 +1,277 @@
+// Command loadgen replays a weighted mix of requests against a running
+// server and reports latency percentiles, error rates and rate-limit hits
+// per endpoint.
+//
+//	go run ./cmd/loadgen -url http://localhost:8080 -c 20 -d 1m cmd/loadgen/scenarios/browse.jsonl
+//
+// A scenario is a JSONL file with one request template per line:
+//
+//	{"name": "get post", "path": "/api/v1/posts/{id}", "weight": 5}
+//	{"name": "list", "path": "/api/v1/posts?limit=50", "pages": 20}
+//	{"name": "create", "method": "POST", "path": "/api/v1/posts",
+//	 "headers": {"Idempotency-Key": "{uuid}"},
+//	 "body": {"title": "Load test {n}", "content": "About {word}", "author": "{author}"}}
+//
+// Each worker picks templates at random by weight. Placeholders in paths,
+// headers and string values in bodies are filled in per request: {id} is
+// a post ID up to -max-id, {n} a sequence number, {author} an author as
+// blogctl generate names them (same -authors and -author-skew), {word} a
+// word its posts use and {uuid} a random ID.
+//
+// Pair it with blogctl generate to see how the API behaves with millions of
+// posts. The server rate-limits each client IP, so expect 429s from a
+// single machine unless the limit is raised.
+package main
+
+import (
+	"blog-api/synth"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	mrand "math/rand/v2"
+	"net/http"
+	"net/url"
+	"os"
+	"os/signal"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+func main() {
+	baseURL := flag.String("url", "http://localhost:8080", "server root URL")
+	workers := flag.Int("c", 10, "concurrent workers")
+	duration := flag.Duration("d", 30*time.Second, "how long to run")
+	total := flag.Int("n", 0, "stop after this many requests (0: run for -d)")
+	rate := flag.Float64("rate", 0, "requests per second across all workers (0: as fast as they go)")
+	timeout := flag.Duration("timeout", 10*time.Second, "per-request timeout")
+	maxID := flag.Int("max-id", 1000, "largest post ID {id} expands to")
+	authors := flag.Int("authors", 1000, "authors {author} is drawn from")
+	skew := flag.Float64("author-skew", 1.1, "Zipf exponent for {author}; 1 or less is uniform")
+	seed := flag.Uint64("seed", 0, "random seed (default random)")
+	asJSON := flag.Bool("json", false, "print the report as JSON")
+	flag.Usage = func() {
+		fmt.Fprintln(flag.CommandLine.Output(), "usage: loadgen [flags] SCENARIO.jsonl")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+	if flag.NArg() != 1 || *workers < 1 || *maxID < 1 {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	steps, err := loadScenario(flag.Arg(0))
+	if err != nil {
+		log.Fatal(err)
+	}
+	base, err := url.Parse(*baseURL)
+	if err != nil {
+		log.Fatalf("Invalid -url: %v", err)
+	}
+	if *seed == 0 {
+		*seed = mrand.Uint64()
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+	ctx, cancel := context.WithTimeout(ctx, *duration)
+	defer cancel()
+
+	d := &driver{
+		client: &http.Client{
+			Timeout:   *timeout,
+			Transport: &http.Transport{MaxIdleConnsPerHost: *workers},
+		},
+		base:    base,
+		steps:   steps,
+		maxID:   *maxID,
+		limit:   int64(*total),
+		results: newRecorder(),
+	}
+	for _, s := range steps {
+		d.weights += s.Weight
+	}
+
+	if *rate > 0 {
+		d.tokens = make(chan struct{})
+		go d.pace(ctx, *rate)
+	}
+
+	log.Printf("Running %s with %d workers against %s", flag.Arg(0), *workers, base)
+	start := time.Now()
+	var wg sync.WaitGroup
+	for i := 0; i < *workers; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			d.work(ctx, synth.New(synth.Options{
+				Authors:    *authors,
+				AuthorSkew: *skew,
+				Seed:       *seed + uint64(i),
+			}), mrand.New(mrand.NewPCG(*seed, uint64(i))))
+		}(i)
+	}
+	wg.Wait()
+
+	rep := d.results.report(time.Since(start))
+	if *asJSON {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		enc.Encode(rep)
+		return
+	}
+	rep.print(os.Stdout)
+}
+
+type driver struct {
+	client  *http.Client
+	base    *url.URL
+	steps   []step
+	weights int
+	maxID   int
+
+	limit   int64         // requests to send, 0 for no limit
+	sent    atomic.Int64  // requests started
+	seq     atomic.Int64  // values of {n}
+	tokens  chan struct{} // paces requests when -rate is set
+	results *recorder
+}
+
+// pace hands out one token per request at the given rate.
+func (d *driver) pace(ctx context.Context, perSecond float64) {
+	ticker := time.NewTicker(time.Duration(float64(time.Second) / perSecond))
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			select {
+			case d.tokens <- struct{}{}:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}
+}
+
+func (d *driver) work(ctx context.Context, gen *synth.Generator, rng *mrand.Rand) {
+	vars := func(name string) string {
+		switch name {
+		case "id":
+			return strconv.Itoa(1 + rng.IntN(d.maxID))
+		case "n":
+			return strconv.FormatInt(d.seq.Add(1), 10)
+		case "author":
+			return gen.Author()
+		case "word":
+			return gen.Word()
+		case "uuid":
+			b := make([]byte, 16)
+			rand.Read(b)
+			return hex.EncodeToString(b)
+		}
+		return ""
+	}
+
+	for ctx.Err() == nil {
+		s := d.pick(rng)
+		req, err := s.newRequest(d.base, vars)
+		if err != nil {
+			log.Printf("%s: %v", s.Name, err)
+			return
+		}
+
+		// Pagination follows the cursor in each response
+		name := s.Name
+		for page := 0; page <= s.Pages; page++ {
+			if !d.acquire(ctx) {
+				return
+			}
+			cursor := d.send(ctx, name, req, s.Pages > 0)
+			if cursor == "" || page == s.Pages {
+				break
+			}
+
+			next := *req.URL
+			q := next.Query()
+			q.Set("cursor", cursor)
+			next.RawQuery = q.Encode()
+			req = req.Clone(ctx)
+			req.URL = &next
+			name = s.Name + " (next pages)"
+		}
+	}
+}
+
+// pick chooses a step at random by weight.
+func (d *driver) pick(rng *mrand.Rand) *step {
+	n := rng.IntN(d.weights)
+	for i := range d.steps {
+		if n < d.steps[i].Weight {
+			return &d.steps[i]
+		}
+		n -= d.steps[i].Weight
+	}
+	return &d.steps[len(d.steps)-1]
+}
+
+// acquire reports whether another request may be sent, waiting for the
+// pacer if there is one.
+func (d *driver) acquire(ctx context.Context) bool {
+	if d.limit > 0 && d.sent.Add(1) > d.limit {
+		return false
+	}
+	if d.tokens == nil {
+		return ctx.Err() == nil
+	}
+	select {
+	case <-d.tokens:
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
+
+// send makes one request and records it. With wantCursor it returns the
+// next_cursor of a successful JSON response, if it has more pages.
+func (d *driver) send(ctx context.Context, name string, req *http.Request, wantCursor bool) string {
+	start := time.Now()
+	resp, err := d.client.Do(req.WithContext(ctx))
+	if err != nil {
+		// Requests cut off by the end of the run are not failures
+		if ctx.Err() == nil {
+			d.results.record(name, time.Since(start), 0)
+		}
+		return ""
+	}
+	defer resp.Body.Close()
+
+	var page struct {
+		NextCursor string `json:"next_cursor"`
+		HasMore    bool   `json:"has_more"`
+	}
+	status := resp.StatusCode
+	if wantCursor && status == http.StatusOK {
+		// A page that cannot be read is a failure and ends the walk
+		if err := json.NewDecoder(resp.Body).Decode(&page); err != nil {
+			if ctx.Err() == nil {
+				d.results.record(name, time.Since(start), 0)
+			}
+			return ""
+		}
+	}
+	io.Copy(io.Discard, resp.Body)
+	d.results.record(name, time.Since(start), status)
+
+	if !page.HasMore {
+		return ""
+	}
+	return page.NextCursor
+}
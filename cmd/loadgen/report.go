@@ -0,0 +1,158 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strconv"
+	"sync"
+	"text/tabwriter"
+	"time"
+)
+
+// recorder collects the outcome of every request, per endpoint.
+type recorder struct {
+	mu        sync.Mutex
+	endpoints map[string]*samples
+}
+
+type samples struct {
+	latencies []time.Duration
+	statuses  map[string]int
+	errors    int
+	limited   int
+}
+
+func newRecorder() *recorder {
+	return &recorder{endpoints: make(map[string]*samples)}
+}
+
+// record notes one request. status is 0 if no response arrived or its
+// body could not be read.
+func (r *recorder) record(name string, latency time.Duration, status int) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	s := r.endpoints[name]
+	if s == nil {
+		s = &samples{statuses: make(map[string]int)}
+		r.endpoints[name] = s
+	}
+	s.latencies = append(s.latencies, latency)
+
+	key := strconv.Itoa(status)
+	switch {
+	case status == 0:
+		key = "failed"
+		s.errors++
+	case status == http.StatusTooManyRequests:
+		s.limited++
+	case status >= 400:
+		s.errors++
+	}
+	s.statuses[key]++
+}
+
+// endpointReport summarizes one endpoint. Latencies are in milliseconds.
+// Rate-limited (429) responses are counted apart from errors.
+type endpointReport struct {
+	Name        string         `json:"name"`
+	Requests    int            `json:"requests"`
+	PerSecond   float64        `json:"per_second"`
+	Errors      int            `json:"errors"`
+	ErrorRate   float64        `json:"error_rate"`
+	RateLimited int            `json:"rate_limited"`
+	Statuses    map[string]int `json:"statuses"`
+	P50         float64        `json:"p50_ms"`
+	P90         float64        `json:"p90_ms"`
+	P95         float64        `json:"p95_ms"`
+	P99         float64        `json:"p99_ms"`
+	Max         float64        `json:"max_ms"`
+}
+
+type report struct {
+	Duration  float64          `json:"duration_s"`
+	Total     endpointReport   `json:"total"`
+	Endpoints []endpointReport `json:"endpoints"`
+}
+
+func (r *recorder) report(elapsed time.Duration) report {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	all := &samples{statuses: make(map[string]int)}
+	rep := report{Duration: elapsed.Seconds()}
+	for name, s := range r.endpoints {
+		rep.Endpoints = append(rep.Endpoints, summarize(name, s, elapsed))
+
+		all.latencies = append(all.latencies, s.latencies...)
+		all.errors += s.errors
+		all.limited += s.limited
+		for k, n := range s.statuses {
+			all.statuses[k] += n
+		}
+	}
+	sort.Slice(rep.Endpoints, func(i, j int) bool { return rep.Endpoints[i].Name < rep.Endpoints[j].Name })
+	rep.Total = summarize("total", all, elapsed)
+	return rep
+}
+
+func summarize(name string, s *samples, elapsed time.Duration) endpointReport {
+	sort.Slice(s.latencies, func(i, j int) bool { return s.latencies[i] < s.latencies[j] })
+
+	n := len(s.latencies)
+	rep := endpointReport{
+		Name:        name,
+		Requests:    n,
+		PerSecond:   float64(n) / elapsed.Seconds(),
+		Errors:      s.errors,
+		RateLimited: s.limited,
+		Statuses:    s.statuses,
+		P50:         percentile(s.latencies, 50),
+		P90:         percentile(s.latencies, 90),
+		P95:         percentile(s.latencies, 95),
+		P99:         percentile(s.latencies, 99),
+		Max:         percentile(s.latencies, 100),
+	}
+	if n > 0 {
+		rep.ErrorRate = float64(s.errors) / float64(n)
+	}
+	return rep
+}
+
+// percentile returns the nearest-rank p-th percentile of sorted latencies
+// in milliseconds.
+func percentile(sorted []time.Duration, p float64) float64 {
+	if len(sorted) == 0 {
+		return 0
+	}
+	rank := int(p/100*float64(len(sorted))+0.5) - 1
+	rank = max(0, min(rank, len(sorted)-1))
+	return float64(sorted[rank].Microseconds()) / 1000
+}
+
+func (rep report) print(w io.Writer) {
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', tabwriter.AlignRight)
+	fmt.Fprintln(tw, "ENDPOINT\tREQS\tREQ/S\tP50\tP90\tP95\tP99\tMAX\tERRORS\t429\t")
+	row := func(e endpointReport) {
+		fmt.Fprintf(tw, "%s\t%d\t%.1f\t%.1fms\t%.1fms\t%.1fms\t%.1fms\t%.1fms\t%.2f%%\t%d\t\n",
+			e.Name, e.Requests, e.PerSecond, e.P50, e.P90, e.P95, e.P99, e.Max, e.ErrorRate*100, e.RateLimited)
+	}
+	for _, e := range rep.Endpoints {
+		row(e)
+	}
+	row(rep.Total)
+	tw.Flush()
+
+	codes := make([]string, 0, len(rep.Total.Statuses))
+	for code := range rep.Total.Statuses {
+		codes = append(codes, code)
+	}
+	sort.Strings(codes)
+	fmt.Fprintf(w, "\n%d requests in %.1fs; responses:", rep.Total.Requests, rep.Duration)
+	for _, code := range codes {
+		fmt.Fprintf(w, " %s×%d", code, rep.Total.Statuses[code])
+	}
+	fmt.Fprintln(w)
+}
@@ -0,0 +1,123 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"regexp"
+	"strings"
+)
+
+// step is one line of a scenario file: a request template and how often to
+// send it relative to the others.
+type step struct {
+	Name    string            `json:"name"`
+	Method  string            `json:"method"`
+	Path    string            `json:"path"`
+	Headers map[string]string `json:"headers"`
+	Body    json.RawMessage   `json:"body"`
+	Weight  int               `json:"weight"`
+	// Pages makes a GET follow next_cursor up to this many more times,
+	// recorded separately as "<name> (next pages)".
+	Pages int `json:"pages"`
+}
+
+// loadScenario reads a JSONL scenario. Blank lines and lines starting with
+// # are skipped.
+func loadScenario(path string) ([]step, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var steps []step
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 64*1024), 1<<20)
+	line := 0
+	for scanner.Scan() {
+		line++
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+
+		var s step
+		dec := json.NewDecoder(strings.NewReader(text))
+		dec.DisallowUnknownFields()
+		if err := dec.Decode(&s); err != nil {
+			return nil, fmt.Errorf("%s:%d: %v", path, line, err)
+		}
+		if !strings.HasPrefix(s.Path, "/") {
+			return nil, fmt.Errorf("%s:%d: path must start with /", path, line)
+		}
+		if s.Method == "" {
+			s.Method = http.MethodGet
+		}
+		s.Method = strings.ToUpper(s.Method)
+		if s.Weight == 0 {
+			s.Weight = 1
+		}
+		if s.Weight < 0 || s.Pages < 0 {
+			return nil, fmt.Errorf("%s:%d: weight and pages must not be negative", path, line)
+		}
+		if s.Name == "" {
+			s.Name = s.Method + " " + s.Path
+		}
+		steps = append(steps, s)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	if len(steps) == 0 {
+		return nil, fmt.Errorf("%s: no requests", path)
+	}
+	return steps, nil
+}
+
+// placeholder matches the variables a step may use: {id}, {n}, {author},
+// {word} and {uuid}.
+var placeholder = regexp.MustCompile(`\{(id|n|author|word|uuid)\}`)
+
+// expand replaces placeholders in s with values from vars, passed through
+// escape.
+func expand(s string, vars func(name string) string, escape func(string) string) string {
+	return placeholder.ReplaceAllStringFunc(s, func(m string) string {
+		return escape(vars(m[1 : len(m)-1]))
+	})
+}
+
+// jsonEscape makes v safe to put inside a JSON string.
+func jsonEscape(v string) string {
+	b, _ := json.Marshal(v)
+	return string(b[1 : len(b)-1])
+}
+
+// newRequest builds the request for s with its placeholders filled in.
+func (s *step) newRequest(base *url.URL, vars func(name string) string) (*http.Request, error) {
+	target, err := base.Parse(expand(s.Path, vars, url.QueryEscape))
+	if err != nil {
+		return nil, err
+	}
+
+	var body io.Reader
+	if len(s.Body) > 0 {
+		body = strings.NewReader(expand(string(s.Body), vars, jsonEscape))
+	}
+	req, err := http.NewRequest(s.Method, target.String(), body)
+	if err != nil {
+		return nil, err
+	}
+
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	for k, v := range s.Headers {
+		req.Header.Set(k, expand(v, vars, func(v string) string { return v }))
+	}
+	return req, nil
+}
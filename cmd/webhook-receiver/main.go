@@ -0,0 +1,56 @@
+// Command webhook-receiver is a local endpoint for trying out webhook
+// subscriptions. It verifies each delivery's signature and prints it.
+//
+//	go run ./cmd/webhook-receiver -addr :9000 -secret whsec_...
+//
+// then subscribe http://localhost:9000/ and POST /api/v1/webhooks/{id}:ping.
+package main
+
+import (
+	"blog-api/webhooks"
+	"bytes"
+	"encoding/json"
+	"flag"
+	"io"
+	"log"
+	"net/http"
+	"time"
+)
+
+func main() {
+	addr := flag.String("addr", ":9000", "listen address")
+	secret := flag.String("secret", "", "subscription secret; empty skips signature checks")
+	status := flag.Int("status", http.StatusOK, "status code to answer with, e.g. 500 to exercise retries")
+	flag.Parse()
+
+	http.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		body, err := io.ReadAll(r.Body)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		if *secret != "" {
+			if err := webhooks.Verify(*secret, r.Header, body, 5*time.Minute); err != nil {
+				log.Printf("Rejected delivery %s: %v", r.Header.Get(webhooks.HeaderDelivery), err)
+				http.Error(w, err.Error(), http.StatusUnauthorized)
+				return
+			}
+		}
+
+		var pretty bytes.Buffer
+		if json.Indent(&pretty, body, "", "  ") != nil {
+			pretty.Write(body)
+		}
+		log.Printf("%s (event %s, delivery %s)\n%s",
+			r.Header.Get(webhooks.HeaderEvent),
+			r.Header.Get(webhooks.HeaderEventID),
+			r.Header.Get(webhooks.HeaderDelivery),
+			pretty.String())
+
+		w.WriteHeader(*status)
+	})
+
+	log.Printf("Listening for webhooks on %s", *addr)
+	log.Fatal(http.ListenAndServe(*addr, nil))
+}
@@ -1,52 +1,538 @@
 package config
 
 import (
+	"errors"
+	"flag"
 	"fmt"
+	"net"
+	"net/url"
 	"os"
 	"strconv"
+	"strings"
+	"time"
 )
 
+// Deployment profiles
+const (
+	EnvDevelopment = "development"
+	EnvProduction  = "production"
+)
+
+// defaultConfigFile is read when no --config flag or CONFIG_FILE is given.
+// It is optional: a missing default file is not an error. It holds
+// development settings, so it is not read when the environment or flags
+// select production.
+const defaultConfigFile = "file.env"
+
 type Config struct {
-	DBHost string
-	DBPort int
-	DBUser string
-	DBPassword string
-	DBName string
+	Env string
+
+	// Database. DatabaseURL, when set, takes precedence over the DB_* fields.
+	DatabaseURL   string
+	DBHost        string
+	DBPort        int
+	DBUser        string
+	DBPassword    string
+	DBName        string
+	DBSSLMode     string // disable, require, verify-ca, verify-full
+	DBSSLRootCert string
+
+	// Connection pool
+	DBMaxOpenConns    int
+	DBMaxIdleConns    int
+	DBConnMaxLifetime time.Duration
+	DBConnMaxIdleTime time.Duration
+	DBConnectTimeout  time.Duration
+	DBReadRetries     int
+
+	// Apply pending migrations at startup; off when blogctl migrate manages
+	// the schema
+	DBAutoMigrate bool
+
+	// Read replicas
+	DBReplicaURLs          []string
+	DBReplicaCheckInterval time.Duration
+	DBReadYourWritesWindow time.Duration
+
 	ServerPort string
+
+	// Listen address for runtime counters (/debug/vars), kept off the
+	// public port. Empty disables it.
+	AdminAddr string
+
+	// Public site details used in feeds and other absolute links. An empty
+	// PublicBaseURL means links are built from the request's host.
+	// PublicPostPath is where readers find a post under PublicBaseURL.
+	PublicBaseURL   string
+	PublicPostPath  string
+	SiteTitle       string
+	SiteDescription string
+	RobotsDisallow  []string
+
+	// Post cache
+	CacheEnabled  bool
+	CacheMaxBytes int64
+	CacheTTL      time.Duration
+
+	// Outgoing webhooks. WebhooksEnabled false stops every delivery,
+	// whatever OutboxSinks lists.
+	WebhooksEnabled            bool
+	WebhookTimeout             time.Duration
+	WebhookMaxAttempts         int
+	WebhookPollInterval        time.Duration
+	WebhookAllowPrivateTargets bool
+
+	// Bearer token required to manage webhooks. Empty keeps those routes
+	// closed.
+	AdminToken string
+
+	// Event outbox
+	OutboxSinks        []string // webhooks, log, file
+	OutboxFile         string
+	OutboxPollInterval time.Duration
+	OutboxRetention    time.Duration
+
+	// Post event stream (SSE)
+	StreamReplayBuffer int
+	StreamHeartbeat    time.Duration
+
+	// Change feed. A write transaction running longer than
+	// ChangeFeedLagAlert, which holds the feed back, is logged.
+	ChangeFeedRetention time.Duration
+	ChangeFeedLagAlert  time.Duration
+
+	// Trash
+	TrashRetention time.Duration
+
+	// GraphQL
+	GraphQLMaxDepth      int
+	GraphQLMaxComplexity int
+
+	// Idempotency keys
+	IdempotencyKeyTTL time.Duration
+
+	// OpenAPI
+	ValidateRequests bool
+
+	// Health probes
+	HealthCheckTimeout time.Duration
+	ShutdownDrainDelay time.Duration
+
+	// Tracing
+	ServiceName        string
+	TracingExporter    string // none, stdout, file, otlp
+	TracingFile        string
+	TracingEndpoint    string
+	TracingInsecure    bool
+	TracingSampleRatio float64
 }
 
+// Load builds the configuration from the process arguments and environment.
 func Load() (*Config, error) {
-	cfg := &Config{
-		DBHost: getEnv("DB_HOST", "localhost"),
-		DBPort: getEnvAsInt("DB_PORT", 5432),
-		DBUser: getEnv("DB_USER", "postgres"),
-		DBPassword: getEnv("DB_PASSWORD", "password"),
-		DBName: getEnv("DB_NAME", "blogdb"),
-		ServerPort: getEnv("SERVER_PORT", "8080"),
+	cfg, _, err := LoadArgs(os.Args[1:])
+	return cfg, err
+}
+
+// LoadArgs builds the configuration in layers, each overriding the last:
+// built-in defaults, a YAML or .env config file, environment variables and
+// finally command-line flags. It returns the arguments left after flag
+// parsing.
+func LoadArgs(args []string) (*Config, []string, error) {
+	values := make(map[string]string, len(settings))
+	for _, s := range settings {
+		values[s.key] = s.def
+	}
+
+	fs := flag.NewFlagSet("blog-api", flag.ContinueOnError)
+	configPath := fs.String("config", "", "path to a YAML or .env config file (env CONFIG_FILE)")
+	flagValues := make(map[string]*string, len(settings))
+	for _, s := range settings {
+		flagValues[s.flagName()] = fs.String(s.flagName(), "", s.usage+" (env "+s.key+")")
+	}
+	if err := fs.Parse(args); err != nil {
+		return nil, nil, err
 	}
 
-	return cfg, nil
+	// Environment and flags, applied over the file below
+	overrides := make(map[string]string)
+	for _, s := range settings {
+		if value, exists := os.LookupEnv(s.key); exists {
+			overrides[s.key] = value
+		}
+	}
+	fs.Visit(func(f *flag.Flag) {
+		if s := lookupFlag(f.Name); s != nil {
+			overrides[s.key] = *flagValues[f.Name]
+		}
+	})
+
+	// Config file
+	path, required := *configPath, true
+	if path == "" {
+		path = os.Getenv("CONFIG_FILE")
+	}
+	if path == "" {
+		path, required = defaultConfigFile, false
+	}
+	if required || strings.TrimSpace(overrides["APP_ENV"]) != EnvProduction {
+		fileValues, err := readConfigFile(path)
+		if err != nil && (required || !errors.Is(err, os.ErrNotExist)) {
+			return nil, nil, err
+		}
+		for key, value := range fileValues {
+			values[key] = value
+		}
+	}
+
+	for key, value := range overrides {
+		values[key] = value
+	}
+
+	cfg := &Config{}
+	var errs []error
+	for _, s := range settings {
+		if err := s.set(cfg, strings.TrimSpace(values[s.key])); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", s.key, err))
+		}
+	}
+	if len(errs) == 0 {
+		errs = cfg.validate()
+	}
+	if len(errs) > 0 {
+		return nil, nil, fmt.Errorf("invalid configuration:\n%w", errors.Join(errs...))
+	}
+
+	return cfg, fs.Args(), nil
+}
+
+// IsProduction reports whether the production profile is active.
+func (c *Config) IsProduction() bool {
+	return c.Env == EnvProduction
+}
+
+func (c *Config) validate() []error {
+	var errs []error
+	fail := func(key, format string, args ...interface{}) {
+		errs = append(errs, fmt.Errorf("%s: %s", key, fmt.Sprintf(format, args...)))
+	}
+
+	if c.Env != EnvDevelopment && c.Env != EnvProduction {
+		fail("APP_ENV", "must be %q or %q, got %q", EnvDevelopment, EnvProduction, c.Env)
+	}
+
+	if c.DatabaseURL != "" {
+		u, err := url.Parse(c.DatabaseURL)
+		if err != nil || (u.Scheme != "postgres" && u.Scheme != "postgresql") || u.Host == "" {
+			fail("DATABASE_URL", "must be a postgres:// URL")
+		}
+	} else {
+		if c.DBHost == "" {
+			fail("DB_HOST", "is required")
+		}
+		if c.DBPort < 1 || c.DBPort > 65535 {
+			fail("DB_PORT", "must be between 1 and 65535, got %d", c.DBPort)
+		}
+		if c.DBUser == "" {
+			fail("DB_USER", "is required")
+		}
+		if c.DBName == "" {
+			fail("DB_NAME", "is required")
+		}
+	}
+
+	switch c.DBSSLMode {
+	case "disable", "require", "verify-ca", "verify-full":
+	default:
+		fail("DB_SSLMODE", "must be one of disable, require, verify-ca, verify-full, got %q", c.DBSSLMode)
+	}
+	if c.DBSSLRootCert != "" {
+		if _, err := os.Stat(c.DBSSLRootCert); err != nil {
+			fail("DB_SSLROOTCERT", "%v", err)
+		}
+	}
+
+	if c.DBMaxOpenConns < 0 {
+		fail("DB_MAX_OPEN_CONNS", "must not be negative")
+	}
+	if c.DBMaxIdleConns < 0 {
+		fail("DB_MAX_IDLE_CONNS", "must not be negative")
+	}
+	if c.DBMaxOpenConns > 0 && c.DBMaxIdleConns > c.DBMaxOpenConns {
+		fail("DB_MAX_IDLE_CONNS", "must not exceed DB_MAX_OPEN_CONNS (%d)", c.DBMaxOpenConns)
+	}
+	if c.DBConnMaxLifetime < 0 {
+		fail("DB_CONN_MAX_LIFETIME", "must not be negative")
+	}
+	if c.DBConnMaxIdleTime < 0 {
+		fail("DB_CONN_MAX_IDLE_TIME", "must not be negative")
+	}
+	if c.DBConnectTimeout <= 0 {
+		fail("DB_CONNECT_TIMEOUT", "must be positive")
+	}
+	if c.DBReadRetries < 0 {
+		fail("DB_READ_RETRIES", "must not be negative")
+	}
+
+	for _, dsn := range c.DBReplicaURLs {
+		u, err := url.Parse(dsn)
+		if err != nil || (u.Scheme != "postgres" && u.Scheme != "postgresql") || u.Host == "" {
+			fail("DB_REPLICA_URLS", "each entry must be a postgres:// URL")
+			break
+		}
+	}
+	if c.DBReplicaCheckInterval <= 0 {
+		fail("DB_REPLICA_CHECK_INTERVAL", "must be positive")
+	}
+	if c.DBReadYourWritesWindow < 0 {
+		fail("DB_READ_YOUR_WRITES_WINDOW", "must not be negative")
+	}
+
+	if port, err := strconv.Atoi(c.ServerPort); err != nil || port < 1 || port > 65535 {
+		fail("SERVER_PORT", "must be a port number between 1 and 65535, got %q", c.ServerPort)
+	}
+
+	if c.AdminAddr != "" {
+		if _, port, err := net.SplitHostPort(c.AdminAddr); err != nil || port == "" {
+			fail("ADMIN_ADDR", "must be host:port, got %q", c.AdminAddr)
+		}
+	}
+
+	if c.PublicBaseURL != "" {
+		u, err := url.Parse(c.PublicBaseURL)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			fail("PUBLIC_BASE_URL", "must be an absolute http:// or https:// URL")
+		} else if u.RawQuery != "" || u.Fragment != "" {
+			fail("PUBLIC_BASE_URL", "must not have a query or fragment")
+		}
+	}
+
+	if !strings.HasPrefix(c.PublicPostPath, "/") ||
+		(!strings.Contains(c.PublicPostPath, "{slug}") && !strings.Contains(c.PublicPostPath, "{id}")) {
+		fail("PUBLIC_POST_PATH", "must be a path starting with / that contains {slug} or {id}, got %q", c.PublicPostPath)
+	}
+
+	for _, path := range c.RobotsDisallow {
+		if !strings.HasPrefix(path, "/") {
+			fail("ROBOTS_DISALLOW", "each entry must be a path starting with /, got %q", path)
+			break
+		}
+	}
+
+	if c.CacheEnabled {
+		if c.CacheMaxBytes <= 0 {
+			fail("CACHE_MAX_BYTES", "must be positive")
+		}
+		if c.CacheTTL <= 0 {
+			fail("CACHE_TTL", "must be positive")
+		}
+	}
+
+	if c.AdminToken != "" && len(c.AdminToken) < 16 {
+		fail("ADMIN_TOKEN", "must be at least 16 characters")
+	}
+
+	if c.WebhookTimeout <= 0 {
+		fail("WEBHOOK_TIMEOUT", "must be positive")
+	}
+	if c.WebhookMaxAttempts < 1 {
+		fail("WEBHOOK_MAX_ATTEMPTS", "must be at least 1")
+	}
+	if c.WebhookPollInterval <= 0 {
+		fail("WEBHOOK_POLL_INTERVAL", "must be positive")
+	}
+
+	for _, sink := range c.OutboxSinks {
+		switch sink {
+		case "webhooks", "log":
+		case "file":
+			if c.OutboxFile == "" {
+				fail("OUTBOX_FILE", "is required when OUTBOX_SINKS includes file")
+			}
+		default:
+			fail("OUTBOX_SINKS", "entries must be webhooks, log or file, got %q", sink)
+		}
+	}
+	if c.OutboxPollInterval <= 0 {
+		fail("OUTBOX_POLL_INTERVAL", "must be positive")
+	}
+	if c.OutboxRetention < 0 {
+		fail("OUTBOX_RETENTION", "must not be negative")
+	}
+
+	if c.StreamReplayBuffer < 0 {
+		fail("STREAM_REPLAY_BUFFER", "must not be negative")
+	}
+	if c.StreamHeartbeat <= 0 {
+		fail("STREAM_HEARTBEAT", "must be positive")
+	}
+
+	if c.ChangeFeedRetention <= 0 {
+		fail("CHANGE_FEED_RETENTION", "must be positive")
+	}
+	if c.ChangeFeedLagAlert < 0 {
+		fail("CHANGE_FEED_LAG_ALERT", "must not be negative")
+	}
+
+	if c.TrashRetention <= 0 {
+		fail("TRASH_RETENTION", "must be positive")
+	}
+
+	if c.GraphQLMaxDepth < 1 {
+		fail("GRAPHQL_MAX_DEPTH", "must be at least 1")
+	}
+	if c.GraphQLMaxComplexity < 1 {
+		fail("GRAPHQL_MAX_COMPLEXITY", "must be at least 1")
+	}
+
+	if c.IdempotencyKeyTTL <= 0 {
+		fail("IDEMPOTENCY_KEY_TTL", "must be positive")
+	}
+
+	if c.HealthCheckTimeout <= 0 {
+		fail("HEALTH_CHECK_TIMEOUT", "must be positive")
+	}
+	if c.ShutdownDrainDelay < 0 {
+		fail("SHUTDOWN_DRAIN_DELAY", "must not be negative")
+	}
+
+	switch c.TracingExporter {
+	case "none", "stdout", "file", "otlp":
+	default:
+		fail("TRACING_EXPORTER", "must be one of none, stdout, file, otlp, got %q", c.TracingExporter)
+	}
+	if c.TracingExporter == "file" && c.TracingFile == "" {
+		fail("TRACING_FILE", "is required when TRACING_EXPORTER=file")
+	}
+	if c.TracingSampleRatio < 0 || c.TracingSampleRatio > 1 {
+		fail("TRACING_SAMPLE_RATIO", "must be between 0 and 1, got %g", c.TracingSampleRatio)
+	}
+
+	if c.IsProduction() {
+		errs = append(errs, c.validateProduction()...)
+	}
+
+	return errs
+}
+
+// validateProduction refuses settings that are fine on a laptop but unsafe
+// in production.
+func (c *Config) validateProduction() []error {
+	var errs []error
+
+	// DATABASE_URL wins over the DB_* settings, so check what it says.
+	// DB_SSLMODE still applies to every URL that names no sslmode.
+	defaultSSLMode := c.DatabaseURL == "" || urlSSLMode(c.DatabaseURL) == ""
+	if c.DatabaseURL != "" {
+		errs = append(errs, checkProductionURL("DATABASE_URL", c.DatabaseURL)...)
+	} else if weakPassword(c.DBPassword) {
+		errs = append(errs, fmt.Errorf("DB_PASSWORD: must be set to a non-default value in production"))
+	}
+	for i, dsn := range c.DBReplicaURLs {
+		errs = append(errs, checkProductionURL(fmt.Sprintf("DB_REPLICA_URLS: entry %d", i+1), dsn)...)
+		defaultSSLMode = defaultSSLMode || urlSSLMode(dsn) == ""
+	}
+	if defaultSSLMode && c.DBSSLMode == "disable" {
+		errs = append(errs, fmt.Errorf("DB_SSLMODE: must not be \"disable\" in production"))
+	}
+	if c.PublicBaseURL == "" {
+		errs = append(errs, fmt.Errorf("PUBLIC_BASE_URL: must be set in production, so feeds and sitemaps do not link to whatever Host a request names"))
+	}
+	if c.TracingExporter == "otlp" && c.TracingInsecure {
+		errs = append(errs, fmt.Errorf("TRACING_OTLP_INSECURE: must be false in production"))
+	}
+
+	return errs
+}
+
+// checkProductionURL applies the production password and sslmode checks
+// to a postgres:// URL, naming it by what in errors.
+func checkProductionURL(what, dsn string) []error {
+	var errs []error
+	if weakPassword(urlPassword(dsn)) {
+		errs = append(errs, fmt.Errorf("%s: must include a non-default password in production", what))
+	}
+	if urlSSLMode(dsn) == "disable" {
+		errs = append(errs, fmt.Errorf("%s: sslmode must not be \"disable\" in production", what))
+	}
+	return errs
+}
+
+func weakPassword(password string) bool {
+	return password == "" || password == "password"
+}
+
+// urlPassword returns the password in a postgres:// URL, given as user info
+// or a password parameter.
+func urlPassword(dsn string) string {
+	u, err := url.Parse(dsn)
+	if err != nil {
+		return ""
+	}
+	if password := u.Query().Get("password"); password != "" {
+		return password
+	}
+	password, _ := u.User.Password()
+	return password
+}
+
+// urlSSLMode returns the sslmode a postgres:// URL names, or "".
+func urlSSLMode(dsn string) string {
+	u, err := url.Parse(dsn)
+	if err != nil {
+		return ""
+	}
+	return u.Query().Get("sslmode")
 }
 
 func (c *Config) GetDBConnectionString() string {
-	return fmt.Sprintf(
-		"host=%s port=%d user=%s password=%s dbname=%s sslmode=disable",
-		c.DBHost, c.DBPort, c.DBUser, c.DBPassword, c.DBName,
+	if c.DatabaseURL != "" {
+		return c.withSSL(c.DatabaseURL)
+	}
+
+	dsn := fmt.Sprintf(
+		"host=%s port=%d user=%s password=%s dbname=%s sslmode=%s",
+		quoteDSN(c.DBHost), c.DBPort, quoteDSN(c.DBUser), quoteDSN(c.DBPassword), quoteDSN(c.DBName), c.DBSSLMode,
 	)
+	if c.DBSSLRootCert != "" {
+		dsn += " sslrootcert=" + quoteDSN(c.DBSSLRootCert)
+	}
+	return dsn
 }
 
-func getEnv(key, defaultValue string) string {
-    if value, exists := os.LookupEnv(key); exists {
-        return value
-    }
-    return defaultValue
+// GetReplicaConnectionStrings returns DB_REPLICA_URLS with DB_SSLMODE and
+// DB_SSLROOTCERT applied like they are to the primary.
+func (c *Config) GetReplicaConnectionStrings() []string {
+	dsns := make([]string, len(c.DBReplicaURLs))
+	for i, dsn := range c.DBReplicaURLs {
+		dsns[i] = c.withSSL(dsn)
+	}
+	return dsns
+}
+
+// withSSL adds DB_SSLMODE and DB_SSLROOTCERT to a postgres:// URL that does
+// not set them itself.
+func (c *Config) withSSL(dsn string) string {
+	u, err := url.Parse(dsn)
+	if err != nil {
+		return dsn
+	}
+	q := u.Query()
+	if q.Get("sslmode") == "" {
+		q.Set("sslmode", c.DBSSLMode)
+	}
+	if q.Get("sslrootcert") == "" && c.DBSSLRootCert != "" {
+		q.Set("sslrootcert", c.DBSSLRootCert)
+	}
+	u.RawQuery = q.Encode()
+	return u.String()
 }
 
-func getEnvAsInt(key string, defaultValue int) int {
-    if value, exists := os.LookupEnv(key); exists {
-        if intValue, err := strconv.Atoi(value); err == nil {
-            return intValue
-        }
-    }
-    return defaultValue
-}
\ No newline at end of file
+// quoteDSN quotes a value for a libpq key/value connection string.
+func quoteDSN(value string) string {
+	if value != "" && !strings.ContainsAny(value, ` '\`) {
+		return value
+	}
+	value = strings.ReplaceAll(value, `\`, `\\`)
+	value = strings.ReplaceAll(value, `'`, `\'`)
+	return "'" + value + "'"
+}
@@ -0,0 +1,247 @@
+package config
+
+import (
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// configWith builds a Config from the defaults with values overriding them,
+// as LoadArgs would without a file, environment or flags.
+func configWith(t *testing.T, values map[string]string) *Config {
+	t.Helper()
+	cfg := &Config{}
+	for _, s := range settings {
+		value, ok := values[s.key]
+		if !ok {
+			value = s.def
+		}
+		if err := s.set(cfg, value); err != nil {
+			t.Fatalf("%s: %v", s.key, err)
+		}
+	}
+	return cfg
+}
+
+func TestValidate(t *testing.T) {
+	production := map[string]string{
+		"APP_ENV":         EnvProduction,
+		"DB_PASSWORD":     "s3cret",
+		"DB_SSLMODE":      "require",
+		"PUBLIC_BASE_URL": "https://blog.example.com",
+	}
+	with := func(base map[string]string, kv ...string) map[string]string {
+		values := make(map[string]string, len(base)+len(kv)/2)
+		for k, v := range base {
+			values[k] = v
+		}
+		for i := 0; i < len(kv); i += 2 {
+			values[kv[i]] = kv[i+1]
+		}
+		return values
+	}
+
+	tests := []struct {
+		name   string
+		values map[string]string
+		want   []string // keys of the expected errors, in order
+	}{
+		{"development defaults", nil, nil},
+		{"production", production, nil},
+		{"production defaults", map[string]string{"APP_ENV": EnvProduction},
+			[]string{"DB_PASSWORD", "DB_SSLMODE", "PUBLIC_BASE_URL"}},
+		{"default password", with(production, "DB_PASSWORD", "password"), []string{"DB_PASSWORD"}},
+		{"sslmode disable", with(production, "DB_SSLMODE", "disable"), []string{"DB_SSLMODE"}},
+		{"no public base URL", with(production, "PUBLIC_BASE_URL", ""), []string{"PUBLIC_BASE_URL"}},
+		{"insecure OTLP", with(production,
+			"TRACING_EXPORTER", "otlp", "TRACING_OTLP_ENDPOINT", "collector:4318", "TRACING_OTLP_INSECURE", "true"),
+			[]string{"TRACING_OTLP_INSECURE"}},
+
+		{"URL", with(production, "DATABASE_URL", "postgres://blog:s3cret@db/blogdb?sslmode=verify-full"), nil},
+		{"URL password parameter", with(production, "DATABASE_URL", "postgres://blog@db/blogdb?password=s3cret&sslmode=require"), nil},
+		{"URL without password", with(production, "DATABASE_URL", "postgres://blog@db/blogdb?sslmode=require"),
+			[]string{"DATABASE_URL"}},
+		{"URL default password", with(production, "DATABASE_URL", "postgres://blog:password@db/blogdb?sslmode=require"),
+			[]string{"DATABASE_URL"}},
+		{"URL ignores DB_PASSWORD", with(production, "DATABASE_URL", "postgres://blog@db/blogdb?sslmode=require", "DB_PASSWORD", "s3cret"),
+			[]string{"DATABASE_URL"}},
+		{"URL sslmode disable", with(production, "DATABASE_URL", "postgres://blog:s3cret@db/blogdb?sslmode=disable"),
+			[]string{"DATABASE_URL"}},
+		{"URL falls back to DB_SSLMODE", with(production, "DATABASE_URL", "postgres://blog:s3cret@db/blogdb", "DB_SSLMODE", "disable"),
+			[]string{"DB_SSLMODE"}},
+		{"URL sslmode overrides DB_SSLMODE", with(production, "DATABASE_URL", "postgres://blog:s3cret@db/blogdb?sslmode=require", "DB_SSLMODE", "disable"),
+			nil},
+		{"replicas", with(production, "DB_REPLICA_URLS", "postgres://blog:s3cret@r1/blogdb,postgres://blog:s3cret@r2/blogdb?sslmode=verify-full"), nil},
+		{"replica without password", with(production, "DB_REPLICA_URLS", "postgres://blog:s3cret@r1/blogdb,postgres://blog@r2/blogdb"),
+			[]string{"DB_REPLICA_URLS"}},
+		{"replica sslmode disable", with(production, "DB_REPLICA_URLS", "postgres://blog:s3cret@r1/blogdb?sslmode=disable"),
+			[]string{"DB_REPLICA_URLS"}},
+		{"replica falls back to DB_SSLMODE", with(production,
+			"DATABASE_URL", "postgres://blog:s3cret@db/blogdb?sslmode=require",
+			"DB_REPLICA_URLS", "postgres://blog:s3cret@r1/blogdb",
+			"DB_SSLMODE", "disable"),
+			[]string{"DB_SSLMODE"}},
+		{"development allows weak URL", map[string]string{"DATABASE_URL": "postgres://postgres@localhost/blogdb"}, nil},
+
+		{"post path by id", with(production, "PUBLIC_POST_PATH", "/p/{id}"), nil},
+		{"post path without placeholder", with(production, "PUBLIC_POST_PATH", "/posts"), []string{"PUBLIC_POST_PATH"}},
+		{"relative post path", with(production, "PUBLIC_POST_PATH", "posts/{slug}"), []string{"PUBLIC_POST_PATH"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			errs := configWith(t, tt.values).validate()
+			var got []string
+			for _, err := range errs {
+				key, _, _ := strings.Cut(err.Error(), ":")
+				got = append(got, key)
+			}
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("got errors %v, want errors for %v", errs, tt.want)
+			}
+		})
+	}
+}
+
+func TestReplicaConnectionStrings(t *testing.T) {
+	cfg := configWith(t, map[string]string{
+		"DB_SSLMODE":      "verify-full",
+		"DB_SSLROOTCERT":  "/etc/ssl/db.pem",
+		"DB_REPLICA_URLS": "postgres://blog:s3cret@r1/blogdb,postgres://blog:s3cret@r2/blogdb?sslmode=require&sslrootcert=%2Fr2.pem",
+	})
+	want := []string{
+		"postgres://blog:s3cret@r1/blogdb?sslmode=verify-full&sslrootcert=%2Fetc%2Fssl%2Fdb.pem",
+		"postgres://blog:s3cret@r2/blogdb?sslmode=require&sslrootcert=%2Fr2.pem",
+	}
+	if got := cfg.GetReplicaConnectionStrings(); !slices.Equal(got, want) {
+		t.Errorf("GetReplicaConnectionStrings() = %q, want %q", got, want)
+	}
+}
+
+func TestLoadArgsPrecedence(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "blog.env")
+	writeFile(t, path, "DB_HOST=file\nDB_NAME=file\nDB_USER=file\n")
+	t.Setenv("APP_ENV", EnvDevelopment)
+	t.Setenv("DB_NAME", "env")
+	t.Setenv("DB_USER", "env")
+
+	cfg, rest, err := LoadArgs([]string{"-config", path, "-db-user", "flag", "migrate", "up"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := []string{cfg.DBHost, cfg.DBName, cfg.DBUser, strconv.Itoa(cfg.DBPort)}
+	if want := []string{"file", "env", "flag", "5432"}; !slices.Equal(got, want) {
+		t.Errorf("DB_HOST, DB_NAME, DB_USER, DB_PORT = %q, want %q", got, want)
+	}
+	if want := []string{"migrate", "up"}; !slices.Equal(rest, want) {
+		t.Errorf("remaining args = %q, want %q", rest, want)
+	}
+}
+
+func TestLoadArgsDefaultFile(t *testing.T) {
+	t.Chdir(t.TempDir())
+	writeFile(t, defaultConfigFile, "DB_HOST=filehost\nDB_PASSWORD=blogpassword\n")
+	t.Setenv("CONFIG_FILE", "")
+
+	t.Setenv("APP_ENV", EnvDevelopment)
+	cfg, _, err := LoadArgs(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.DBHost != "filehost" {
+		t.Errorf("development: DB_HOST = %q, want it from %s", cfg.DBHost, defaultConfigFile)
+	}
+
+	// Production must not pick up the development credentials
+	t.Setenv("APP_ENV", EnvProduction)
+	_, _, err = LoadArgs([]string{"-db-sslmode", "require", "-public-base-url", "https://blog.example.com"})
+	if err == nil || !strings.Contains(err.Error(), "DB_PASSWORD") {
+		t.Errorf("production with only %s for a password: err = %v, want DB_PASSWORD refused", defaultConfigFile, err)
+	}
+	cfg, _, err = LoadArgs([]string{"-db-password", "s3cret", "-db-sslmode", "require", "-public-base-url", "https://blog.example.com"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.DBHost != "localhost" {
+		t.Errorf("production: DB_HOST = %q, want the default", cfg.DBHost)
+	}
+
+	// Unless it is asked for by name
+	t.Setenv("CONFIG_FILE", defaultConfigFile)
+	cfg, _, err = LoadArgs([]string{"-db-password", "s3cret", "-db-sslmode", "require", "-public-base-url", "https://blog.example.com"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.DBHost != "filehost" {
+		t.Errorf("production with CONFIG_FILE: DB_HOST = %q, want it from the file", cfg.DBHost)
+	}
+}
+
+func TestParseDotEnv(t *testing.T) {
+	got, err := parseDotEnv([]byte(`
+# comment
+DB_HOST=db.internal
+export DB_USER = blog
+DB_PASSWORD="pa ss=word"
+DB_NAME='blogdb'
+PUBLIC_BASE_URL=
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]string{
+		"DB_HOST":         "db.internal",
+		"DB_USER":         "blog",
+		"DB_PASSWORD":     "pa ss=word",
+		"DB_NAME":         "blogdb",
+		"PUBLIC_BASE_URL": "",
+	}
+	if !maps.Equal(got, want) {
+		t.Errorf("parseDotEnv = %q, want %q", got, want)
+	}
+
+	if _, err := parseDotEnv([]byte("DB_HOST=x\nnot a setting\n")); err == nil || !strings.Contains(err.Error(), "line 2") {
+		t.Errorf("parseDotEnv of a line without = returned %v, want an error for line 2", err)
+	}
+}
+
+func TestParseYAML(t *testing.T) {
+	got, err := parseYAML([]byte(`
+app_env: production
+db:
+  host: db.internal
+  port: 5433
+  sslmode: verify-full
+  replica-urls:
+    - postgres://r1/blogdb
+    - postgres://r2/blogdb
+outbox_sinks: [log, webhooks]
+public_base_url:
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]string{
+		"APP_ENV":         "production",
+		"DB_HOST":         "db.internal",
+		"DB_PORT":         "5433",
+		"DB_SSLMODE":      "verify-full",
+		"DB_REPLICA_URLS": "postgres://r1/blogdb,postgres://r2/blogdb",
+		"OUTBOX_SINKS":    "log,webhooks",
+		"PUBLIC_BASE_URL": "",
+	}
+	if !maps.Equal(got, want) {
+		t.Errorf("parseYAML = %q, want %q", got, want)
+	}
+}
+
+func writeFile(t *testing.T, path, content string) {
+	t.Helper()
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+}
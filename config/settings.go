@@ -0,0 +1,227 @@
+package config
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// setting describes one configuration value. key is the environment
+// variable name; the flag name and config file key are derived from it.
+type setting struct {
+	key   string
+	def   string
+	usage string
+	set   func(c *Config, value string) error
+}
+
+// flagName turns DB_HOST into db-host.
+func (s setting) flagName() string {
+	return strings.ReplaceAll(strings.ToLower(s.key), "_", "-")
+}
+
+var settings = []setting{
+	{"APP_ENV", EnvDevelopment, "deployment profile: development or production",
+		func(c *Config, v string) error { c.Env = v; return nil }},
+
+	{"DATABASE_URL", "", "postgres:// connection URL, overrides the DB_* settings",
+		func(c *Config, v string) error { c.DatabaseURL = v; return nil }},
+	{"DB_HOST", "localhost", "database host",
+		func(c *Config, v string) error { c.DBHost = v; return nil }},
+	{"DB_PORT", "5432", "database port",
+		func(c *Config, v string) error { return parseInt(v, &c.DBPort) }},
+	{"DB_USER", "postgres", "database user",
+		func(c *Config, v string) error { c.DBUser = v; return nil }},
+	{"DB_PASSWORD", "", "database password",
+		func(c *Config, v string) error { c.DBPassword = v; return nil }},
+	{"DB_NAME", "blogdb", "database name",
+		func(c *Config, v string) error { c.DBName = v; return nil }},
+	{"DB_SSLMODE", "disable", "database sslmode: disable, require, verify-ca or verify-full",
+		func(c *Config, v string) error { c.DBSSLMode = v; return nil }},
+	{"DB_SSLROOTCERT", "", "path to the CA certificate used to verify the database server",
+		func(c *Config, v string) error { c.DBSSLRootCert = v; return nil }},
+
+	{"DB_MAX_OPEN_CONNS", "25", "maximum open database connections (0 = unlimited)",
+		func(c *Config, v string) error { return parseInt(v, &c.DBMaxOpenConns) }},
+	{"DB_MAX_IDLE_CONNS", "10", "maximum idle database connections (0 = none kept)",
+		func(c *Config, v string) error { return parseInt(v, &c.DBMaxIdleConns) }},
+	{"DB_CONN_MAX_LIFETIME", "30m", "maximum time a connection may be reused",
+		func(c *Config, v string) error { return parseDuration(v, &c.DBConnMaxLifetime) }},
+	{"DB_CONN_MAX_IDLE_TIME", "5m", "maximum time a connection may sit idle",
+		func(c *Config, v string) error { return parseDuration(v, &c.DBConnMaxIdleTime) }},
+	{"DB_CONNECT_TIMEOUT", "30s", "how long to keep retrying the database at startup",
+		func(c *Config, v string) error { return parseDuration(v, &c.DBConnectTimeout) }},
+	{"DB_READ_RETRIES", "2", "retries for reads that fail with a transient error",
+		func(c *Config, v string) error { return parseInt(v, &c.DBReadRetries) }},
+	{"DB_AUTO_MIGRATE", "true", "apply pending database migrations at startup",
+		func(c *Config, v string) error { return parseBool(v, &c.DBAutoMigrate) }},
+
+	{"DB_REPLICA_URLS", "", "comma-separated postgres:// URLs of read replicas; DB_SSLMODE and DB_SSLROOTCERT apply to those that do not set them",
+		func(c *Config, v string) error { c.DBReplicaURLs = splitList(v); return nil }},
+	{"DB_REPLICA_CHECK_INTERVAL", "5s", "how often replicas are health checked",
+		func(c *Config, v string) error { return parseDuration(v, &c.DBReplicaCheckInterval) }},
+	{"DB_READ_YOUR_WRITES_WINDOW", "5s", "how long a client reads from the primary after writing (0 disables)",
+		func(c *Config, v string) error { return parseDuration(v, &c.DBReadYourWritesWindow) }},
+
+	{"SERVER_PORT", "8080", "HTTP listen port",
+		func(c *Config, v string) error { c.ServerPort = v; return nil }},
+	{"ADMIN_ADDR", "localhost:6060", "listen address for /debug/vars, e.g. localhost:6060 (empty disables)",
+		func(c *Config, v string) error { c.AdminAddr = v; return nil }},
+
+	{"PUBLIC_BASE_URL", "", "public URL of the site used in absolute links, e.g. https://blog.example.com",
+		func(c *Config, v string) error { c.PublicBaseURL = strings.TrimRight(v, "/"); return nil }},
+	{"PUBLIC_POST_PATH", "/posts/{slug}", "path of a post's page on the public site; {slug} and {id} are replaced",
+		func(c *Config, v string) error { c.PublicPostPath = v; return nil }},
+	{"SITE_TITLE", "Blog", "site title shown in feeds",
+		func(c *Config, v string) error { c.SiteTitle = v; return nil }},
+	{"SITE_DESCRIPTION", "Latest posts", "site description shown in feeds",
+		func(c *Config, v string) error { c.SiteDescription = v; return nil }},
+	{"ROBOTS_DISALLOW", "/debug/", "comma-separated path prefixes robots.txt asks crawlers to skip",
+		func(c *Config, v string) error { c.RobotsDisallow = splitList(v); return nil }},
+
+	{"CACHE_ENABLED", "true", "cache posts and listings in memory",
+		func(c *Config, v string) error { return parseBool(v, &c.CacheEnabled) }},
+	{"CACHE_MAX_BYTES", "67108864", "upper bound on cached post data in bytes",
+		func(c *Config, v string) error { return parseInt64(v, &c.CacheMaxBytes) }},
+	{"CACHE_TTL", "30s", "how long cached posts and listings stay fresh",
+		func(c *Config, v string) error { return parseDuration(v, &c.CacheTTL) }},
+
+	{"WEBHOOKS_ENABLED", "true", "send webhooks; false stops all deliveries even if OUTBOX_SINKS lists webhooks",
+		func(c *Config, v string) error { return parseBool(v, &c.WebhooksEnabled) }},
+	{"WEBHOOK_TIMEOUT", "10s", "time limit for each webhook request",
+		func(c *Config, v string) error { return parseDuration(v, &c.WebhookTimeout) }},
+	{"WEBHOOK_MAX_ATTEMPTS", "8", "attempts before a webhook delivery is marked failed",
+		func(c *Config, v string) error { return parseInt(v, &c.WebhookMaxAttempts) }},
+	{"WEBHOOK_POLL_INTERVAL", "2s", "how often due webhook deliveries are sent",
+		func(c *Config, v string) error { return parseDuration(v, &c.WebhookPollInterval) }},
+	{"WEBHOOK_ALLOW_PRIVATE_TARGETS", "false", "allow webhook URLs on loopback, private and link-local addresses",
+		func(c *Config, v string) error { return parseBool(v, &c.WebhookAllowPrivateTargets) }},
+
+	{"ADMIN_TOKEN", "", "bearer token required to manage webhooks (empty disables those routes)",
+		func(c *Config, v string) error { c.AdminToken = v; return nil }},
+
+	{"OUTBOX_SINKS", "webhooks", "comma-separated sinks post events are relayed to: webhooks, log, file",
+		func(c *Config, v string) error { c.OutboxSinks = splitList(v); return nil }},
+	{"OUTBOX_FILE", "events.jsonl", "output path for the file event sink",
+		func(c *Config, v string) error { c.OutboxFile = v; return nil }},
+	{"OUTBOX_POLL_INTERVAL", "1s", "how often the outbox is checked for new events",
+		func(c *Config, v string) error { return parseDuration(v, &c.OutboxPollInterval) }},
+	{"OUTBOX_RETENTION", "168h", "how long relayed events are kept (0 keeps them forever)",
+		func(c *Config, v string) error { return parseDuration(v, &c.OutboxRetention) }},
+
+	{"STREAM_REPLAY_BUFFER", "1000", "recent events kept for clients resuming the post stream",
+		func(c *Config, v string) error { return parseInt(v, &c.StreamReplayBuffer) }},
+	{"STREAM_HEARTBEAT", "10s", "interval between keep-alive comments on the post stream",
+		func(c *Config, v string) error { return parseDuration(v, &c.StreamHeartbeat) }},
+
+	{"CHANGE_FEED_RETENTION", "720h", "how long deleted posts stay in the change feed as tombstones",
+		func(c *Config, v string) error { return parseDuration(v, &c.ChangeFeedRetention) }},
+	{"CHANGE_FEED_LAG_ALERT", "5m", "log when a write transaction has held the change feed back this long (0 disables)",
+		func(c *Config, v string) error { return parseDuration(v, &c.ChangeFeedLagAlert) }},
+
+	{"TRASH_RETENTION", "720h", "how long deleted posts stay in the trash before they are purged",
+		func(c *Config, v string) error { return parseDuration(v, &c.TrashRetention) }},
+
+	{"GRAPHQL_MAX_DEPTH", "10", "deepest field nesting a GraphQL query may use",
+		func(c *Config, v string) error { return parseInt(v, &c.GraphQLMaxDepth) }},
+	{"GRAPHQL_MAX_COMPLEXITY", "1000", "highest cost a GraphQL query may have; fields under posts count once per item",
+		func(c *Config, v string) error { return parseInt(v, &c.GraphQLMaxComplexity) }},
+
+	{"IDEMPOTENCY_KEY_TTL", "24h", "how long the response to an Idempotency-Key is replayed to retries",
+		func(c *Config, v string) error { return parseDuration(v, &c.IdempotencyKeyTTL) }},
+
+	{"OPENAPI_VALIDATE_REQUESTS", "false", "reject requests that do not match openapi/openapi.json with 400",
+		func(c *Config, v string) error { return parseBool(v, &c.ValidateRequests) }},
+
+	{"HEALTH_CHECK_TIMEOUT", "2s", "time limit for the readiness database check",
+		func(c *Config, v string) error { return parseDuration(v, &c.HealthCheckTimeout) }},
+	{"SHUTDOWN_DRAIN_DELAY", "5s", "how long readiness fails before the server stops",
+		func(c *Config, v string) error { return parseDuration(v, &c.ShutdownDrainDelay) }},
+
+	{"OTEL_SERVICE_NAME", "blog-api", "service name reported in traces",
+		func(c *Config, v string) error { c.ServiceName = v; return nil }},
+	{"TRACING_EXPORTER", "none", "trace exporter: none, stdout, file or otlp",
+		func(c *Config, v string) error { c.TracingExporter = v; return nil }},
+	{"TRACING_FILE", "traces.jsonl", "output path for the file trace exporter",
+		func(c *Config, v string) error { c.TracingFile = v; return nil }},
+	{"TRACING_OTLP_ENDPOINT", "", "OTLP/HTTP collector endpoint (host:port)",
+		func(c *Config, v string) error { c.TracingEndpoint = v; return nil }},
+	{"TRACING_OTLP_INSECURE", "false", "send OTLP traces without TLS",
+		func(c *Config, v string) error { return parseBool(v, &c.TracingInsecure) }},
+	{"TRACING_SAMPLE_RATIO", "1.0", "fraction of traces to sample, 0 to 1",
+		func(c *Config, v string) error { return parseFloat(v, &c.TracingSampleRatio) }},
+}
+
+func lookupSetting(key string) *setting {
+	for i := range settings {
+		if settings[i].key == key {
+			return &settings[i]
+		}
+	}
+	return nil
+}
+
+func lookupFlag(name string) *setting {
+	for i := range settings {
+		if settings[i].flagName() == name {
+			return &settings[i]
+		}
+	}
+	return nil
+}
+
+func parseInt(value string, dst *int) error {
+	v, err := strconv.Atoi(value)
+	if err != nil {
+		return fmt.Errorf("invalid integer %q", value)
+	}
+	*dst = v
+	return nil
+}
+
+func parseInt64(value string, dst *int64) error {
+	v, err := strconv.ParseInt(value, 10, 64)
+	if err != nil {
+		return fmt.Errorf("invalid integer %q", value)
+	}
+	*dst = v
+	return nil
+}
+
+func parseBool(value string, dst *bool) error {
+	v, err := strconv.ParseBool(value)
+	if err != nil {
+		return fmt.Errorf("invalid boolean %q", value)
+	}
+	*dst = v
+	return nil
+}
+
+func parseFloat(value string, dst *float64) error {
+	v, err := strconv.ParseFloat(value, 64)
+	if err != nil {
+		return fmt.Errorf("invalid number %q", value)
+	}
+	*dst = v
+	return nil
+}
+
+func parseDuration(value string, dst *time.Duration) error {
+	v, err := time.ParseDuration(value)
+	if err != nil {
+		return fmt.Errorf("invalid duration %q (use e.g. 500ms, 5s, 1m)", value)
+	}
+	*dst = v
+	return nil
+}
+
+func splitList(value string) []string {
+	var items []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
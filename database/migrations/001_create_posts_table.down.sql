@@ -0,0 +1,2 @@
+DROP TABLE IF EXISTS posts CASCADE;
+DROP FUNCTION IF EXISTS update_updated_at_column();
@@ -0,0 +1,5 @@
+DROP INDEX IF EXISTS idx_posts_cursor_created_at;
+DROP INDEX IF EXISTS idx_posts_cursor_updated_at;
+DROP INDEX IF EXISTS idx_posts_cursor_title;
+DROP INDEX IF EXISTS idx_posts_author_created_at;
+DROP INDEX IF EXISTS idx_posts_search_gin;
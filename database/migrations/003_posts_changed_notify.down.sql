@@ -0,0 +1,2 @@
+DROP TRIGGER IF EXISTS posts_changed_notify ON posts;
+DROP FUNCTION IF EXISTS notify_posts_changed();
@@ -0,0 +1,23 @@
+-- Notify listeners of every committed change to posts so each server can
+-- invalidate its local cache
+CREATE OR REPLACE FUNCTION notify_posts_changed()
+RETURNS TRIGGER AS $$
+DECLARE
+    post_id INT;
+BEGIN
+    IF TG_OP = 'DELETE' THEN
+        post_id := OLD.id;
+    ELSE
+        post_id := NEW.id;
+    END IF;
+    PERFORM pg_notify('posts_changed',
+        json_build_object('id', post_id, 'op', lower(TG_OP))::text);
+    RETURN NULL;
+END;
+$$ language 'plpgsql';
+
+DROP TRIGGER IF EXISTS posts_changed_notify ON posts;
+CREATE TRIGGER posts_changed_notify
+    AFTER INSERT OR UPDATE OR DELETE ON posts
+    FOR EACH ROW
+    EXECUTE FUNCTION notify_posts_changed();
@@ -0,0 +1,3 @@
+DROP INDEX IF EXISTS idx_posts_slug;
+DROP TABLE IF EXISTS post_slug_redirects;
+ALTER TABLE posts DROP COLUMN IF EXISTS slug;
@@ -0,0 +1,15 @@
+-- Human-readable permalinks
+ALTER TABLE posts ADD COLUMN IF NOT EXISTS slug VARCHAR(255);
+
+-- Old slugs keep resolving after a post is renamed
+CREATE TABLE IF NOT EXISTS post_slug_redirects (
+    slug VARCHAR(255) PRIMARY KEY,
+    post_id INT NOT NULL REFERENCES posts(id) ON DELETE CASCADE,
+    created_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_post_slug_redirects_post_id ON post_slug_redirects(post_id);
+
+-- Existing rows get their slugs from the application on startup (see
+-- PostgresStore.Init), which handles transliteration and collisions
+CREATE UNIQUE INDEX IF NOT EXISTS idx_posts_slug ON posts(slug);
@@ -0,0 +1,3 @@
+DROP TRIGGER IF EXISTS posts_record_change ON posts;
+DROP FUNCTION IF EXISTS record_post_change();
+DROP TABLE IF EXISTS post_changes;
@@ -0,0 +1,44 @@
+-- Change feed: the latest change per post plus tombstones for deleted posts.
+-- tx_id lets readers skip changes from transactions that may still be
+-- overtaken by ones in flight (requires PostgreSQL 13+).
+CREATE TABLE IF NOT EXISTS post_changes (
+    seq BIGSERIAL PRIMARY KEY,
+    post_id INT NOT NULL,
+    op TEXT NOT NULL,
+    tx_id XID8 NOT NULL DEFAULT pg_current_xact_id(),
+    changed_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_post_changes_position ON post_changes(tx_id, seq);
+CREATE INDEX IF NOT EXISTS idx_post_changes_post_id ON post_changes(post_id);
+CREATE INDEX IF NOT EXISTS idx_post_changes_tombstones ON post_changes(changed_at) WHERE op = 'delete';
+
+CREATE OR REPLACE FUNCTION record_post_change()
+RETURNS TRIGGER AS $$
+DECLARE
+    changed_id INT;
+BEGIN
+    IF TG_OP = 'DELETE' THEN
+        changed_id := OLD.id;
+    ELSE
+        changed_id := NEW.id;
+    END IF;
+    DELETE FROM post_changes WHERE post_id = changed_id;
+    INSERT INTO post_changes (post_id, op) VALUES (changed_id, lower(TG_OP));
+    RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
+
+DROP TRIGGER IF EXISTS posts_record_change ON posts;
+CREATE TRIGGER posts_record_change
+    AFTER INSERT OR UPDATE OR DELETE ON posts
+    FOR EACH ROW
+    EXECUTE FUNCTION record_post_change();
+
+-- Posts written before the feed existed. This runs once, with the
+-- migration; the trigger above records every change after it.
+INSERT INTO post_changes (post_id, op, changed_at)
+SELECT p.id, 'insert', COALESCE(p.updated_at, CURRENT_TIMESTAMP)
+FROM posts p
+WHERE NOT EXISTS (SELECT 1 FROM post_changes c WHERE c.post_id = p.id)
+ORDER BY p.id;
@@ -0,0 +1,38 @@
+-- Posts in the trash are deleted for good. The current triggers already
+-- announced them as deleted, so they stay quiet here.
+DELETE FROM posts WHERE deleted_at IS NOT NULL;
+
+CREATE OR REPLACE FUNCTION notify_posts_changed()
+RETURNS TRIGGER AS $$
+DECLARE
+    post_id INT;
+BEGIN
+    IF TG_OP = 'DELETE' THEN
+        post_id := OLD.id;
+    ELSE
+        post_id := NEW.id;
+    END IF;
+    PERFORM pg_notify('posts_changed',
+        json_build_object('id', post_id, 'op', lower(TG_OP))::text);
+    RETURN NULL;
+END;
+$$ language 'plpgsql';
+
+CREATE OR REPLACE FUNCTION record_post_change()
+RETURNS TRIGGER AS $$
+DECLARE
+    changed_id INT;
+BEGIN
+    IF TG_OP = 'DELETE' THEN
+        changed_id := OLD.id;
+    ELSE
+        changed_id := NEW.id;
+    END IF;
+    DELETE FROM post_changes WHERE post_id = changed_id;
+    INSERT INTO post_changes (post_id, op) VALUES (changed_id, lower(TG_OP));
+    RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
+
+DROP INDEX IF EXISTS idx_posts_deleted_at;
+ALTER TABLE posts DROP COLUMN IF EXISTS deleted_at;
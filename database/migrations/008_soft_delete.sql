@@ -0,0 +1,69 @@
+-- Soft delete: DELETE /posts/{id} moves a post to the trash, from which it
+-- can be restored or purged. Listeners see trashing as a delete and
+-- restoring as an insert; purging a trashed post is not reported again.
+-- Trashed posts keep their slug, so it stays reserved until they are purged.
+ALTER TABLE posts ADD COLUMN IF NOT EXISTS deleted_at TIMESTAMPTZ;
+
+CREATE INDEX IF NOT EXISTS idx_posts_deleted_at ON posts(deleted_at) WHERE deleted_at IS NOT NULL;
+
+CREATE OR REPLACE FUNCTION notify_posts_changed()
+RETURNS TRIGGER AS $$
+DECLARE
+    post_id INT;
+    change_op TEXT := lower(TG_OP);
+BEGIN
+    IF TG_OP = 'DELETE' THEN
+        IF OLD.deleted_at IS NOT NULL THEN
+            RETURN NULL;
+        END IF;
+        post_id := OLD.id;
+    ELSE
+        post_id := NEW.id;
+    END IF;
+
+    IF TG_OP = 'UPDATE' THEN
+        IF NEW.deleted_at IS NOT NULL AND OLD.deleted_at IS NULL THEN
+            change_op := 'delete';
+        ELSIF NEW.deleted_at IS NULL AND OLD.deleted_at IS NOT NULL THEN
+            change_op := 'insert';
+        ELSIF NEW.deleted_at IS NOT NULL THEN
+            RETURN NULL;
+        END IF;
+    END IF;
+
+    PERFORM pg_notify('posts_changed',
+        json_build_object('id', post_id, 'op', change_op)::text);
+    RETURN NULL;
+END;
+$$ language 'plpgsql';
+
+CREATE OR REPLACE FUNCTION record_post_change()
+RETURNS TRIGGER AS $$
+DECLARE
+    changed_id INT;
+    change_op TEXT := lower(TG_OP);
+BEGIN
+    IF TG_OP = 'DELETE' THEN
+        IF OLD.deleted_at IS NOT NULL THEN
+            RETURN NULL;
+        END IF;
+        changed_id := OLD.id;
+    ELSE
+        changed_id := NEW.id;
+    END IF;
+
+    IF TG_OP = 'UPDATE' THEN
+        IF NEW.deleted_at IS NOT NULL AND OLD.deleted_at IS NULL THEN
+            change_op := 'delete';
+        ELSIF NEW.deleted_at IS NULL AND OLD.deleted_at IS NOT NULL THEN
+            change_op := 'insert';
+        ELSIF NEW.deleted_at IS NOT NULL THEN
+            RETURN NULL;
+        END IF;
+    END IF;
+
+    DELETE FROM post_changes WHERE post_id = changed_id;
+    INSERT INTO post_changes (post_id, op) VALUES (changed_id, change_op);
+    RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
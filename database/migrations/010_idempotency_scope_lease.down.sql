@@ -0,0 +1,13 @@
+-- Keys are global again, so only one client's copy of each can stay.
+DELETE FROM idempotency_keys WHERE scope <> '';
+
+ALTER TABLE idempotency_keys DROP CONSTRAINT IF EXISTS idempotency_keys_pkey;
+ALTER TABLE idempotency_keys ADD PRIMARY KEY (key);
+
+ALTER TABLE idempotency_keys ADD COLUMN IF NOT EXISTS content_type TEXT NOT NULL DEFAULT '';
+UPDATE idempotency_keys SET content_type = COALESCE(headers->'Content-Type'->>0, '');
+
+ALTER TABLE idempotency_keys DROP COLUMN IF EXISTS headers;
+ALTER TABLE idempotency_keys DROP COLUMN IF EXISTS leased_until;
+ALTER TABLE idempotency_keys DROP COLUMN IF EXISTS lease_token;
+ALTER TABLE idempotency_keys DROP COLUMN IF EXISTS scope;
@@ -0,0 +1,16 @@
+-- Idempotency keys belong to the client that sent them, so one client can
+-- neither replay nor block another's. A running request holds its key by
+-- lease_token until leased_until, renewing it as it goes. Responses keep
+-- their headers, not just the content type.
+ALTER TABLE idempotency_keys ADD COLUMN IF NOT EXISTS scope TEXT NOT NULL DEFAULT '';
+ALTER TABLE idempotency_keys ADD COLUMN IF NOT EXISTS lease_token TEXT;
+ALTER TABLE idempotency_keys ADD COLUMN IF NOT EXISTS leased_until TIMESTAMPTZ;
+ALTER TABLE idempotency_keys ADD COLUMN IF NOT EXISTS headers JSONB NOT NULL DEFAULT '{}';
+
+UPDATE idempotency_keys
+SET headers = jsonb_build_object('Content-Type', jsonb_build_array(content_type))
+WHERE content_type <> '';
+ALTER TABLE idempotency_keys DROP COLUMN content_type;
+
+ALTER TABLE idempotency_keys DROP CONSTRAINT IF EXISTS idempotency_keys_pkey;
+ALTER TABLE idempotency_keys ADD PRIMARY KEY (scope, key);
@@ -0,0 +1,22 @@
+-- Announce every event written to the outbox so each server can push it
+-- to its stream subscribers with its real event ID, without reading the
+-- post back. NOTIFY payloads are limited to 8000 bytes; larger events are
+-- announced by outbox id and read from the table instead.
+CREATE OR REPLACE FUNCTION notify_post_event()
+RETURNS TRIGGER AS $$
+DECLARE
+    body TEXT := NEW.payload::text;
+BEGIN
+    IF octet_length(body) > 7900 THEN
+        body := json_build_object('outbox_id', NEW.id)::text;
+    END IF;
+    PERFORM pg_notify('post_events', body);
+    RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
+
+DROP TRIGGER IF EXISTS outbox_notify ON outbox;
+CREATE TRIGGER outbox_notify
+    AFTER INSERT ON outbox
+    FOR EACH ROW
+    EXECUTE FUNCTION notify_post_event();
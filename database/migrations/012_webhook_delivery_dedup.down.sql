@@ -0,0 +1,4 @@
+DROP INDEX IF EXISTS idx_webhook_deliveries_event;
+CREATE INDEX IF NOT EXISTS idx_webhook_deliveries_event
+    ON webhook_deliveries(subscription_id, event_id);
+ALTER TABLE webhook_deliveries DROP COLUMN IF EXISTS redelivery_of;
@@ -0,0 +1,24 @@
+-- Deliveries were deduplicated with NOT EXISTS, which two relays handling
+-- the same event at once could both pass. A unique index makes the second
+-- insert a no-op instead. Redeliveries are deliberate copies; they record
+-- the delivery they repeat and stay outside the index.
+ALTER TABLE webhook_deliveries ADD COLUMN IF NOT EXISTS redelivery_of BIGINT;
+
+-- Copies made so far, by redelivery or the race, count as redeliveries of
+-- the first delivery of their event
+UPDATE webhook_deliveries d
+SET redelivery_of = first.id
+FROM (
+    SELECT subscription_id, event_id, MIN(id) AS id
+    FROM webhook_deliveries
+    GROUP BY subscription_id, event_id
+    HAVING COUNT(*) > 1
+) first
+WHERE d.subscription_id = first.subscription_id
+    AND d.event_id = first.event_id
+    AND d.id > first.id;
+
+DROP INDEX IF EXISTS idx_webhook_deliveries_event;
+CREATE UNIQUE INDEX IF NOT EXISTS idx_webhook_deliveries_event
+    ON webhook_deliveries(event_id, subscription_id)
+    WHERE redelivery_of IS NULL;
@@ -0,0 +1,8 @@
+// Package migrations embeds the numbered SQL files in this directory.
+// NNN_name.sql applies a change and NNN_name.down.sql reverts it.
+package migrations
+
+import "embed"
+
+//go:embed *.sql
+var FS embed.FS
@@ -0,0 +1,237 @@
+package gql
+
+import (
+	"blog-api/models"
+	"blog-api/render"
+	"blog-api/storage"
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/graphql-go/graphql"
+	"github.com/graphql-go/graphql/gqlerrors"
+	"github.com/graphql-go/graphql/language/ast"
+	"github.com/graphql-go/graphql/language/parser"
+	"github.com/graphql-go/graphql/language/source"
+)
+
+// Options limits the operations an Executor accepts. Zero disables a limit.
+type Options struct {
+	MaxDepth      int
+	MaxComplexity int
+}
+
+// Request is a GraphQL request as sent over HTTP.
+type Request struct {
+	Query         string                 `json:"query"`
+	OperationName string                 `json:"operationName"`
+	Variables     map[string]interface{} `json:"variables"`
+	Extensions    map[string]interface{} `json:"extensions"` // accepted for client compatibility, unused
+}
+
+// Executor runs GraphQL operations against a PostStore.
+type Executor struct {
+	schema    graphql.Schema
+	resolvers *resolvers
+	opts      Options
+}
+
+func NewExecutor(store storage.PostStore, renderer *render.Renderer, opts Options) (*Executor, error) {
+	r := &resolvers{store: store, renderer: renderer}
+	schema, err := newSchema(r)
+	if err != nil {
+		return nil, err
+	}
+	return &Executor{schema: schema, resolvers: r, opts: opts}, nil
+}
+
+// Execute parses, validates and runs req. readOnly rejects mutations, for
+// requests that arrive as GET. Requests that never ran come back with
+// errors and no data.
+func (e *Executor) Execute(ctx context.Context, req Request, readOnly bool) *graphql.Result {
+	doc, err := parser.Parse(parser.ParseParams{
+		Source: source.NewSource(&source.Source{Body: []byte(req.Query), Name: "GraphQL request"}),
+	})
+	if err != nil {
+		return &graphql.Result{Errors: gqlerrors.FormatErrors(err)}
+	}
+
+	if v := graphql.ValidateDocument(&e.schema, doc, nil); !v.IsValid {
+		return &graphql.Result{Errors: v.Errors}
+	}
+
+	op, err := operation(doc, req.OperationName)
+	if err != nil {
+		return &graphql.Result{Errors: gqlerrors.FormatErrors(err)}
+	}
+	if readOnly && op.Operation != ast.OperationTypeQuery {
+		return &graphql.Result{Errors: gqlerrors.FormatErrors(fmt.Errorf("%s operations must be sent with POST", op.Operation))}
+	}
+
+	l := limits{maxDepth: e.opts.MaxDepth, maxComplexity: e.opts.MaxComplexity}
+	if err := l.check(doc, op, req.Variables); err != nil {
+		return &graphql.Result{Errors: gqlerrors.FormatErrors(err)}
+	}
+
+	return graphql.Execute(graphql.ExecuteParams{
+		Schema:        e.schema,
+		AST:           doc,
+		OperationName: req.OperationName,
+		Args:          req.Variables,
+		Context:       withLoaders(ctx, e.resolvers.newLoaders()),
+	})
+}
+
+// operation picks the operation to run, as the executor will.
+func operation(doc *ast.Document, name string) (*ast.OperationDefinition, error) {
+	var found *ast.OperationDefinition
+	for _, def := range doc.Definitions {
+		op, ok := def.(*ast.OperationDefinition)
+		if !ok {
+			continue
+		}
+		if name == "" {
+			if found != nil {
+				return nil, errors.New("must provide operation name if query contains multiple operations")
+			}
+			found = op
+		} else if op.Name != nil && op.Name.Value == name {
+			found = op
+		}
+	}
+	if found == nil {
+		if name != "" {
+			return nil, fmt.Errorf("unknown operation named %q", name)
+		}
+		return nil, errors.New("must provide an operation")
+	}
+	return found, nil
+}
+
+// loaders are created per request so cached results never outlive it.
+type loaders struct {
+	posts       *loader[int, models.Post]
+	postCounts  *loader[string, int]
+	authorPages *loader[authorPage, models.PaginatedPosts]
+}
+
+// authorPage identifies one Author.posts page. Pages with the same query
+// are fetched together.
+type authorPage struct {
+	author string
+	query  models.PostQuery
+}
+
+type loadersKey struct{}
+
+func withLoaders(ctx context.Context, l *loaders) context.Context {
+	return context.WithValue(ctx, loadersKey{}, l)
+}
+
+func loadersFrom(ctx context.Context) *loaders {
+	return ctx.Value(loadersKey{}).(*loaders)
+}
+
+func (r *resolvers) newLoaders() *loaders {
+	return &loaders{
+		posts:       newLoader(r.fetchPosts),
+		postCounts:  newLoader(r.countPosts),
+		authorPages: newLoader(r.fetchAuthorPages),
+	}
+}
+
+// fetchPosts reads posts in one query if the store supports it, and one by
+// one otherwise.
+func (r *resolvers) fetchPosts(ctx context.Context, ids []int) (map[int]models.Post, error) {
+	found := make(map[int]models.Post, len(ids))
+
+	if reader, ok := r.store.(storage.PostBatchReader); ok {
+		posts, err := reader.GetByIDs(ctx, ids)
+		if err == nil {
+			for _, post := range posts {
+				found[post.ID] = post
+			}
+			return found, nil
+		}
+		if !errors.Is(err, storage.ErrUnsupported) {
+			return nil, err
+		}
+	}
+
+	for _, id := range ids {
+		post, err := r.store.GetByID(ctx, id)
+		if err != nil {
+			return nil, err
+		}
+		if post != nil {
+			found[id] = *post
+		}
+	}
+	return found, nil
+}
+
+// countPosts counts the posts of every author in one query if the store
+// supports it, and author by author otherwise.
+func (r *resolvers) countPosts(ctx context.Context, authors []string) (map[string]int, error) {
+	if reader, ok := r.store.(storage.AuthorBatchReader); ok {
+		counts, err := reader.CountByAuthor(ctx, authors)
+		if !errors.Is(err, storage.ErrUnsupported) {
+			return counts, err
+		}
+	}
+
+	counter, ok := r.store.(storage.PostCounter)
+	if !ok {
+		return nil, storage.ErrUnsupported
+	}
+	counts := make(map[string]int, len(authors))
+	for _, author := range authors {
+		n, err := counter.CountPosts(ctx, models.PostQuery{Author: author})
+		if err != nil {
+			return nil, err
+		}
+		counts[author] = n
+	}
+	return counts, nil
+}
+
+// fetchAuthorPages fetches the pages of every author that share a query in
+// one call if the store supports it, and author by author otherwise.
+func (r *resolvers) fetchAuthorPages(ctx context.Context, keys []authorPage) (map[authorPage]models.PaginatedPosts, error) {
+	byQuery := map[models.PostQuery][]string{}
+	for _, key := range keys {
+		byQuery[key.query] = append(byQuery[key.query], key.author)
+	}
+
+	found := make(map[authorPage]models.PaginatedPosts, len(keys))
+	for query, authors := range byQuery {
+		pages, err := r.authorPages(ctx, authors, query)
+		if err != nil {
+			return nil, err
+		}
+		for author, page := range pages {
+			found[authorPage{author: author, query: query}] = *page
+		}
+	}
+	return found, nil
+}
+
+func (r *resolvers) authorPages(ctx context.Context, authors []string, query models.PostQuery) (map[string]*models.PaginatedPosts, error) {
+	if reader, ok := r.store.(storage.AuthorBatchReader); ok {
+		pages, err := reader.GetAuthorPages(ctx, authors, query)
+		if !errors.Is(err, storage.ErrUnsupported) {
+			return pages, err
+		}
+	}
+
+	pages := make(map[string]*models.PaginatedPosts, len(authors))
+	for _, author := range authors {
+		query.Author = author
+		page, err := r.store.GetPostsPaginated(ctx, query)
+		if err != nil {
+			return nil, err
+		}
+		pages[author] = page
+	}
+	return pages, nil
+}
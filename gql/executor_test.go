@@ -0,0 +1,183 @@
+package gql
+
+import (
+	"blog-api/models"
+	"blog-api/storage"
+	"context"
+	"fmt"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/graphql-go/graphql/language/ast"
+	"github.com/graphql-go/graphql/language/parser"
+)
+
+// batchStore adds the batch reads to the in-memory store and counts calls,
+// the way a round trip to the database would be counted.
+type batchStore struct {
+	*storage.InMemoryPostStore
+	getByID, getByIDs, authorPages atomic.Int32
+}
+
+func (s *batchStore) GetByID(ctx context.Context, id int) (*models.Post, error) {
+	s.getByID.Add(1)
+	return s.InMemoryPostStore.GetByID(ctx, id)
+}
+
+func (s *batchStore) GetByIDs(ctx context.Context, ids []int) ([]models.Post, error) {
+	s.getByIDs.Add(1)
+	var posts []models.Post
+	for _, id := range ids {
+		if post, _ := s.InMemoryPostStore.GetByID(ctx, id); post != nil {
+			posts = append(posts, *post)
+		}
+	}
+	return posts, nil
+}
+
+func (s *batchStore) CountByAuthor(ctx context.Context, authors []string) (map[string]int, error) {
+	return nil, storage.ErrUnsupported
+}
+
+func (s *batchStore) GetAuthorPages(ctx context.Context, authors []string, query models.PostQuery) (map[string]*models.PaginatedPosts, error) {
+	s.authorPages.Add(1)
+	pages := make(map[string]*models.PaginatedPosts, len(authors))
+	for _, author := range authors {
+		query.Author = author
+		page, err := s.InMemoryPostStore.GetPostsPaginated(ctx, query)
+		if err != nil {
+			return nil, err
+		}
+		pages[author] = page
+	}
+	return pages, nil
+}
+
+func newTestExecutor(t *testing.T, opts Options) (*Executor, *batchStore) {
+	t.Helper()
+	store := &batchStore{InMemoryPostStore: storage.NewInMemoryPostStore()}
+	for i := 1; i <= 6; i++ {
+		_, err := store.Create(context.Background(), models.Post{
+			Title:   fmt.Sprintf("Post %d", i),
+			Content: "Body",
+			Author:  fmt.Sprintf("Author %d", i%3),
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	exec, err := NewExecutor(store, nil, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return exec, store
+}
+
+func TestMaxDepth(t *testing.T) {
+	exec, _ := newTestExecutor(t, Options{MaxDepth: 4})
+
+	// posts > nodes > author > name is depth 4
+	if res := exec.Execute(context.Background(), Request{Query: `{ posts { nodes { author { name } } } }`}, true); res.HasErrors() {
+		t.Errorf("query at the depth limit failed: %v", res.Errors)
+	}
+
+	res := exec.Execute(context.Background(), Request{Query: `{ posts { nodes { author { posts { nodes { id } } } } } }`}, true)
+	if !res.HasErrors() || !strings.Contains(res.Errors[0].Message, "depth exceeds the limit of 4") {
+		t.Fatalf("query past the depth limit: errors %v", res.Errors)
+	}
+	if res.Data != nil {
+		t.Errorf("rejected query returned data %v", res.Data)
+	}
+
+	// Fragments count at the depth they are spread at
+	res = exec.Execute(context.Background(), Request{Query: `
+		{ posts { nodes { ...deep } } }
+		fragment deep on Post { author { posts { nodes { id } } } }`}, true)
+	if !res.HasErrors() || !strings.Contains(res.Errors[0].Message, "depth exceeds") {
+		t.Errorf("query nested past the limit through a fragment: errors %v", res.Errors)
+	}
+}
+
+func TestComplexity(t *testing.T) {
+	cost := func(query string, variables map[string]interface{}) int {
+		t.Helper()
+		doc, err := parser.Parse(parser.ParseParams{Source: query})
+		if err != nil {
+			t.Fatal(err)
+		}
+		l := limits{maxComplexity: 1}
+		err = l.check(doc, doc.Definitions[0].(*ast.OperationDefinition), variables)
+		var n int
+		if _, scanErr := fmt.Sscanf(fmt.Sprint(err), "query complexity %d", &n); scanErr != nil {
+			t.Fatalf("check(%s) = %v, want a complexity error", query, err)
+		}
+		return n
+	}
+
+	// posts + nodes + 2 fields, the nodes counted once per item
+	tests := []struct {
+		query     string
+		variables map[string]interface{}
+		want      int
+	}{
+		{`{ posts(first: 10) { nodes { id title } } }`, nil, 1 + 10*(1+2)},
+		{`{ posts(first: 20) { nodes { id title } } }`, nil, 1 + 20*(1+2)},
+		{`query($n: Int) { posts(first: $n) { nodes { id title } } }`, map[string]interface{}{"n": float64(5)}, 1 + 5*(1+2)},
+		{`{ posts { nodes { id title } } }`, nil, 1 + models.DefaultPostQuery().Limit*(1+2)},
+		// Nested pages multiply
+		{`{ posts(first: 10) { nodes { author { posts(first: 3) { nodes { id } } } } } }`, nil, 1 + 10*(1+(1+(1+3*(1+1))))},
+		// Introspection is free
+		{`{ __typename posts(first: 1) { nodes { id } } }`, nil, 1 + 1*(1+1)},
+	}
+	for _, tt := range tests {
+		if got := cost(tt.query, tt.variables); got != tt.want {
+			t.Errorf("complexity of %s = %d, want %d", tt.query, got, tt.want)
+		}
+	}
+
+	exec, _ := newTestExecutor(t, Options{MaxComplexity: 100})
+	if res := exec.Execute(context.Background(), Request{Query: `{ posts(first: 30) { nodes { id title } } }`}, true); res.HasErrors() {
+		t.Errorf("query within the limit failed: %v", res.Errors)
+	}
+	res := exec.Execute(context.Background(), Request{Query: `{ posts(first: 40) { nodes { id title } } }`}, true)
+	if !res.HasErrors() || !strings.Contains(res.Errors[0].Message, "complexity 121 exceeds the limit of 100") {
+		t.Errorf("query over the limit: errors %v", res.Errors)
+	}
+}
+
+func TestLoadersBatch(t *testing.T) {
+	exec, store := newTestExecutor(t, Options{})
+
+	res := exec.Execute(context.Background(), Request{Query: `{
+		posts(first: 6) { nodes { title author { name posts(first: 2) { nodes { id } } } } }
+	}`}, true)
+	if res.HasErrors() {
+		t.Fatal(res.Errors)
+	}
+	if n := store.authorPages.Load(); n != 1 {
+		t.Errorf("6 author { posts } fields made %d GetAuthorPages calls, want 1", n)
+	}
+
+	res = exec.Execute(context.Background(), Request{Query: `{
+		a: post(id: "1") { title }
+		b: post(id: "2") { title }
+		c: post(id: "3") { title }
+		d: post(id: "1") { title }
+	}`}, true)
+	if res.HasErrors() {
+		t.Fatal(res.Errors)
+	}
+	if n := store.getByIDs.Load(); n != 1 {
+		t.Errorf("4 post fields made %d GetByIDs calls, want 1", n)
+	}
+	if n := store.getByID.Load(); n != 0 {
+		t.Errorf("post fields made %d GetByID calls, want none", n)
+	}
+	data := fmt.Sprint(res.Data)
+	for _, want := range []string{"a:map[title:Post 1]", "c:map[title:Post 3]", "d:map[title:Post 1]"} {
+		if !strings.Contains(data, want) {
+			t.Errorf("data %s lacks %s", data, want)
+		}
+	}
+}
@@ -0,0 +1,109 @@
+package gql
+
+import (
+	"blog-api/models"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/graphql-go/graphql/language/ast"
+)
+
+// limits measures an operation before it runs. Depth counts nested fields;
+// complexity counts one per field, with the fields under a paginated field
+// counted once per requested item. Introspection fields are not counted.
+type limits struct {
+	maxDepth      int
+	maxComplexity int
+
+	fragments map[string]*ast.FragmentDefinition
+	variables map[string]interface{}
+}
+
+func (l *limits) check(doc *ast.Document, op *ast.OperationDefinition, variables map[string]interface{}) error {
+	l.fragments = make(map[string]*ast.FragmentDefinition)
+	for _, def := range doc.Definitions {
+		if frag, ok := def.(*ast.FragmentDefinition); ok {
+			l.fragments[frag.Name.Value] = frag
+		}
+	}
+	l.variables = variables
+
+	cost, err := l.selectionSet(op.SelectionSet, 1)
+	if err != nil {
+		return err
+	}
+	if l.maxComplexity > 0 && cost > l.maxComplexity {
+		return fmt.Errorf("query complexity %d exceeds the limit of %d", cost, l.maxComplexity)
+	}
+	return nil
+}
+
+// selectionSet returns the cost of set, whose fields are at depth. Fragment
+// cycles are rejected by validation before this runs.
+func (l *limits) selectionSet(set *ast.SelectionSet, depth int) (int, error) {
+	if set == nil {
+		return 0, nil
+	}
+
+	cost := 0
+	for _, sel := range set.Selections {
+		var c int
+		var err error
+
+		switch sel := sel.(type) {
+		case *ast.Field:
+			if strings.HasPrefix(sel.Name.Value, "__") {
+				continue
+			}
+			if l.maxDepth > 0 && depth > l.maxDepth {
+				return 0, fmt.Errorf("query depth exceeds the limit of %d", l.maxDepth)
+			}
+			c, err = l.selectionSet(sel.SelectionSet, depth+1)
+			c = 1 + c*l.multiplier(sel)
+		case *ast.InlineFragment:
+			c, err = l.selectionSet(sel.SelectionSet, depth)
+		case *ast.FragmentSpread:
+			if frag := l.fragments[sel.Name.Value]; frag != nil {
+				c, err = l.selectionSet(frag.SelectionSet, depth)
+			}
+		}
+		if err != nil {
+			return 0, err
+		}
+		cost += c
+	}
+	return cost, nil
+}
+
+// multiplier is the number of items a paginated field can return: its first
+// argument, or the default page size.
+func (l *limits) multiplier(field *ast.Field) int {
+	if field.Name.Value != "posts" {
+		return 1
+	}
+
+	for _, arg := range field.Arguments {
+		if arg.Name.Value != "first" {
+			continue
+		}
+		switch v := arg.Value.(type) {
+		case *ast.IntValue:
+			if n, err := strconv.Atoi(v.Value); err == nil && n > 0 {
+				return n
+			}
+		case *ast.Variable:
+			switch n := l.variables[v.Name.Value].(type) {
+			case float64:
+				if n > 0 {
+					return int(n)
+				}
+			case int:
+				if n > 0 {
+					return n
+				}
+			}
+		}
+	}
+	return models.DefaultPostQuery().Limit
+}
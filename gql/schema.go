@@ -0,0 +1,459 @@
+// Package gql serves posts over GraphQL. Posts in this API have an author
+// name but no tags or comments, so the schema has no fields for those.
+package gql
+
+import (
+	"blog-api/models"
+	"blog-api/render"
+	"blog-api/storage"
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"strconv"
+
+	"github.com/graphql-go/graphql"
+)
+
+// resolvers holds what the schema's resolve functions need.
+type resolvers struct {
+	store    storage.PostStore
+	renderer *render.Renderer
+}
+
+// connection is the value behind a PostConnection.
+type connection struct {
+	page  *models.PaginatedPosts
+	after string
+}
+
+type edge struct {
+	cursor string
+	post   models.Post
+}
+
+func newSchema(r *resolvers) (graphql.Schema, error) {
+	contentFormat := graphql.NewEnum(graphql.EnumConfig{
+		Name: "ContentFormat",
+		Values: graphql.EnumValueConfigMap{
+			"MARKDOWN": {Value: render.FormatMarkdown, Description: "The stored Markdown source."},
+			"HTML":     {Value: render.FormatHTML, Description: "Sanitized HTML."},
+			"TEXT":     {Value: render.FormatText, Description: "Plain text with the markup removed."},
+		},
+	})
+
+	pageInfo := graphql.NewObject(graphql.ObjectConfig{
+		Name: "PageInfo",
+		Fields: graphql.Fields{
+			"hasNextPage": {Type: graphql.NewNonNull(graphql.Boolean), Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+				return p.Source.(connection).page.HasMore, nil
+			}},
+			"hasPreviousPage": {Type: graphql.NewNonNull(graphql.Boolean), Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+				return p.Source.(connection).after != "", nil
+			}},
+			"startCursor": {Type: graphql.String, Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+				posts := p.Source.(connection).page.Posts
+				if len(posts) == 0 {
+					return nil, nil
+				}
+				return postCursor(posts[0]), nil
+			}},
+			"endCursor": {Type: graphql.String, Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+				posts := p.Source.(connection).page.Posts
+				if len(posts) == 0 {
+					return nil, nil
+				}
+				return postCursor(posts[len(posts)-1]), nil
+			}},
+		},
+	})
+
+	// Post, Author and the connection types refer to each other, so their
+	// fields are declared lazily.
+	var post, author, postConnection *graphql.Object
+
+	postsArgs := graphql.FieldConfigArgument{
+		"first": {Type: graphql.Int, Description: "Page size, 1 to 100.", DefaultValue: models.DefaultPostQuery().Limit},
+		"after": {Type: graphql.String, Description: "endCursor of the previous page."},
+	}
+
+	post = graphql.NewObject(graphql.ObjectConfig{
+		Name: "Post",
+		Fields: graphql.FieldsThunk(func() graphql.Fields {
+			return graphql.Fields{
+				"id": {Type: graphql.NewNonNull(graphql.ID), Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					return strconv.Itoa(p.Source.(models.Post).ID), nil
+				}},
+				"title": {Type: graphql.NewNonNull(graphql.String), Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					return p.Source.(models.Post).Title, nil
+				}},
+				"content": {
+					Type: graphql.NewNonNull(graphql.String),
+					Args: graphql.FieldConfigArgument{
+						"format": {Type: contentFormat, DefaultValue: render.FormatMarkdown},
+					},
+					Resolve: r.content,
+				},
+				"slug": {Type: graphql.NewNonNull(graphql.String), Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					return p.Source.(models.Post).Slug, nil
+				}},
+				"author": {Type: graphql.NewNonNull(author), Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					return p.Source.(models.Post).Author, nil
+				}},
+				"createdAt": {Type: graphql.NewNonNull(graphql.DateTime), Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					return p.Source.(models.Post).CreatedAt, nil
+				}},
+				"updatedAt": {Type: graphql.NewNonNull(graphql.DateTime), Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					return p.Source.(models.Post).UpdatedAt, nil
+				}},
+			}
+		}),
+	})
+
+	author = graphql.NewObject(graphql.ObjectConfig{
+		Name: "Author",
+		Fields: graphql.FieldsThunk(func() graphql.Fields {
+			return graphql.Fields{
+				"name": {Type: graphql.NewNonNull(graphql.String), Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					return p.Source.(string), nil
+				}},
+				"postCount": {Type: graphql.NewNonNull(graphql.Int), Resolve: r.postCount},
+				"posts": {
+					Type:    graphql.NewNonNull(postConnection),
+					Args:    postsArgs,
+					Resolve: r.authorPosts,
+				},
+			}
+		}),
+	})
+
+	postEdge := graphql.NewObject(graphql.ObjectConfig{
+		Name: "PostEdge",
+		Fields: graphql.Fields{
+			"cursor": {Type: graphql.NewNonNull(graphql.String), Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+				return p.Source.(edge).cursor, nil
+			}},
+			"node": {Type: graphql.NewNonNull(post), Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+				return p.Source.(edge).post, nil
+			}},
+		},
+	})
+
+	postConnection = graphql.NewObject(graphql.ObjectConfig{
+		Name: "PostConnection",
+		Fields: graphql.Fields{
+			"edges": {Type: graphql.NewNonNull(graphql.NewList(graphql.NewNonNull(postEdge))), Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+				posts := p.Source.(connection).page.Posts
+				edges := make([]edge, len(posts))
+				for i, post := range posts {
+					edges[i] = edge{cursor: postCursor(post), post: post}
+				}
+				return edges, nil
+			}},
+			"nodes": {Type: graphql.NewNonNull(graphql.NewList(graphql.NewNonNull(post))), Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+				return p.Source.(connection).page.Posts, nil
+			}},
+			"pageInfo": {Type: graphql.NewNonNull(pageInfo), Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+				return p.Source, nil
+			}},
+		},
+	})
+
+	queryPostsArgs := graphql.FieldConfigArgument{
+		"author": {Type: graphql.String, Description: "Only posts by this author."},
+		"search": {Type: graphql.String, Description: "Only posts whose title or content contains this."},
+	}
+	for name, arg := range postsArgs {
+		queryPostsArgs[name] = arg
+	}
+
+	query := graphql.NewObject(graphql.ObjectConfig{
+		Name: "Query",
+		Fields: graphql.Fields{
+			"posts": {
+				Type:    graphql.NewNonNull(postConnection),
+				Args:    queryPostsArgs,
+				Resolve: r.posts,
+			},
+			"post": {
+				Type:        post,
+				Description: "A post by id or slug. Old slugs resolve to the post they used to name.",
+				Args: graphql.FieldConfigArgument{
+					"id":   {Type: graphql.ID},
+					"slug": {Type: graphql.String},
+				},
+				Resolve: r.post,
+			},
+		},
+	})
+
+	createInput := graphql.NewInputObject(graphql.InputObjectConfig{
+		Name: "CreatePostInput",
+		Fields: graphql.InputObjectConfigFieldMap{
+			"title":   {Type: graphql.NewNonNull(graphql.String)},
+			"content": {Type: graphql.NewNonNull(graphql.String)},
+			"author":  {Type: graphql.NewNonNull(graphql.String)},
+			"slug":    {Type: graphql.String, Description: "Generated from the title when omitted."},
+		},
+	})
+
+	updateInput := graphql.NewInputObject(graphql.InputObjectConfig{
+		Name: "UpdatePostInput",
+		Fields: graphql.InputObjectConfigFieldMap{
+			"title":   {Type: graphql.String},
+			"content": {Type: graphql.String},
+			"author":  {Type: graphql.String},
+			"slug":    {Type: graphql.String, Description: "The old slug keeps redirecting to the post."},
+		},
+	})
+
+	mutation := graphql.NewObject(graphql.ObjectConfig{
+		Name: "Mutation",
+		Fields: graphql.Fields{
+			"createPost": {
+				Type:    graphql.NewNonNull(post),
+				Args:    graphql.FieldConfigArgument{"input": {Type: graphql.NewNonNull(createInput)}},
+				Resolve: r.createPost,
+			},
+			"updatePost": {
+				Type:        post,
+				Description: "Returns null if the post does not exist.",
+				Args: graphql.FieldConfigArgument{
+					"id":    {Type: graphql.NewNonNull(graphql.ID)},
+					"input": {Type: graphql.NewNonNull(updateInput)},
+				},
+				Resolve: r.updatePost,
+			},
+			"deletePost": {
+				Type:        graphql.NewNonNull(graphql.Boolean),
+				Description: "Moves the post to the trash. Returns false if it does not exist.",
+				Args:        graphql.FieldConfigArgument{"id": {Type: graphql.NewNonNull(graphql.ID)}},
+				Resolve:     r.deletePost,
+			},
+		},
+	})
+
+	return graphql.NewSchema(graphql.SchemaConfig{Query: query, Mutation: mutation})
+}
+
+func postCursor(post models.Post) string {
+	return models.NewCursor(post, "created_at").Encode()
+}
+
+func parseID(v interface{}) (int, error) {
+	s, _ := v.(string)
+	id, err := strconv.Atoi(s)
+	if err != nil {
+		return 0, fmt.Errorf("invalid post id %q", s)
+	}
+	return id, nil
+}
+
+func stringArg(args map[string]interface{}, name string) string {
+	s, _ := args[name].(string)
+	return s
+}
+
+func (r *resolvers) content(p graphql.ResolveParams) (interface{}, error) {
+	post := p.Source.(models.Post)
+	format, _ := p.Args["format"].(string)
+	if format == "" || format == render.FormatMarkdown {
+		return post.Content, nil
+	}
+
+	out, err := r.renderer.Render(post)
+	if err != nil {
+		return nil, internalError(err)
+	}
+	if format == render.FormatHTML {
+		return out.HTML, nil
+	}
+	return out.Text, nil
+}
+
+// pageQuery builds the query for one page of a connection field.
+func pageQuery(args map[string]interface{}) (models.PostQuery, error) {
+	query := models.DefaultPostQuery()
+	if first, ok := args["first"].(int); ok {
+		query.Limit = first
+	}
+	query.Cursor = stringArg(args, "after")
+	query.Search = stringArg(args, "search")
+
+	// Validate checks the after cursor too
+	err := query.Validate()
+	return query, err
+}
+
+func (r *resolvers) posts(p graphql.ResolveParams) (interface{}, error) {
+	query, err := pageQuery(p.Args)
+	if err != nil {
+		return nil, err
+	}
+	query.Author = stringArg(p.Args, "author")
+
+	page, err := r.store.GetPostsPaginated(p.Context, query)
+	if err != nil {
+		return nil, internalError(err)
+	}
+	return connection{page: page, after: query.Cursor}, nil
+}
+
+// authorPosts batches the pages of every author in the response, so a
+// list of posts with their authors' posts costs one query, not one per
+// author.
+func (r *resolvers) authorPosts(p graphql.ResolveParams) (interface{}, error) {
+	query, err := pageQuery(p.Args)
+	if err != nil {
+		return nil, err
+	}
+
+	load := loadersFrom(p.Context).authorPages.Load(p.Context, authorPage{author: p.Source.(string), query: query})
+	return func() (interface{}, error) {
+		page, _, err := load()
+		if err != nil {
+			return nil, internalError(err)
+		}
+		return connection{page: &page, after: query.Cursor}, nil
+	}, nil
+}
+
+func (r *resolvers) postCount(p graphql.ResolveParams) (interface{}, error) {
+	load := loadersFrom(p.Context).postCounts.Load(p.Context, p.Source.(string))
+	return func() (interface{}, error) {
+		n, _, err := load()
+		if err != nil {
+			return nil, internalError(err)
+		}
+		return n, nil
+	}, nil
+}
+
+func (r *resolvers) post(p graphql.ResolveParams) (interface{}, error) {
+	idArg, hasID := p.Args["id"]
+	slug, hasSlug := p.Args["slug"].(string)
+	if hasID == hasSlug {
+		return nil, errors.New("pass exactly one of id and slug")
+	}
+
+	if hasSlug {
+		post, err := r.store.GetBySlug(p.Context, slug)
+		if err != nil {
+			return nil, internalError(err)
+		}
+		if post == nil {
+			return nil, nil
+		}
+		return *post, nil
+	}
+
+	id, err := parseID(idArg)
+	if err != nil {
+		return nil, err
+	}
+	load := loadersFrom(p.Context).posts.Load(p.Context, id)
+	return func() (interface{}, error) {
+		post, found, err := load()
+		if err != nil {
+			return nil, internalError(err)
+		}
+		if !found {
+			return nil, nil
+		}
+		return post, nil
+	}, nil
+}
+
+func (r *resolvers) createPost(p graphql.ResolveParams) (interface{}, error) {
+	input, _ := p.Args["input"].(map[string]interface{})
+	req := models.CreatePostRequest{
+		Title:   stringArg(input, "title"),
+		Content: stringArg(input, "content"),
+		Author:  stringArg(input, "author"),
+		Slug:    stringArg(input, "slug"),
+	}
+	if err := req.Validate(); err != nil {
+		return nil, err
+	}
+
+	created, err := r.store.Create(p.Context, req.ToPost())
+	if err != nil {
+		return nil, internalError(err)
+	}
+	return *created, nil
+}
+
+func (r *resolvers) updatePost(p graphql.ResolveParams) (interface{}, error) {
+	id, err := parseID(p.Args["id"])
+	if err != nil {
+		return nil, err
+	}
+
+	input, _ := p.Args["input"].(map[string]interface{})
+	req := models.UpdatePostRequest{
+		Title:   stringArg(input, "title"),
+		Content: stringArg(input, "content"),
+		Author:  stringArg(input, "author"),
+		Slug:    stringArg(input, "slug"),
+	}
+	if err := req.Validate(); err != nil {
+		return nil, err
+	}
+
+	updated, err := r.store.Update(p.Context, id, models.Post{
+		Title:   req.Title,
+		Content: req.Content,
+		Author:  req.Author,
+		Slug:    req.Slug,
+	})
+	if err != nil {
+		return nil, internalError(err)
+	}
+	if updated == nil {
+		return nil, nil
+	}
+	return *updated, nil
+}
+
+func (r *resolvers) deletePost(p graphql.ResolveParams) (interface{}, error) {
+	id, err := parseID(p.Args["id"])
+	if err != nil {
+		return nil, err
+	}
+
+	removed, err := r.remove(p.Context, id)
+	if err != nil {
+		return nil, internalError(err)
+	}
+	return removed, nil
+}
+
+// remove deletes a post and reports whether it existed. Stores that cannot
+// tell are asked first; the answer may then be stale.
+func (r *resolvers) remove(ctx context.Context, id int) (bool, error) {
+	if remover, ok := r.store.(storage.PostRemover); ok {
+		removed, err := remover.Remove(ctx, id)
+		if !errors.Is(err, storage.ErrUnsupported) {
+			return removed, err
+		}
+	}
+
+	existing, err := r.store.GetByID(ctx, id)
+	if err != nil || existing == nil {
+		return false, err
+	}
+	return true, r.store.Delete(ctx, id)
+}
+
+// internalError hides store and rendering failures, which can carry SQL or
+// driver details, behind a generic message and logs them instead. Errors
+// the client can act on pass through.
+func internalError(err error) error {
+	if errors.Is(err, storage.ErrSlugTaken) {
+		return err
+	}
+	log.Printf("GraphQL: %v", err)
+	return errInternal
+}
+
+var errInternal = errors.New("internal error")
@@ -0,0 +1,78 @@
+package handlers
+
+import (
+	"blog-api/models"
+	"blog-api/storage"
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+const (
+	defaultChangesLimit = 100
+	maxChangesLimit     = 1000
+)
+
+// ChangeFeedHandler serves incremental post changes for syncing clients.
+type ChangeFeedHandler struct {
+	store     storage.PostStore
+	retention time.Duration
+}
+
+// NewChangeFeedHandler creates the handler. retention is how long
+// tombstones are kept; older tokens are refused.
+func NewChangeFeedHandler(store storage.PostStore, retention time.Duration) *ChangeFeedHandler {
+	return &ChangeFeedHandler{store: store, retention: retention}
+}
+
+// GetChanges handles GET /posts/changes?since=<token>. Without a token it
+// starts from the beginning: every current post plus recent tombstones.
+// Created and updated entries carry the post as it is now. Pass next_token
+// back as since to continue; has_more means another request will return
+// more right away. A token older than the tombstone retention gets 410 Gone
+// and the client must sync from scratch.
+func (h *ChangeFeedHandler) GetChanges(w http.ResponseWriter, r *http.Request) {
+	qp := r.URL.Query()
+
+	var since models.ChangeToken
+	if token := qp.Get("since"); token != "" {
+		var err error
+		if since, err = models.DecodeChangeToken(token); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if h.retention > 0 && time.Since(since.IssuedAt) > h.retention {
+			http.Error(w, "change token has expired; sync again without since", http.StatusGone)
+			return
+		}
+	}
+
+	limit := defaultChangesLimit
+	if v := qp.Get("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 || n > maxChangesLimit {
+			http.Error(w, "limit must be between 1 and 1000", http.StatusBadRequest)
+			return
+		}
+		limit = n
+	}
+
+	feed, ok := h.store.(storage.PostChangeFeed)
+	if !ok {
+		http.Error(w, storage.ErrUnsupported.Error(), http.StatusNotImplemented)
+		return
+	}
+
+	set, err := feed.PostChanges(r.Context(), since, limit)
+	if errors.Is(err, storage.ErrUnsupported) {
+		http.Error(w, err.Error(), http.StatusNotImplemented)
+		return
+	}
+	if err != nil {
+		http.Error(w, "internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	writeJSON(w, r, http.StatusOK, set)
+}
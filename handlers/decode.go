@@ -0,0 +1,88 @@
+package handlers
+
+import (
+	"blog-api/models"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+)
+
+// maxJSONBody is the largest JSON request body the API accepts.
+const maxJSONBody = 1 << 20
+
+// decodeJSON reads a single JSON value from the request body into dst,
+// rejecting unknown fields, trailing data and bodies over maxJSONBody. On
+// failure it writes the error response and returns false.
+func decodeJSON(w http.ResponseWriter, r *http.Request, dst interface{}) bool {
+	dec := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxJSONBody))
+	dec.DisallowUnknownFields()
+
+	err := dec.Decode(dst)
+	if err == nil && dec.Decode(&struct{}{}) != io.EOF {
+		err = errors.New("Request body must contain a single JSON value")
+	}
+	if err == nil {
+		return true
+	}
+
+	var maxErr *http.MaxBytesError
+	var syntaxErr *json.SyntaxError
+	var typeErr *json.UnmarshalTypeError
+	switch {
+	case errors.As(err, &maxErr):
+		http.Error(w, fmt.Sprintf("Request body must not exceed %d bytes", maxErr.Limit), http.StatusRequestEntityTooLarge)
+	case errors.As(err, &syntaxErr):
+		http.Error(w, fmt.Sprintf("Request body is not valid JSON (at byte %d)", syntaxErr.Offset), http.StatusBadRequest)
+	case errors.Is(err, io.ErrUnexpectedEOF):
+		http.Error(w, "Request body is not valid JSON", http.StatusBadRequest)
+	case errors.Is(err, io.EOF):
+		http.Error(w, "Request body is required", http.StatusBadRequest)
+	case errors.As(err, &typeErr) && typeErr.Field != "":
+		writeValidationError(w, r, &models.ValidationError{Fields: []models.FieldError{
+			{Field: typeErr.Field, Message: "must be " + jsonTypeName(typeErr.Type.Kind().String())},
+		}})
+	case strings.HasPrefix(err.Error(), "json: unknown field "):
+		field := strings.Trim(strings.TrimPrefix(err.Error(), "json: unknown field "), `"`)
+		writeValidationError(w, r, &models.ValidationError{Fields: []models.FieldError{
+			{Field: field, Message: "is not a known field"},
+		}})
+	default:
+		http.Error(w, err.Error(), http.StatusBadRequest)
+	}
+	return false
+}
+
+// jsonTypeName names a Go kind the way a JSON client would know it.
+func jsonTypeName(kind string) string {
+	switch kind {
+	case "string":
+		return "a string"
+	case "bool":
+		return "a boolean"
+	case "slice", "array":
+		return "an array"
+	case "map", "struct":
+		return "an object"
+	}
+	if strings.HasPrefix(kind, "int") || strings.HasPrefix(kind, "uint") || strings.HasPrefix(kind, "float") {
+		return "a number"
+	}
+	return "a " + kind
+}
+
+// writeValidationError responds 400 with per-field errors when err is a
+// *models.ValidationError, and with its plain message otherwise.
+func writeValidationError(w http.ResponseWriter, r *http.Request, err error) {
+	var verr *models.ValidationError
+	if !errors.As(err, &verr) {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	writeJSON(w, r, http.StatusBadRequest, struct {
+		Error  string              `json:"error"`
+		Fields []models.FieldError `json:"fields"`
+	}{"Validation failed", verr.Fields})
+}
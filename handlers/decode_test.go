@@ -0,0 +1,47 @@
+package handlers
+
+import (
+	"blog-api/models"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestDecodeJSON(t *testing.T) {
+	tests := []struct {
+		name   string
+		body   string
+		ok     bool
+		status int
+		want   string // substring of the response body
+	}{
+		{"valid", `{"title":"T","content":"C","author":"A"}`, true, http.StatusOK, ""},
+		{"trailing whitespace", `{"title":"T"}` + "\n\n", true, http.StatusOK, ""},
+		{"unknown field", `{"title":"T","tags":["x"]}`, false, http.StatusBadRequest, `{"field":"tags","message":"is not a known field"}`},
+		{"wrong type", `{"title":5}`, false, http.StatusBadRequest, `{"field":"title","message":"must be a string"}`},
+		{"trailing data", `{"title":"T"} {"title":"U"}`, false, http.StatusBadRequest, "single JSON value"},
+		{"trailing garbage", `{"title":"T"}x`, false, http.StatusBadRequest, "single JSON value"},
+		{"syntax error", `{"title":}`, false, http.StatusBadRequest, "not valid JSON (at byte"},
+		{"truncated", `{"title":"T"`, false, http.StatusBadRequest, "not valid JSON"},
+		{"empty", ``, false, http.StatusBadRequest, "Request body is required"},
+		{"too large", `{"content":"` + strings.Repeat("x", maxJSONBody) + `"}`, false, http.StatusRequestEntityTooLarge, "must not exceed 1048576 bytes"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			r := httptest.NewRequest(http.MethodPost, "/api/v1/posts", strings.NewReader(tt.body))
+
+			var req models.CreatePostRequest
+			if ok := decodeJSON(w, r, &req); ok != tt.ok {
+				t.Fatalf("decodeJSON = %v, want %v (response %d %s)", ok, tt.ok, w.Code, w.Body)
+			}
+			if w.Code != tt.status {
+				t.Errorf("status = %d, want %d", w.Code, tt.status)
+			}
+			if !strings.Contains(w.Body.String(), tt.want) {
+				t.Errorf("response %q does not contain %q", w.Body, tt.want)
+			}
+		})
+	}
+}
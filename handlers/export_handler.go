@@ -0,0 +1,57 @@
+package handlers
+
+import (
+	"blog-api/portability"
+	"blog-api/storage"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+)
+
+const exportTimeout = 30 * time.Minute
+
+// ExportPosts handles GET /posts:export. It streams every post matching the
+// usual author, search and sort parameters as JSONL (default), CSV or a
+// tar.gz of Markdown files with YAML front matter (?format=markdown).
+func (h *PostHandler) ExportPosts(w http.ResponseWriter, r *http.Request) {
+	query, err := parsePostQuery(r.URL.Query())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err := query.Validate(); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	name := r.URL.Query().Get("format")
+	if name == "" {
+		name = "jsonl"
+	}
+	format, err := portability.LookupExportFormat(name)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	exporter, ok := h.store.(storage.PostExporter)
+	if !ok {
+		http.Error(w, storage.ErrUnsupported.Error(), http.StatusNotImplemented)
+		return
+	}
+
+	// A full export can take longer than the server's write timeout
+	http.NewResponseController(w).SetWriteDeadline(time.Now().Add(exportTimeout))
+
+	filename := fmt.Sprintf("posts-%s.%s", time.Now().UTC().Format("20060102T150405Z"), format.Extension)
+	w.Header().Set("Content-Type", format.ContentType)
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", filename))
+
+	_, err = portability.Export(r.Context(), exporter, query, format, w)
+	if err != nil && !errors.Is(err, r.Context().Err()) {
+		// Headers are already sent; the truncated body is all we can signal
+		log.Printf("Export failed: %v", err)
+	}
+}
@@ -0,0 +1,370 @@
+package handlers
+
+import (
+	"blog-api/models"
+	"blog-api/render"
+	"blog-api/storage"
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// FeedHandler serves the newest posts as RSS 2.0, Atom and JSON Feed.
+type FeedHandler struct {
+	store    storage.PostStore
+	renderer *render.Renderer
+	site     Site
+}
+
+func NewFeedHandler(store storage.PostStore, renderer *render.Renderer, site Site) *FeedHandler {
+	return &FeedHandler{store: store, renderer: renderer, site: site}
+}
+
+// feed is the format-independent content of a feed.
+type feed struct {
+	Title       string
+	Description string
+	HomeURL     string
+	SelfURL     string
+	Updated     time.Time
+	Items       []feedItem
+}
+
+type feedItem struct {
+	ID        string
+	URL       string
+	Title     string
+	Author    string
+	HTML      string
+	Text      string
+	Published time.Time
+	Updated   time.Time
+}
+
+// RSS handles GET /feeds/rss.xml.
+func (h *FeedHandler) RSS(w http.ResponseWriter, r *http.Request) {
+	h.serve(w, r, "application/rss+xml; charset=utf-8", encodeRSS)
+}
+
+// Atom handles GET /feeds/atom.xml.
+func (h *FeedHandler) Atom(w http.ResponseWriter, r *http.Request) {
+	h.serve(w, r, "application/atom+xml; charset=utf-8", encodeAtom)
+}
+
+// JSONFeed handles GET /feeds/feed.json.
+func (h *FeedHandler) JSONFeed(w http.ResponseWriter, r *http.Request) {
+	h.serve(w, r, "application/feed+json; charset=utf-8", encodeJSONFeed)
+}
+
+// serve builds the feed for the request's author, search and limit
+// parameters and writes it with ETag and Last-Modified validators, answering
+// conditional requests with 304.
+func (h *FeedHandler) serve(w http.ResponseWriter, r *http.Request, contentType string, encode func(*feed) ([]byte, error)) {
+	query, err := parsePostQuery(r.URL.Query())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	// Feeds always list the newest posts first
+	query.Cursor = ""
+	query.SortBy = "created_at"
+	query.SortDir = "desc"
+	if err := query.Validate(); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	page, err := h.store.GetPostsPaginated(r.Context(), query)
+	if err != nil {
+		http.Error(w, "internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	f, err := h.build(r, query, page.Posts)
+	if err != nil {
+		http.Error(w, "internal server error", http.StatusInternalServerError)
+		return
+	}
+	modified, err := h.lastModified(r, f.Updated)
+	if err != nil {
+		http.Error(w, "internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	body, err := encode(f)
+	if err != nil {
+		http.Error(w, "internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	sum := sha256.Sum256(body)
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Set("ETag", `"`+hex.EncodeToString(sum[:16])+`"`)
+	w.Header().Set("Cache-Control", h.site.cacheControl(300))
+
+	// ServeContent handles If-None-Match and If-Modified-Since
+	http.ServeContent(w, r, "", modified, bytes.NewReader(body))
+}
+
+// lastModified is when the feed last changed: the newest update among its
+// posts or the newest deletion, which can change a feed without touching
+// any post left in it. Deletions are not filtered by author or search, so a
+// feed may look modified when it is not, never the reverse. Stores that
+// cannot tell when posts were deleted get no Last-Modified, leaving ETag to
+// validate.
+func (h *FeedHandler) lastModified(r *http.Request, updated time.Time) (time.Time, error) {
+	trash, ok := h.store.(storage.PostTrash)
+	if !ok {
+		return time.Time{}, nil
+	}
+	deleted, err := trash.LastDeleted(r.Context())
+	if errors.Is(err, storage.ErrUnsupported) {
+		return time.Time{}, nil
+	}
+	if err != nil {
+		return time.Time{}, err
+	}
+	if deleted.After(updated) {
+		return deleted.UTC(), nil
+	}
+	return updated, nil
+}
+
+func (h *FeedHandler) build(r *http.Request, query models.PostQuery, posts []models.Post) (*feed, error) {
+	base := h.site.baseURL(r)
+
+	f := &feed{
+		Title:       h.site.Title,
+		Description: h.site.Description,
+		HomeURL:     base + "/",
+		SelfURL:     absoluteURL(base, r),
+	}
+	switch {
+	case query.Author != "" && query.Search != "":
+		f.Title += " - posts by " + query.Author + " matching " + strconv.Quote(query.Search)
+	case query.Author != "":
+		f.Title += " - posts by " + query.Author
+	case query.Search != "":
+		f.Title += " - posts matching " + strconv.Quote(query.Search)
+	}
+
+	for _, post := range posts {
+		out, err := h.renderer.Render(post)
+		if err != nil {
+			return nil, err
+		}
+
+		f.Items = append(f.Items, feedItem{
+			ID:        postID(base, post),
+			URL:       h.site.postURL(base, post),
+			Title:     post.Title,
+			Author:    post.Author,
+			HTML:      out.HTML,
+			Text:      out.Text,
+			Published: post.CreatedAt.UTC(),
+			Updated:   post.UpdatedAt.UTC(),
+		})
+		if post.UpdatedAt.After(f.Updated) {
+			f.Updated = post.UpdatedAt.UTC()
+		}
+	}
+	return f, nil
+}
+
+// RSS 2.0
+
+type rssDocument struct {
+	XMLName xml.Name   `xml:"rss"`
+	Version string     `xml:"version,attr"`
+	AtomNS  string     `xml:"xmlns:atom,attr"`
+	DCNS    string     `xml:"xmlns:dc,attr"`
+	Channel rssChannel `xml:"channel"`
+}
+
+type rssChannel struct {
+	Title         string    `xml:"title"`
+	Link          string    `xml:"link"`
+	Description   string    `xml:"description"`
+	SelfLink      atomLink  `xml:"atom:link"`
+	LastBuildDate string    `xml:"lastBuildDate,omitempty"`
+	Items         []rssItem `xml:"item"`
+}
+
+type rssItem struct {
+	Title       string  `xml:"title"`
+	Link        string  `xml:"link"`
+	GUID        rssGUID `xml:"guid"`
+	Creator     string  `xml:"dc:creator"`
+	PubDate     string  `xml:"pubDate"`
+	Description string  `xml:"description"`
+}
+
+type rssGUID struct {
+	IsPermaLink bool   `xml:"isPermaLink,attr"`
+	Value       string `xml:",chardata"`
+}
+
+func encodeRSS(f *feed) ([]byte, error) {
+	doc := rssDocument{
+		Version: "2.0",
+		AtomNS:  "http://www.w3.org/2005/Atom",
+		DCNS:    "http://purl.org/dc/elements/1.1/",
+		Channel: rssChannel{
+			Title:       f.Title,
+			Link:        f.HomeURL,
+			Description: f.Description,
+			SelfLink:    atomLink{Href: f.SelfURL, Rel: "self", Type: "application/rss+xml"},
+		},
+	}
+	if !f.Updated.IsZero() {
+		doc.Channel.LastBuildDate = f.Updated.Format(time.RFC1123Z)
+	}
+	for _, item := range f.Items {
+		doc.Channel.Items = append(doc.Channel.Items, rssItem{
+			Title:       item.Title,
+			Link:        item.URL,
+			GUID:        rssGUID{Value: item.ID},
+			Creator:     item.Author,
+			PubDate:     item.Published.Format(time.RFC1123Z),
+			Description: item.HTML, // escaped by the encoder
+		})
+	}
+	return marshalXML(doc)
+}
+
+// Atom
+
+type atomFeed struct {
+	XMLName  xml.Name    `xml:"http://www.w3.org/2005/Atom feed"`
+	Title    string      `xml:"title"`
+	Subtitle string      `xml:"subtitle,omitempty"`
+	ID       string      `xml:"id"`
+	Updated  string      `xml:"updated"`
+	Links    []atomLink  `xml:"link"`
+	Entries  []atomEntry `xml:"entry"`
+}
+
+type atomLink struct {
+	Href string `xml:"href,attr"`
+	Rel  string `xml:"rel,attr,omitempty"`
+	Type string `xml:"type,attr,omitempty"`
+}
+
+type atomEntry struct {
+	Title     string      `xml:"title"`
+	ID        string      `xml:"id"`
+	Link      atomLink    `xml:"link"`
+	Published string      `xml:"published"`
+	Updated   string      `xml:"updated"`
+	Author    atomAuthor  `xml:"author"`
+	Content   atomContent `xml:"content"`
+}
+
+type atomAuthor struct {
+	Name string `xml:"name"`
+}
+
+type atomContent struct {
+	Type  string `xml:"type,attr"`
+	Value string `xml:",chardata"`
+}
+
+func encodeAtom(f *feed) ([]byte, error) {
+	// Atom requires an updated date even for an empty feed
+	updated := f.Updated
+	if updated.IsZero() {
+		updated = time.Unix(0, 0).UTC()
+	}
+
+	doc := atomFeed{
+		Title:    f.Title,
+		Subtitle: f.Description,
+		ID:       f.SelfURL,
+		Updated:  updated.Format(time.RFC3339),
+		Links: []atomLink{
+			{Href: f.SelfURL, Rel: "self", Type: "application/atom+xml"},
+			{Href: f.HomeURL, Rel: "alternate"},
+		},
+	}
+	for _, item := range f.Items {
+		doc.Entries = append(doc.Entries, atomEntry{
+			Title:     item.Title,
+			ID:        item.ID,
+			Link:      atomLink{Href: item.URL, Rel: "alternate"},
+			Published: item.Published.Format(time.RFC3339),
+			Updated:   item.Updated.Format(time.RFC3339),
+			Author:    atomAuthor{Name: item.Author},
+			Content:   atomContent{Type: "html", Value: item.HTML},
+		})
+	}
+	return marshalXML(doc)
+}
+
+func marshalXML(v interface{}) ([]byte, error) {
+	var buf bytes.Buffer
+	buf.WriteString(xml.Header)
+	enc := xml.NewEncoder(&buf)
+	enc.Indent("", "  ")
+	if err := enc.Encode(v); err != nil {
+		return nil, err
+	}
+	buf.WriteByte('\n')
+	return buf.Bytes(), nil
+}
+
+// JSON Feed 1.1
+
+type jsonFeed struct {
+	Version     string         `json:"version"`
+	Title       string         `json:"title"`
+	HomePageURL string         `json:"home_page_url"`
+	FeedURL     string         `json:"feed_url"`
+	Description string         `json:"description,omitempty"`
+	Items       []jsonFeedItem `json:"items"`
+}
+
+type jsonFeedItem struct {
+	ID            string           `json:"id"`
+	URL           string           `json:"url"`
+	Title         string           `json:"title"`
+	ContentHTML   string           `json:"content_html"`
+	ContentText   string           `json:"content_text,omitempty"`
+	DatePublished time.Time        `json:"date_published"`
+	DateModified  time.Time        `json:"date_modified"`
+	Authors       []jsonFeedAuthor `json:"authors"`
+}
+
+type jsonFeedAuthor struct {
+	Name string `json:"name"`
+}
+
+func encodeJSONFeed(f *feed) ([]byte, error) {
+	doc := jsonFeed{
+		Version:     "https://jsonfeed.org/version/1.1",
+		Title:       f.Title,
+		HomePageURL: f.HomeURL,
+		FeedURL:     f.SelfURL,
+		Description: f.Description,
+		Items:       []jsonFeedItem{},
+	}
+	for _, item := range f.Items {
+		doc.Items = append(doc.Items, jsonFeedItem{
+			ID:            item.ID,
+			URL:           item.URL,
+			Title:         item.Title,
+			ContentHTML:   item.HTML,
+			ContentText:   item.Text,
+			DatePublished: item.Published,
+			DateModified:  item.Updated,
+			Authors:       []jsonFeedAuthor{{Name: item.Author}},
+		})
+	}
+	return json.MarshalIndent(doc, "", "  ")
+}
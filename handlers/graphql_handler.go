@@ -0,0 +1,50 @@
+package handlers
+
+import (
+	"blog-api/gql"
+	"encoding/json"
+	"net/http"
+)
+
+// GraphQLHandler serves /graphql. Queries may be sent with GET or POST,
+// mutations only with POST.
+type GraphQLHandler struct {
+	executor *gql.Executor
+}
+
+func NewGraphQLHandler(executor *gql.Executor) *GraphQLHandler {
+	return &GraphQLHandler{executor: executor}
+}
+
+func (h *GraphQLHandler) ServeGraphQL(w http.ResponseWriter, r *http.Request) {
+	var req gql.Request
+	readOnly := r.Method == http.MethodGet
+
+	if readOnly {
+		qp := r.URL.Query()
+		req.Query = qp.Get("query")
+		req.OperationName = qp.Get("operationName")
+		if v := qp.Get("variables"); v != "" {
+			if err := json.Unmarshal([]byte(v), &req.Variables); err != nil {
+				http.Error(w, "variables must be a JSON object", http.StatusBadRequest)
+				return
+			}
+		}
+	} else if !decodeJSON(w, r, &req) {
+		return
+	}
+
+	if req.Query == "" {
+		http.Error(w, "query is required", http.StatusBadRequest)
+		return
+	}
+
+	result := h.executor.Execute(r.Context(), req, readOnly)
+
+	// Requests rejected before execution have no data
+	status := http.StatusOK
+	if result.Data == nil && len(result.Errors) > 0 {
+		status = http.StatusBadRequest
+	}
+	writeJSON(w, r, status, result)
+}
@@ -0,0 +1,85 @@
+package handlers
+
+import (
+	"blog-api/storage"
+	"context"
+	"fmt"
+	"net/http"
+	"sync/atomic"
+	"time"
+)
+
+type HealthHandler struct {
+	checker  storage.HealthChecker
+	timeout  time.Duration
+	draining atomic.Bool
+}
+
+func NewHealthHandler(checker storage.HealthChecker, timeout time.Duration) *HealthHandler {
+	return &HealthHandler{checker: checker, timeout: timeout}
+}
+
+// StartDraining makes readiness fail from now on so load balancers stop
+// routing new traffic before the server shuts down.
+func (h *HealthHandler) StartDraining() {
+	h.draining.Store(true)
+}
+
+// Livez reports whether the process is up. It never touches the database.
+func (h *HealthHandler) Livez(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, r, http.StatusOK, map[string]string{
+		"status":    "alive",
+		"timestamp": time.Now().Format(time.RFC3339),
+	})
+}
+
+// Readyz reports whether the service can take traffic: it is not shutting
+// down, the database answers a ping within the timeout and the schema is
+// migrated to the version this build expects.
+func (h *HealthHandler) Readyz(w http.ResponseWriter, r *http.Request) {
+	checks := map[string]string{}
+
+	if h.draining.Load() {
+		checks["server"] = "shutting down"
+		h.respond(w, r, checks, false)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), h.timeout)
+	defer cancel()
+
+	ready := true
+	if err := h.checker.Ping(ctx); err != nil {
+		checks["database"] = err.Error()
+		h.respond(w, r, checks, false)
+		return
+	}
+	checks["database"] = "ok"
+
+	version, err := h.checker.MigrationVersion(ctx)
+	switch {
+	case err != nil:
+		checks["migrations"] = err.Error()
+		ready = false
+	case version < storage.SchemaVersion:
+		checks["migrations"] = fmt.Sprintf("at version %d, want %d", version, storage.SchemaVersion)
+		ready = false
+	default:
+		checks["migrations"] = "ok"
+	}
+
+	h.respond(w, r, checks, ready)
+}
+
+func (h *HealthHandler) respond(w http.ResponseWriter, r *http.Request, checks map[string]string, ready bool) {
+	status, code := "ready", http.StatusOK
+	if !ready {
+		status, code = "unavailable", http.StatusServiceUnavailable
+	}
+
+	writeJSON(w, r, code, map[string]interface{}{
+		"status":    status,
+		"checks":    checks,
+		"timestamp": time.Now().Format(time.RFC3339),
+	})
+}
@@ -0,0 +1,92 @@
+package handlers
+
+import (
+	"blog-api/portability"
+	"blog-api/storage"
+	"errors"
+	"mime"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+const importTimeout = 10 * time.Minute
+
+// ImportPosts handles POST /posts:import. The body is NDJSON or CSV (chosen
+// by ?format= or Content-Type); each row is validated like CreatePost and
+// inserted in batches. ?dry_run=true only validates, ?atomic=true commits
+// nothing unless every row succeeds. An import that stops part way still
+// answers with the report, its error field saying why.
+func (h *PostHandler) ImportPosts(w http.ResponseWriter, r *http.Request) {
+	qp := r.URL.Query()
+
+	dryRun, err := parseBoolParam(qp.Get("dry_run"))
+	if err != nil {
+		http.Error(w, "invalid dry_run parameter", http.StatusBadRequest)
+		return
+	}
+	atomic, err := parseBoolParam(qp.Get("atomic"))
+	if err != nil {
+		http.Error(w, "invalid atomic parameter", http.StatusBadRequest)
+		return
+	}
+
+	format := qp.Get("format")
+	if format == "" {
+		format = importFormatFromContentType(r.Header.Get("Content-Type"))
+	}
+
+	// Large imports outlive the server's default read and write timeouts
+	rc := http.NewResponseController(w)
+	rc.SetReadDeadline(time.Now().Add(importTimeout))
+	rc.SetWriteDeadline(time.Now().Add(importTimeout))
+
+	report, err := portability.Import(r.Context(), h.store, r.Body, portability.ImportOptions{
+		Format: format,
+		DryRun: dryRun,
+		Atomic: atomic,
+	})
+	var inputErr *portability.InputError
+	switch {
+	case errors.Is(err, portability.ErrImportFormat):
+		http.Error(w, err.Error(), http.StatusUnsupportedMediaType)
+		return
+	case errors.Is(err, storage.ErrUnsupported):
+		http.Error(w, err.Error(), http.StatusNotImplemented)
+		return
+	case errors.As(err, &inputErr):
+		// Rows before the bad input may have been committed; say which
+		writeJSON(w, r, http.StatusBadRequest, report)
+		return
+	case err != nil:
+		writeJSON(w, r, http.StatusInternalServerError, report)
+		return
+	}
+
+	status := http.StatusOK
+	switch {
+	case report.Committed && report.Succeeded > 0:
+		status = http.StatusCreated
+	case atomic && !dryRun && report.Failed > 0:
+		status = http.StatusUnprocessableEntity
+	}
+	writeJSON(w, r, status, report)
+}
+
+func importFormatFromContentType(contentType string) string {
+	mediaType, _, _ := mime.ParseMediaType(contentType)
+	switch mediaType {
+	case "application/x-ndjson", "application/ndjson", "application/jsonl":
+		return "ndjson"
+	case "text/csv", "application/csv":
+		return "csv"
+	}
+	return ""
+}
+
+func parseBoolParam(value string) (bool, error) {
+	if value == "" {
+		return false, nil
+	}
+	return strconv.ParseBool(value)
+}
@@ -2,72 +2,59 @@ package handlers
 
 import (
 	"blog-api/models"
+	"blog-api/render"
 	"blog-api/storage"
 	"encoding/json"
+	"errors"
+	"fmt"
 	"net/http"
+	"net/url"
 	"strconv"
+	"strings"
 
 	"github.com/gorilla/mux"
+	"go.opentelemetry.io/otel"
 )
 
 type PostHandler struct {
-	store storage.PostStore
+	store    storage.PostStore
+	renderer *render.Renderer
 }
 
-func NewPostStoreHandler(store storage.PostStore) *PostHandler {
-	return &PostHandler{store: store}
+func NewPostStoreHandler(store storage.PostStore, renderer *render.Renderer) *PostHandler {
+	return &PostHandler{store: store, renderer: renderer}
 }
 
 func (h *PostHandler) GetPostsPaginated(w http.ResponseWriter, r *http.Request) {
-	qp := r.URL.Query()
-
-	query := models.DefaultPostQuery()
-
-	if cursor := qp.Get("cursor"); cursor != "" {
-		query.Cursor = cursor
-	}
-
-	if limitStr := qp.Get("limit"); limitStr != "" {
-		limit, err := strconv.Atoi(limitStr)
-		if err != nil {
-			http.Error(w, "invalid limit parameter", http.StatusBadRequest)
-			return
-		}
-		query.Limit = limit
-	}
-
-	if sortBy := qp.Get("sort_by"); sortBy != "" {
-		query.SortBy = sortBy
-	}
-
-	if sortDir := qp.Get("sort_dir"); sortDir != "" {
-		query.SortDir = sortDir
-	}
-
-	if author := qp.Get("author"); author != "" {
-		query.Author = author
+	query, err := parsePostQuery(r.URL.Query())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
 	}
 
-	if search := qp.Get("search"); search != "" {
-		query.Search = search
+	if err := query.Validate(); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
 	}
 
-	if err := query.Validate(); err != nil {
+	format, err := render.ParseFormat(r.URL.Query().Get("format"))
+	if err != nil {
 		http.Error(w, err.Error(), http.StatusBadRequest)
 		return
 	}
 
-	paginatedPosts, err := h.store.GetPostsPaginated(query)
+	paginatedPosts, err := h.store.GetPostsPaginated(r.Context(), query)
 	if err != nil {
 		http.Error(w, "internal server error", http.StatusInternalServerError)
 		return
 	}
 
-	w.Header().Set("Content-Type", "application/json")
-	if err := json.NewEncoder(w).Encode(paginatedPosts); err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+	if err := h.renderPosts(format, paginatedPosts.Posts); err != nil {
+		http.Error(w, "internal server error", http.StatusInternalServerError)
 		return
 	}
+
+	writeJSON(w, r, http.StatusOK, paginatedPosts)
 }
 
 // Update GetAllPosts to use pagination (backward compatibility)
@@ -82,8 +69,14 @@ func (h *PostHandler) GetAllPosts(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	format, err := render.ParseFormat(r.URL.Query().Get("format"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
 	// Old behavior: get all posts (limit to 100 for safety)
-	posts, err := h.store.GetAll()
+	posts, err := h.store.GetAll(r.Context())
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
@@ -94,8 +87,12 @@ func (h *PostHandler) GetAllPosts(w http.ResponseWriter, r *http.Request) {
 		posts = posts[:100]
 	}
 
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(posts)
+	if err := h.renderPosts(format, posts); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	writeJSON(w, r, http.StatusOK, posts)
 }
 
 func (h *PostHandler) GetPost(w http.ResponseWriter, r *http.Request) {
@@ -106,7 +103,13 @@ func (h *PostHandler) GetPost(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	post, err := h.store.GetByID(id)
+	format, err := render.ParseFormat(r.URL.Query().Get("format"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	post, err := h.store.GetByID(r.Context(), id)
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
@@ -117,40 +120,77 @@ func (h *PostHandler) GetPost(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(post)
+	if err := h.renderPost(format, post); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	writeJSON(w, r, http.StatusOK, post)
+}
+
+// GetPostBySlug serves a post by its permalink. Old slugs answer with a 301
+// to the post's current slug.
+func (h *PostHandler) GetPostBySlug(w http.ResponseWriter, r *http.Request) {
+	slug := mux.Vars(r)["slug"]
+
+	format, err := render.ParseFormat(r.URL.Query().Get("format"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	post, err := h.store.GetBySlug(r.Context(), slug)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	if post == nil {
+		http.Error(w, "Post not found", http.StatusNotFound)
+		return
+	}
+
+	if post.Slug != slug {
+		target := strings.TrimSuffix(r.URL.Path, slug) + url.PathEscape(post.Slug)
+		if r.URL.RawQuery != "" {
+			target += "?" + r.URL.RawQuery
+		}
+		http.Redirect(w, r, target, http.StatusMovedPermanently)
+		return
+	}
+
+	if err := h.renderPost(format, post); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	writeJSON(w, r, http.StatusOK, post)
 }
 
 // ===================== Create Post ===============================================================
 func (h *PostHandler) CreatePost(w http.ResponseWriter, r *http.Request) {
 	var req models.CreatePostRequest
 
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		http.Error(w, err.Error(), http.StatusBadRequest)
+	if !decodeJSON(w, r, &req) {
 		return
 	}
 
-	// Simple validation
-	if req.Title == "" || req.Content == "" || req.Author == "" {
-		http.Error(w, "Title, content, and author are required", http.StatusBadRequest)
+	if err := req.Validate(); err != nil {
+		writeValidationError(w, r, err)
 		return
 	}
 
-	post := models.Post{
-		Title:   req.Title,
-		Content: req.Content,
-		Author:  req.Author,
+	createdPost, err := h.store.Create(r.Context(), req.ToPost())
+	if errors.Is(err, storage.ErrSlugTaken) {
+		http.Error(w, err.Error(), http.StatusConflict)
+		return
 	}
-
-	createdPost, err := h.store.Create(post)
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
 
-	w.Header().Set("Content-Type", "application/json")
-	w.WriteHeader(http.StatusCreated)
-	json.NewEncoder(w).Encode(createdPost)
+	writeJSON(w, r, http.StatusCreated, createdPost)
 }
 
 //===================== Update Post ===============================================================
@@ -164,8 +204,12 @@ func (h *PostHandler) UpdatePost(w http.ResponseWriter, r *http.Request) {
 	}
 
 	var req models.UpdatePostRequest
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		http.Error(w, err.Error(), http.StatusBadRequest)
+	if !decodeJSON(w, r, &req) {
+		return
+	}
+
+	if err := req.Validate(); err != nil {
+		writeValidationError(w, r, err)
 		return
 	}
 
@@ -173,9 +217,14 @@ func (h *PostHandler) UpdatePost(w http.ResponseWriter, r *http.Request) {
 		Title:   req.Title,
 		Content: req.Content,
 		Author:  req.Author,
+		Slug:    req.Slug,
 	}
 
-	updatedPost, err := h.store.Update(id, post)
+	updatedPost, err := h.store.Update(r.Context(), id, post)
+	if errors.Is(err, storage.ErrSlugTaken) {
+		http.Error(w, err.Error(), http.StatusConflict)
+		return
+	}
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
@@ -186,8 +235,7 @@ func (h *PostHandler) UpdatePost(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(updatedPost)
+	writeJSON(w, r, http.StatusOK, updatedPost)
 }
 
 func (h *PostHandler) DeletePost(w http.ResponseWriter, r *http.Request) {
@@ -198,7 +246,7 @@ func (h *PostHandler) DeletePost(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	err = h.store.Delete(id)
+	err = h.store.Delete(r.Context(), id)
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
@@ -206,3 +254,83 @@ func (h *PostHandler) DeletePost(w http.ResponseWriter, r *http.Request) {
 
 	w.WriteHeader(http.StatusNoContent)
 }
+
+// parsePostQuery reads PostQuery fields from URL parameters on top of the
+// defaults. It does not validate the result.
+func parsePostQuery(qp url.Values) (models.PostQuery, error) {
+	query := models.DefaultPostQuery()
+
+	if cursor := qp.Get("cursor"); cursor != "" {
+		query.Cursor = cursor
+	}
+
+	if limitStr := qp.Get("limit"); limitStr != "" {
+		limit, err := strconv.Atoi(limitStr)
+		if err != nil {
+			return query, fmt.Errorf("invalid limit parameter")
+		}
+		query.Limit = limit
+	}
+
+	if sortBy := qp.Get("sort_by"); sortBy != "" {
+		query.SortBy = sortBy
+	}
+
+	if sortDir := qp.Get("sort_dir"); sortDir != "" {
+		query.SortDir = sortDir
+	}
+
+	if author := qp.Get("author"); author != "" {
+		query.Author = author
+	}
+
+	if search := qp.Get("search"); search != "" {
+		query.Search = search
+	}
+
+	return query, nil
+}
+
+// renderPost fills in the rendered view of the post's Markdown requested by
+// ?format=. The stored Markdown stays in Content for every format.
+func (h *PostHandler) renderPost(format string, post *models.Post) error {
+	if format == render.FormatMarkdown {
+		return nil
+	}
+
+	out, err := h.renderer.Render(*post)
+	if err != nil {
+		return err
+	}
+
+	switch format {
+	case render.FormatHTML:
+		post.ContentHTML = out.HTML
+		post.TOC = out.TOC
+	case render.FormatText:
+		post.ContentText = out.Text
+	}
+	return nil
+}
+
+func (h *PostHandler) renderPosts(format string, posts []models.Post) error {
+	for i := range posts {
+		if err := h.renderPost(format, &posts[i]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// writeJSON encodes data as the response body inside its own span, so slow
+// serialization shows up separately from the store call in traces.
+func writeJSON(w http.ResponseWriter, r *http.Request, status int, data interface{}) {
+	_, span := otel.Tracer("blog-api/handlers").Start(r.Context(), "json.Encode")
+	defer span.End()
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(data); err != nil {
+		span.RecordError(err)
+	}
+}
@@ -0,0 +1,65 @@
+package handlers
+
+import (
+	"blog-api/models"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+// Site holds the public details used when building absolute links.
+type Site struct {
+	Title       string
+	Description string
+	BaseURL     string // empty: derived from the request
+	// PostPath is the path of a post's page on the public site, with
+	// {slug} or {id} standing for the post's.
+	PostPath string
+}
+
+// baseURL returns the configured public URL, or one built from the request
+// when none is configured. Forwarding headers are not trusted: anyone can
+// send them.
+func (s Site) baseURL(r *http.Request) string {
+	if s.BaseURL != "" {
+		return s.BaseURL
+	}
+
+	scheme := "http"
+	if r.TLS != nil {
+		scheme = "https"
+	}
+	return scheme + "://" + r.Host
+}
+
+// cacheControl is the Cache-Control of a response with absolute links.
+// Links built from the request's Host must not be kept by shared caches,
+// which would hand them to every other client.
+func (s Site) cacheControl(maxAge int) string {
+	scope := "public"
+	if s.BaseURL == "" {
+		scope = "private"
+	}
+	return scope + ", max-age=" + strconv.Itoa(maxAge)
+}
+
+// postURL is the permalink of a post: its page for readers, not its API
+// resource.
+func (s Site) postURL(base string, post models.Post) string {
+	path := strings.NewReplacer(
+		"{slug}", url.PathEscape(post.Slug),
+		"{id}", strconv.Itoa(post.ID),
+	).Replace(s.PostPath)
+	return base + path
+}
+
+// postID is a stable identifier for a post that survives slug changes.
+func postID(base string, post models.Post) string {
+	return base + "/api/v1/posts/" + strconv.Itoa(post.ID)
+}
+
+// absoluteURL resolves a request path against base.
+func absoluteURL(base string, r *http.Request) string {
+	return strings.TrimRight(base, "/") + r.URL.RequestURI()
+}
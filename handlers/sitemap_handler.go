@@ -0,0 +1,179 @@
+package handlers
+
+import (
+	"blog-api/models"
+	"blog-api/storage"
+	"bufio"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+const (
+	// sitemapMaxURLs is the protocol's limit on URLs in one sitemap file.
+	sitemapMaxURLs = 50000
+	sitemapTimeout = 5 * time.Minute
+	sitemapNS      = "http://www.sitemaps.org/schemas/sitemap/0.9"
+)
+
+// SitemapHandler serves /sitemap.xml and /robots.txt.
+type SitemapHandler struct {
+	store    storage.PostStore
+	site     Site
+	disallow []string
+}
+
+// NewSitemapHandler creates a handler whose robots.txt disallows the given
+// path prefixes.
+func NewSitemapHandler(store storage.PostStore, site Site, disallow []string) *SitemapHandler {
+	return &SitemapHandler{store: store, site: site, disallow: disallow}
+}
+
+type sitemapURL struct {
+	Loc     string `xml:"loc"`
+	LastMod string `xml:"lastmod,omitempty"`
+}
+
+// Sitemap handles GET /sitemap.xml. Posts are split into pages by id, page
+// N holding ids (N-1)*50,000+1 to N*50,000, so a page is read with one
+// index range scan and a post stays on the same page for good. With more
+// than one page it returns a sitemap index of /sitemaps/posts-N.xml pages.
+func (h *SitemapHandler) Sitemap(w http.ResponseWriter, r *http.Request) {
+	pages, err := h.pageCount(r)
+	if err != nil {
+		http.Error(w, "internal server error", http.StatusInternalServerError)
+		return
+	}
+	if pages > 1 {
+		h.writeIndex(w, r, pages)
+		return
+	}
+	h.writeURLs(w, r, 1)
+}
+
+// SitemapPage handles GET /sitemaps/posts-{page}.xml.
+func (h *SitemapHandler) SitemapPage(w http.ResponseWriter, r *http.Request) {
+	page, err := strconv.Atoi(mux.Vars(r)["page"])
+	if err != nil || page < 1 {
+		http.Error(w, "Sitemap not found", http.StatusNotFound)
+		return
+	}
+
+	pages, err := h.pageCount(r)
+	if err != nil {
+		http.Error(w, "internal server error", http.StatusInternalServerError)
+		return
+	}
+	if page > pages {
+		http.Error(w, "Sitemap not found", http.StatusNotFound)
+		return
+	}
+	h.writeURLs(w, r, page)
+}
+
+// pageCount returns how many sitemap pages the posts need. Stores that cannot
+// read by id range get a single page.
+func (h *SitemapHandler) pageCount(r *http.Request) (int, error) {
+	reader, ok := h.store.(storage.PostRangeReader)
+	if !ok {
+		return 1, nil
+	}
+	maxID, err := reader.MaxPostID(r.Context())
+	if errors.Is(err, storage.ErrUnsupported) {
+		return 1, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+	return max(1, (maxID+sitemapMaxURLs-1)/sitemapMaxURLs), nil
+}
+
+func (h *SitemapHandler) writeIndex(w http.ResponseWriter, r *http.Request, pages int) {
+	base := h.site.baseURL(r)
+
+	type sitemapRef struct {
+		Loc string `xml:"loc"`
+	}
+	index := struct {
+		XMLName  xml.Name     `xml:"sitemapindex"`
+		NS       string       `xml:"xmlns,attr"`
+		Sitemaps []sitemapRef `xml:"sitemap"`
+	}{NS: sitemapNS}
+	for page := 1; page <= pages; page++ {
+		index.Sitemaps = append(index.Sitemaps, sitemapRef{Loc: fmt.Sprintf("%s/sitemaps/posts-%d.xml", base, page)})
+	}
+
+	body, err := marshalXML(index)
+	if err != nil {
+		http.Error(w, "internal server error", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/xml; charset=utf-8")
+	w.Write(body)
+}
+
+// writeURLs streams one page of post URLs in id order, so memory use does
+// not grow with the number of posts.
+func (h *SitemapHandler) writeURLs(w http.ResponseWriter, r *http.Request, page int) {
+	reader, ok := h.store.(storage.PostRangeReader)
+	if !ok {
+		http.Error(w, storage.ErrUnsupported.Error(), http.StatusNotImplemented)
+		return
+	}
+
+	http.NewResponseController(w).SetWriteDeadline(time.Now().Add(sitemapTimeout))
+	w.Header().Set("Content-Type", "application/xml; charset=utf-8")
+
+	base := h.site.baseURL(r)
+	out := bufio.NewWriter(w)
+	out.WriteString(xml.Header)
+	out.WriteString(`<urlset xmlns="` + sitemapNS + `">` + "\n")
+	enc := xml.NewEncoder(out)
+	urlElement := xml.StartElement{Name: xml.Name{Local: "url"}}
+
+	after := (page - 1) * sitemapMaxURLs
+	err := reader.ExportRange(r.Context(), after, after+sitemapMaxURLs, func(post models.Post) error {
+		entry := sitemapURL{Loc: h.site.postURL(base, post)}
+		if !post.UpdatedAt.IsZero() {
+			entry.LastMod = post.UpdatedAt.UTC().Format(time.RFC3339)
+		}
+		if err := enc.EncodeElement(entry, urlElement); err != nil {
+			return err
+		}
+		out.WriteByte('\n')
+		return nil
+	})
+	if err != nil {
+		// Headers are already sent; the truncated body is all we can signal
+		if !errors.Is(err, r.Context().Err()) {
+			log.Printf("Sitemap failed: %v", err)
+		}
+		return
+	}
+
+	out.WriteString("</urlset>\n")
+	out.Flush()
+}
+
+// Robots handles GET /robots.txt.
+func (h *SitemapHandler) Robots(w http.ResponseWriter, r *http.Request) {
+	var b strings.Builder
+	b.WriteString("User-agent: *\n")
+	if len(h.disallow) == 0 {
+		b.WriteString("Disallow:\n")
+	}
+	for _, path := range h.disallow {
+		fmt.Fprintf(&b, "Disallow: %s\n", path)
+	}
+	fmt.Fprintf(&b, "\nSitemap: %s/sitemap.xml\n", h.site.baseURL(r))
+
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	w.Write([]byte(b.String()))
+}
@@ -0,0 +1,132 @@
+package handlers
+
+import (
+	"blog-api/stream"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// StreamHandler serves post events as Server-Sent Events.
+type StreamHandler struct {
+	hub       *stream.Hub
+	heartbeat time.Duration
+}
+
+func NewStreamHandler(hub *stream.Hub, heartbeat time.Duration) *StreamHandler {
+	return &StreamHandler{hub: hub, heartbeat: heartbeat}
+}
+
+// StreamPosts handles GET /posts/stream. Events carry this instance's
+// stream ID as the SSE id, so a reconnecting client resumes after
+// Last-Event-ID (or ?last_event_id=) from the replay buffer. If the buffer
+// no longer reaches back that far, or the ID was issued by another
+// instance or before a restart, a "reset" event tells the client to reload. ?author= and
+// ?search= filter created and updated posts; deletions are always sent since
+// the deleted post is no longer known.
+func (h *StreamHandler) StreamPosts(w http.ResponseWriter, r *http.Request) {
+	author := r.URL.Query().Get("author")
+	search := strings.ToLower(r.URL.Query().Get("search"))
+
+	lastID := r.Header.Get("Last-Event-ID")
+	if lastID == "" {
+		lastID = r.URL.Query().Get("last_event_id")
+	}
+
+	rc := http.NewResponseController(w)
+	// The stream outlives the server's write timeout; every write pushes the
+	// deadline out again instead.
+	extend := func() error {
+		return rc.SetWriteDeadline(time.Now().Add(2 * h.heartbeat))
+	}
+	if err := extend(); err != nil {
+		http.Error(w, "streaming unsupported", http.StatusInternalServerError)
+		return
+	}
+
+	sub, replay, complete := h.hub.Subscribe(lastID)
+	defer sub.Close()
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+
+	matches := func(e stream.Event) bool {
+		if e.Post == nil {
+			return true
+		}
+		if author != "" && e.Post.Author != author {
+			return false
+		}
+		return search == "" ||
+			strings.Contains(strings.ToLower(e.Post.Title), search) ||
+			strings.Contains(strings.ToLower(e.Post.Content), search)
+	}
+
+	send := func(e stream.Event) error {
+		if !matches(e) {
+			return nil
+		}
+		data, err := json.Marshal(e.PostEvent)
+		if err != nil {
+			return err
+		}
+		_, err = fmt.Fprintf(w, "id: %s\nevent: %s\ndata: %s\n\n", e.StreamID, e.Type, data)
+		return err
+	}
+
+	flush := func() error {
+		if err := extend(); err != nil {
+			return err
+		}
+		return rc.Flush()
+	}
+
+	// Tell EventSource how soon to reconnect
+	fmt.Fprint(w, "retry: 3000\n\n")
+	if !complete {
+		fmt.Fprint(w, "event: reset\ndata: {}\n\n")
+	}
+	for _, e := range replay {
+		if err := send(e); err != nil {
+			return
+		}
+	}
+	if err := flush(); err != nil {
+		return
+	}
+
+	ticker := time.NewTicker(h.heartbeat)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+
+		case e, ok := <-sub.Events():
+			if !ok {
+				// Server shutting down or client too slow; it will
+				// reconnect and resume
+				return
+			}
+			if err := send(e); err != nil {
+				return
+			}
+			if err := flush(); err != nil {
+				return
+			}
+
+		case <-ticker.C:
+			if _, err := fmt.Fprint(w, ": heartbeat\n\n"); err != nil {
+				return
+			}
+			if err := flush(); err != nil {
+				return
+			}
+		}
+	}
+}
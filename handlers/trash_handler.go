@@ -0,0 +1,136 @@
+package handlers
+
+import (
+	"blog-api/models"
+	"blog-api/storage"
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+
+	"github.com/gorilla/mux"
+)
+
+const (
+	defaultTrashLimit = 100
+	maxTrashLimit     = 500
+)
+
+// TrashHandler serves posts that were deleted but not yet purged.
+type TrashHandler struct {
+	store storage.PostStore
+}
+
+func NewTrashHandler(store storage.PostStore) *TrashHandler {
+	return &TrashHandler{store: store}
+}
+
+// trash returns the store's trash, answering 501 if it has none.
+func (h *TrashHandler) trash(w http.ResponseWriter) (storage.PostTrash, bool) {
+	trash, ok := h.store.(storage.PostTrash)
+	if !ok {
+		http.Error(w, storage.ErrUnsupported.Error(), http.StatusNotImplemented)
+	}
+	return trash, ok
+}
+
+// trashError logs a store failure and answers with a generic 500, since
+// database errors can describe the schema.
+func trashError(w http.ResponseWriter, action string, err error) {
+	log.Printf("Trash: failed to %s: %v", action, err)
+	http.Error(w, "internal server error", http.StatusInternalServerError)
+}
+
+// ListTrash handles GET /posts/trash, most recently deleted first.
+func (h *TrashHandler) ListTrash(w http.ResponseWriter, r *http.Request) {
+	limit := defaultTrashLimit
+	if v := r.URL.Query().Get("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 || n > maxTrashLimit {
+			http.Error(w, "limit must be between 1 and 500", http.StatusBadRequest)
+			return
+		}
+		limit = n
+	}
+
+	trash, ok := h.trash(w)
+	if !ok {
+		return
+	}
+
+	posts, err := trash.ListTrash(r.Context(), limit)
+	if errors.Is(err, storage.ErrUnsupported) {
+		http.Error(w, err.Error(), http.StatusNotImplemented)
+		return
+	}
+	if err != nil {
+		trashError(w, "list the trash", err)
+		return
+	}
+	if posts == nil {
+		posts = []models.Post{}
+	}
+
+	writeJSON(w, r, http.StatusOK, posts)
+}
+
+// RestorePost handles POST /posts/trash/{id}:restore.
+func (h *TrashHandler) RestorePost(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "Invalid post ID", http.StatusBadRequest)
+		return
+	}
+
+	trash, ok := h.trash(w)
+	if !ok {
+		return
+	}
+
+	post, err := trash.Restore(r.Context(), id)
+	if errors.Is(err, storage.ErrUnsupported) {
+		http.Error(w, err.Error(), http.StatusNotImplemented)
+		return
+	}
+	if err != nil {
+		trashError(w, "restore post "+strconv.Itoa(id), err)
+		return
+	}
+	if post == nil {
+		http.Error(w, "Post not found in trash", http.StatusNotFound)
+		return
+	}
+
+	writeJSON(w, r, http.StatusOK, post)
+}
+
+// PurgePost handles DELETE /posts/trash/{id}. Only trashed posts can be
+// purged; delete the post first.
+func (h *TrashHandler) PurgePost(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "Invalid post ID", http.StatusBadRequest)
+		return
+	}
+
+	trash, ok := h.trash(w)
+	if !ok {
+		return
+	}
+
+	purged, err := trash.Purge(r.Context(), id)
+	if errors.Is(err, storage.ErrUnsupported) {
+		http.Error(w, err.Error(), http.StatusNotImplemented)
+		return
+	}
+	if err != nil {
+		trashError(w, "purge post "+strconv.Itoa(id), err)
+		return
+	}
+	if !purged {
+		http.Error(w, "Post not found in trash", http.StatusNotFound)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
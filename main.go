@@ -2,11 +2,18 @@ package main
 
 import (
 	"blog-api/config"
+	"blog-api/gql"
 	"blog-api/handlers"
+	"blog-api/middleware"
+	"blog-api/openapi"
+	"blog-api/outbox"
+	"blog-api/render"
 	"blog-api/storage"
-    "blog-api/middleware"
+	"blog-api/stream"
+	"blog-api/tracing"
+	"blog-api/webhooks"
 	"context"
-	"encoding/json"
+	"expvar"
 	"log"
 	"net/http"
 	"os"
@@ -27,76 +34,247 @@ func main() {
 		log.Fatalf("Failed to load config: %v", err)
 	}
 
+	// Initialize tracing
+	shutdownTracing, err := tracing.Setup(context.Background(), tracing.Options{
+		ServiceName: cfg.ServiceName,
+		Exporter:    cfg.TracingExporter,
+		FilePath:    cfg.TracingFile,
+		Endpoint:    cfg.TracingEndpoint,
+		Insecure:    cfg.TracingInsecure,
+		SampleRatio: cfg.TracingSampleRatio,
+	})
+	if err != nil {
+		log.Fatalf("Failed to initialize tracing: %v", err)
+	}
+
 	// Initialize PostgreSQL store
-	store, err := storage.NewPostgresStore(cfg.GetDBConnectionString())
+	pgStore, err := storage.NewPostgresStore(cfg.GetDBConnectionString(), storage.PostgresOptions{
+		MaxOpenConns:    cfg.DBMaxOpenConns,
+		MaxIdleConns:    &cfg.DBMaxIdleConns,
+		ConnMaxLifetime: cfg.DBConnMaxLifetime,
+		ConnMaxIdleTime: cfg.DBConnMaxIdleTime,
+		ConnectTimeout:  cfg.DBConnectTimeout,
+		ReadRetries:     cfg.DBReadRetries,
+
+		ReplicaDSNs:          cfg.GetReplicaConnectionStrings(),
+		ReplicaCheckInterval: cfg.DBReplicaCheckInterval,
+		ReadYourWritesWindow: cfg.DBReadYourWritesWindow,
+	})
 	if err != nil {
 		log.Fatalf("Failed to connect to database: %v", err)
 	}
-	defer store.Close()
 
-	// Initialize database (create tables)
-	if err := store.Init(); err != nil {
-		log.Fatalf("Failed to initialize database: %v", err)
+	// Bring the schema up to date. Without auto-migration the server still
+	// starts, but reports not ready until blogctl migrate up has run.
+	if cfg.DBAutoMigrate {
+		if err := pgStore.Init(); err != nil {
+			log.Fatalf("Failed to initialize database: %v", err)
+		}
+		log.Println("Database initialized successfully")
+	} else if version, err := pgStore.MigrationVersion(context.Background()); err != nil {
+		log.Fatalf("Failed to read the schema version: %v", err)
+	} else if version < storage.SchemaVersion {
+		log.Printf("Database schema is at version %d, this build needs %d; run blogctl migrate up", version, storage.SchemaVersion)
 	}
 
-	log.Println("Database initialized successfully")
+	// Background workers stop when this context is cancelled on shutdown
+	bgCtx, stopBackground := context.WithCancel(context.Background())
+	defer stopBackground()
 
-	// Initialize handlers
-	postHandler := handlers.NewPostStoreHandler(store)
-
-	// Create router
-	r := mux.NewRouter()
-
-	// API routes
-	api := r.PathPrefix("/api/v1").Subrouter()
-
-	// Posts endpoints
-	api.HandleFunc("/posts", postHandler.GetAllPosts).Methods("GET")
-    api.HandleFunc("/posts/paginated", postHandler.GetPostsPaginated).Methods("GET")
-	api.HandleFunc("/posts", postHandler.CreatePost).Methods("POST")
-	api.HandleFunc("/posts/{id}", postHandler.GetPost).Methods("GET")
-	api.HandleFunc("/posts/{id}", postHandler.UpdatePost).Methods("PUT")
-	api.HandleFunc("/posts/{id}", postHandler.DeletePost).Methods("DELETE")
-
-	// Health check with DB connectivity test
-	api.HandleFunc("/health", func(w http.ResponseWriter, r *http.Request) {
-		// Test database connection
-		if _, err := store.GetAll(); err != nil {
-			w.WriteHeader(http.StatusServiceUnavailable)
-			jsonResponse(w, map[string]string{
-				"status":  "unhealthy",
-				"error":   err.Error(),
-				"service": "database",
-			})
-			return
+	tracedStore := storage.NewTracedStore(pgStore)
+	var store storage.PostStore = tracedStore
+
+	// Webhook deliveries are queued in the database and sent in the
+	// background
+	dispatcher := webhooks.NewDispatcher(pgStore, webhooks.Options{
+		PollInterval: cfg.WebhookPollInterval,
+		Timeout:      cfg.WebhookTimeout,
+		MaxAttempts:  cfg.WebhookMaxAttempts,
+
+		AllowPrivateTargets: cfg.WebhookAllowPrivateTargets,
+	})
+	if cfg.WebhooksEnabled {
+		go dispatcher.Run(bgCtx)
+	} else {
+		log.Println("WEBHOOKS_ENABLED=false: no webhooks are queued or sent")
+	}
+
+	// Post events are written to the outbox with each change and relayed
+	// to the configured sinks
+	sinks := map[string]outbox.Sink{}
+	for _, name := range cfg.OutboxSinks {
+		switch name {
+		case "webhooks":
+			if cfg.WebhooksEnabled {
+				sinks[name] = dispatcher
+			}
+		case "log":
+			sinks[name] = outbox.LogSink{}
+		case "file":
+			fileSink, err := outbox.NewFileSink(cfg.OutboxFile)
+			if err != nil {
+				log.Fatalf("Failed to open event file: %v", err)
+			}
+			defer fileSink.Close()
+			sinks[name] = fileSink
 		}
+	}
+	go outbox.NewRelay(pgStore, sinks, outbox.Options{
+		PollInterval: cfg.OutboxPollInterval,
+		Retention:    cfg.OutboxRetention,
+	}).Run(bgCtx)
 
-		jsonResponse(w, map[string]string{
-			"status":    "healthy",
-			"timestamp": time.Now().Format(time.RFC3339),
-			"database":  "connected",
+	var cachedStore *storage.CachedStore
+	if cfg.CacheEnabled {
+		cachedStore = storage.NewCachedStore(store, cfg.CacheMaxBytes, cfg.CacheTTL)
+		expvar.Publish("post_cache", expvar.Func(func() interface{} {
+			return cachedStore.Stats()
+		}))
+		store = cachedStore
+	}
+	defer store.Close()
+
+	// Live post events for SSE clients
+	hub := stream.NewHub(cfg.StreamReplayBuffer)
+
+	// Every instance hears about every change: drop cached entries changed
+	// through other instances and push the change to stream subscribers
+	go func() {
+		err := storage.ListenForChanges(bgCtx, cfg.GetDBConnectionString(), storage.ChangeHandler{
+			OnChange: func(c storage.PostChange) {
+				if cachedStore != nil {
+					cachedStore.Invalidate(c.ID)
+				}
+			},
+			OnEvent: func(n storage.PostEventNotice) {
+				hub.PublishNotice(bgCtx, pgStore, n)
+			},
+			OnReset: func() {
+				if cachedStore != nil {
+					cachedStore.InvalidateAll()
+				}
+				hub.Reset()
+			},
 		})
-	}).Methods("GET")
+		if err != nil {
+			log.Printf("Change listener stopped: %v", err)
+		}
+	}()
 
-    // Apply rate limiting to API endpoints
-    api.Use(middleware.RateLimit)
+	// Tombstones older than the retention are no longer needed by any
+	// valid change token
+	go runPeriodically(bgCtx, time.Hour, func(ctx context.Context) {
+		if _, err := pgStore.PruneTombstones(ctx, time.Now().Add(-cfg.ChangeFeedRetention)); err != nil {
+			log.Printf("Failed to prune change feed tombstones: %v", err)
+		}
+	})
+	// The feed only shows changes older than every running write
+	// transaction; say which one when that holds it back for long
+	if cfg.ChangeFeedLagAlert > 0 {
+		go runPeriodically(bgCtx, time.Minute, func(ctx context.Context) {
+			h, err := pgStore.ChangeFeedHorizon(ctx)
+			if err != nil {
+				log.Printf("Failed to check the change feed horizon: %v", err)
+				return
+			}
+			if h != nil && time.Since(h.Since) > cfg.ChangeFeedLagAlert {
+				log.Printf("Change feed held back for %s by transaction in backend %d (%q); new changes are not listed until it ends",
+					time.Since(h.Since).Round(time.Second), h.PID, h.Application)
+			}
+		})
+	}
+	go runPeriodically(bgCtx, time.Hour, func(ctx context.Context) {
+		n, err := pgStore.PurgeTrash(ctx, time.Now().Add(-cfg.TrashRetention))
+		if err != nil {
+			log.Printf("Failed to purge trash: %v", err)
+			return
+		}
+		if n > 0 {
+			log.Printf("Purged %d posts from the trash", n)
+		}
+	})
+	go runPeriodically(bgCtx, time.Hour, func(ctx context.Context) {
+		if _, err := pgStore.PurgeIdempotencyKeys(ctx); err != nil {
+			log.Printf("Failed to purge expired idempotency keys: %v", err)
+		}
+	})
+
+	// Initialize handlers
+	renderer := render.NewRenderer(render.DefaultCacheBytes)
+	postHandler := handlers.NewPostStoreHandler(store, renderer)
+	site := handlers.Site{
+		Title:       cfg.SiteTitle,
+		Description: cfg.SiteDescription,
+		BaseURL:     cfg.PublicBaseURL,
+		PostPath:    cfg.PublicPostPath,
+	}
+	feedHandler := handlers.NewFeedHandler(store, renderer, site)
+	sitemapHandler := handlers.NewSitemapHandler(store, site, cfg.RobotsDisallow)
+
+	webhookHandler := handlers.NewWebhookHandler(pgStore, dispatcher)
+	streamHandler := handlers.NewStreamHandler(hub, cfg.StreamHeartbeat)
+	changeFeedHandler := handlers.NewChangeFeedHandler(store, cfg.ChangeFeedRetention)
+	trashHandler := handlers.NewTrashHandler(store)
+
+	executor, err := gql.NewExecutor(store, renderer, gql.Options{
+		MaxDepth:      cfg.GraphQLMaxDepth,
+		MaxComplexity: cfg.GraphQLMaxComplexity,
+	})
+	if err != nil {
+		log.Fatalf("Failed to build GraphQL schema: %v", err)
+	}
+	graphqlHandler := handlers.NewGraphQLHandler(executor)
+
+	healthHandler := handlers.NewHealthHandler(pgStore, cfg.HealthCheckTimeout)
+
+	r := newRouter(routeHandlers{
+		health:     healthHandler,
+		post:       postHandler,
+		feed:       feedHandler,
+		sitemap:    sitemapHandler,
+		webhook:    webhookHandler,
+		stream:     streamHandler,
+		changeFeed: changeFeedHandler,
+		trash:      trashHandler,
+		graphql:    graphqlHandler,
+	}, routeOptions{
+		adminToken:  cfg.AdminToken,
+		idempotency: middleware.Idempotency(pgStore, cfg.IdempotencyKeyTTL),
+	})
+	if cfg.AdminToken == "" {
+		log.Println("ADMIN_TOKEN is not set; webhook management is disabled")
+	}
 
 	// Middleware
+	r.Use(middleware.TraceRoute)
 	r.Use(loggingMiddleware)
 	r.Use(jsonContentTypeMiddleware)
 
 	// CORS middleware
 	r.Use(mux.CORSMethodMiddleware(r))
 
+	// Optional request checks against the published API description
+	if cfg.ValidateRequests {
+		validator, err := openapi.NewValidator()
+		if err != nil {
+			log.Fatalf("Failed to load the OpenAPI spec: %v", err)
+		}
+		r.Use(validator.Middleware)
+	}
+
 	// Create HTTP server
 	srv := &http.Server{
 		Addr:         ":" + cfg.ServerPort,
-		Handler:      r,
+		Handler:      middleware.Tracing(r),
 		ReadTimeout:  15 * time.Second,
 		WriteTimeout: 15 * time.Second,
 		IdleTimeout:  60 * time.Second,
 	}
 
+	// Open event streams never go idle, so end them as soon as shutdown
+	// starts or srv.Shutdown would wait for its full timeout
+	srv.RegisterOnShutdown(hub.Close)
+
 	// Run server in goroutine
 	go func() {
 		log.Printf("Server starting on :%s", cfg.ServerPort)
@@ -105,12 +283,35 @@ func main() {
 		}
 	}()
 
+	// Runtime counters are served on a separate, usually loopback-only,
+	// listener
+	var adminSrv *http.Server
+	if cfg.AdminAddr != "" {
+		adminSrv = &http.Server{
+			Addr:         cfg.AdminAddr,
+			Handler:      newAdminRouter(),
+			ReadTimeout:  15 * time.Second,
+			WriteTimeout: 15 * time.Second,
+		}
+		go func() {
+			log.Printf("Admin server starting on %s", cfg.AdminAddr)
+			if err := adminSrv.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+				log.Fatalf("Admin ListenAndServe error: %v", err)
+			}
+		}()
+	}
+
 	// Wait for interrupt signal
 	quit := make(chan os.Signal, 1)
 	signal.Notify(quit, os.Interrupt, syscall.SIGTERM)
 	<-quit
 	log.Println("Shutting down server...")
 
+	// Fail readiness first and give load balancers time to notice before
+	// we stop accepting connections
+	healthHandler.StartDraining()
+	time.Sleep(cfg.ShutdownDrainDelay)
+
 	// Create shutdown context with timeout
 	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
 	defer cancel()
@@ -119,10 +320,36 @@ func main() {
 	if err := srv.Shutdown(ctx); err != nil {
 		log.Fatalf("Server forced to shutdown: %v", err)
 	}
+	if adminSrv != nil {
+		adminSrv.Shutdown(ctx)
+	}
+
+	stopBackground()
+
+	// Flush pending spans
+	if err := shutdownTracing(ctx); err != nil {
+		log.Printf("Failed to flush traces: %v", err)
+	}
 
 	log.Println("Server stopped gracefully")
 }
 
+// runPeriodically calls fn now and then every interval until ctx is
+// cancelled.
+func runPeriodically(ctx context.Context, interval time.Duration, fn func(ctx context.Context)) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		fn(ctx)
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
 func loggingMiddleware(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		log.Printf("%s %s", r.Method, r.RequestURI)
@@ -136,8 +363,3 @@ func jsonContentTypeMiddleware(next http.Handler) http.Handler {
 		next.ServeHTTP(w, r)
 	})
 }
-
-func jsonResponse(w http.ResponseWriter, data interface{}) {
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(data)
-}
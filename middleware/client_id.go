@@ -0,0 +1,30 @@
+package middleware
+
+import (
+	"blog-api/storage"
+	"net"
+	"net/http"
+)
+
+// ClientIDHeader lets clients identify themselves across connections, e.g.
+// a mobile app installation. Without it the remote IP is used.
+const ClientIDHeader = "X-Client-ID"
+
+// ClientID tags the request context with the caller's identity so the store
+// can give that client read-your-writes consistency across replicas, and
+// Idempotency can keep each client's keys apart. The two kinds of ID are
+// prefixed so a header cannot claim the identity of an IP address.
+func ClientID(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id := "client:" + r.Header.Get(ClientIDHeader)
+		if id == "client:" {
+			ip, _, err := net.SplitHostPort(r.RemoteAddr)
+			if err != nil {
+				ip = r.RemoteAddr
+			}
+			id = "ip:" + ip
+		}
+
+		next.ServeHTTP(w, r.WithContext(storage.WithClientID(r.Context(), id)))
+	})
+}
@@ -0,0 +1,220 @@
+package middleware
+
+import (
+	"blog-api/storage"
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io"
+	"log"
+	"net/http"
+	"time"
+)
+
+// IdempotencyKeyHeader lets clients retry an unsafe request without
+// repeating its effect: the first response sent for a key is replayed to
+// every later request with the same key until the key expires.
+const IdempotencyKeyHeader = "Idempotency-Key"
+
+// Replayed responses carry this header set to true.
+const IdempotentReplayedHeader = "Idempotent-Replayed"
+
+const (
+	maxIdempotencyKey      = 255
+	maxIdempotentBody      = 1 << 20 // bodies are buffered to fingerprint them
+	maxIdempotentResponse  = 4 << 20 // larger responses are not stored
+	idempotencyTimeout     = 5 * time.Second
+	idempotencyRenewPeriod = 3 // renewals per lease
+)
+
+// unreplayedHeaders are not stored with a response: they describe the
+// connection or this particular reply rather than the result.
+var unreplayedHeaders = []string{
+	"Connection", "Content-Length", "Date", "Keep-Alive", "Set-Cookie",
+	"Trailer", "Transfer-Encoding", "Upgrade", IdempotentReplayedHeader,
+}
+
+// responseRecorder passes a response through while keeping a copy of it,
+// up to maxIdempotentResponse bytes.
+type responseRecorder struct {
+	http.ResponseWriter
+	status   int
+	header   http.Header
+	body     bytes.Buffer
+	overflow bool
+}
+
+func (r *responseRecorder) WriteHeader(code int) {
+	if r.status == 0 {
+		r.status = code
+		r.header = r.ResponseWriter.Header().Clone()
+	}
+	r.ResponseWriter.WriteHeader(code)
+}
+
+func (r *responseRecorder) Write(b []byte) (int, error) {
+	if r.status == 0 {
+		r.WriteHeader(http.StatusOK)
+	}
+	if r.body.Len()+len(b) > maxIdempotentResponse {
+		r.overflow = true
+	} else {
+		r.body.Write(b)
+	}
+	return r.ResponseWriter.Write(b)
+}
+
+func (r *responseRecorder) Unwrap() http.ResponseWriter {
+	return r.ResponseWriter
+}
+
+// Idempotency honours the Idempotency-Key header on POST, PUT, PATCH and
+// DELETE requests. Keys are scoped to the client (see ClientID), which
+// must run first. A key is bound to a fingerprint of the method, URL and
+// body that first used it; reusing it for a different request gets 422, and
+// retrying while the first request is still running gets 409. Responses
+// that did not carry out the request (401, 403, server errors) are not
+// stored, so the request can be retried with the same key.
+func Idempotency(store storage.IdempotencyStore, ttl time.Duration) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			key := r.Header.Get(IdempotencyKeyHeader)
+			if key == "" || !unsafeMethod(r.Method) {
+				next.ServeHTTP(w, r)
+				return
+			}
+			if len(key) > maxIdempotencyKey {
+				http.Error(w, "Idempotency-Key must be at most 255 characters", http.StatusBadRequest)
+				return
+			}
+
+			body, err := io.ReadAll(io.LimitReader(r.Body, maxIdempotentBody+1))
+			if err != nil {
+				http.Error(w, "Failed to read request body", http.StatusBadRequest)
+				return
+			}
+			if len(body) > maxIdempotentBody {
+				http.Error(w, "Request body is too large to use with an Idempotency-Key", http.StatusRequestEntityTooLarge)
+				return
+			}
+			r.Body = io.NopCloser(bytes.NewReader(body))
+			fingerprint := requestFingerprint(r, body)
+
+			lease, rec, err := store.ReserveIdempotencyKey(r.Context(), storage.ClientIDFrom(r.Context()), key, fingerprint, ttl)
+			if err != nil {
+				log.Printf("Failed to reserve Idempotency-Key %q: %v", key, err)
+				http.Error(w, "Internal server error", http.StatusInternalServerError)
+				return
+			}
+			if rec != nil {
+				switch {
+				case rec.Fingerprint != fingerprint:
+					http.Error(w, "Idempotency-Key was already used for a different request", http.StatusUnprocessableEntity)
+				case rec.Response == nil:
+					http.Error(w, "A request with this Idempotency-Key is still in progress", http.StatusConflict)
+				default:
+					replay(w, rec.Response)
+				}
+				return
+			}
+
+			stopRenewing := renewLease(r.Context(), store, *lease)
+			recorder := &responseRecorder{ResponseWriter: w}
+			next.ServeHTTP(recorder, r)
+			stopRenewing()
+
+			// The client may be gone, which is why it will retry, so the
+			// response is stored regardless of the request context
+			ctx, cancel := context.WithTimeout(context.WithoutCancel(r.Context()), idempotencyTimeout)
+			defer cancel()
+
+			status := recorder.status
+			if status == 0 {
+				status = http.StatusOK
+			}
+			switch {
+			case status >= 500 || status == http.StatusUnauthorized || status == http.StatusForbidden:
+				err = store.ReleaseIdempotencyKey(ctx, *lease)
+			case recorder.overflow:
+				log.Printf("Response for Idempotency-Key %q is too large to store; retries will run again", key)
+				err = store.ReleaseIdempotencyKey(ctx, *lease)
+			default:
+				header := recorder.header
+				if header == nil {
+					header = w.Header().Clone()
+				}
+				for _, name := range unreplayedHeaders {
+					header.Del(name)
+				}
+				err = store.CompleteIdempotencyKey(ctx, *lease, storage.IdempotentResponse{
+					StatusCode: status,
+					Header:     header,
+					Body:       recorder.body.Bytes(),
+				})
+			}
+			if err != nil {
+				log.Printf("Failed to store response for Idempotency-Key %q: %v", key, err)
+			}
+		})
+	}
+}
+
+// renewLease keeps a reserved key leased to this request until the
+// returned function is called, so a retry cannot take over a request that
+// is slow rather than dead.
+func renewLease(ctx context.Context, store storage.IdempotencyStore, lease storage.IdempotencyLease) (stop func()) {
+	ctx, cancel := context.WithCancel(context.WithoutCancel(ctx))
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		ticker := time.NewTicker(lease.Duration / idempotencyRenewPeriod)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+			err := store.RenewIdempotencyKey(ctx, lease)
+			if errors.Is(err, storage.ErrLeaseLost) {
+				log.Printf("Lost the lease on Idempotency-Key %q", lease.Key)
+				return
+			}
+			if err != nil && ctx.Err() == nil {
+				log.Printf("Failed to renew Idempotency-Key %q: %v", lease.Key, err)
+			}
+		}
+	}()
+	return func() {
+		cancel()
+		<-done
+	}
+}
+
+func unsafeMethod(method string) bool {
+	switch method {
+	case http.MethodPost, http.MethodPut, http.MethodPatch, http.MethodDelete:
+		return true
+	}
+	return false
+}
+
+func requestFingerprint(r *http.Request, body []byte) string {
+	h := sha256.New()
+	io.WriteString(h, r.Method+" "+r.URL.RequestURI()+"\n")
+	h.Write(body)
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// replay writes a stored response, including its headers such as
+// Location.
+func replay(w http.ResponseWriter, resp *storage.IdempotentResponse) {
+	for name, values := range resp.Header {
+		w.Header()[name] = values
+	}
+	w.Header().Set(IdempotentReplayedHeader, "true")
+	w.WriteHeader(resp.StatusCode)
+	w.Write(resp.Body)
+}
@@ -0,0 +1,72 @@
+package models
+
+import (
+	"encoding/base64"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Change feed operations.
+const (
+	ChangeCreated = "created"
+	ChangeUpdated = "updated"
+	ChangeDeleted = "deleted"
+)
+
+// PostChange is one entry in the change feed: the latest change to a post.
+// Deleted posts are reported as tombstones with no Post.
+type PostChange struct {
+	Op        string    `json:"op"`
+	PostID    int       `json:"post_id"`
+	Post      *Post     `json:"post,omitempty"`
+	ChangedAt time.Time `json:"changed_at"`
+}
+
+type ChangeSet struct {
+	Changes   []PostChange `json:"changes"`
+	NextToken string       `json:"next_token"`
+	HasMore   bool         `json:"has_more"`
+}
+
+// ChangeToken is a position in the change feed. IssuedAt records when the
+// token was handed out, so tokens older than the tombstone retention can be
+// rejected.
+type ChangeToken struct {
+	TxID     uint64
+	Seq      int64
+	IssuedAt time.Time
+}
+
+func (t ChangeToken) Encode() string {
+	data := fmt.Sprintf("%d|%d|%d", t.TxID, t.Seq, t.IssuedAt.UnixNano())
+	return base64.RawURLEncoding.EncodeToString([]byte(data))
+}
+
+func DecodeChangeToken(token string) (ChangeToken, error) {
+	var t ChangeToken
+	invalid := fmt.Errorf("invalid change token")
+
+	data, err := base64.RawURLEncoding.DecodeString(token)
+	if err != nil {
+		return t, invalid
+	}
+	parts := strings.Split(string(data), "|")
+	if len(parts) != 3 {
+		return t, invalid
+	}
+
+	if t.TxID, err = strconv.ParseUint(parts[0], 10, 64); err != nil {
+		return t, invalid
+	}
+	if t.Seq, err = strconv.ParseInt(parts[1], 10, 64); err != nil {
+		return t, invalid
+	}
+	nano, err := strconv.ParseInt(parts[2], 10, 64)
+	if err != nil {
+		return t, invalid
+	}
+	t.IssuedAt = time.Unix(0, nano)
+	return t, nil
+}
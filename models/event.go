@@ -0,0 +1,23 @@
+package models
+
+import "time"
+
+// Post lifecycle event types.
+const (
+	EventPostCreated = "post.created"
+	EventPostUpdated = "post.updated"
+	EventPostDeleted = "post.deleted"
+)
+
+// EventTypes lists every event a webhook can subscribe to.
+var EventTypes = []string{EventPostCreated, EventPostUpdated, EventPostDeleted}
+
+// PostEvent records a change to a post. ID is unique per event, so
+// consumers can use it to drop duplicates.
+type PostEvent struct {
+	ID         string    `json:"id"`
+	Type       string    `json:"type"`
+	OccurredAt time.Time `json:"occurred_at"`
+	PostID     int       `json:"post_id"`
+	Post       *Post     `json:"post,omitempty"` // nil for post.deleted
+}
@@ -13,8 +13,24 @@ type Post struct {
 	Title     string    `json:"title"`
 	Content   string    `json:"content"`
 	Author    string    `json:"author"`
+	Slug      string    `json:"slug"`
 	CreatedAt time.Time `json:"created_at"`
 	UpdatedAt time.Time `json:"updated_at"`
+
+	// Set only on posts listed from the trash
+	DeletedAt *time.Time `json:"deleted_at,omitempty"`
+
+	// Rendered views of Content, filled in on request (?format=html|text)
+	ContentHTML string     `json:"content_html,omitempty"`
+	ContentText string     `json:"content_text,omitempty"`
+	TOC         []TOCEntry `json:"toc,omitempty"`
+}
+
+// TOCEntry is one heading in a post's generated table of contents.
+type TOCEntry struct {
+	Level int    `json:"level"`
+	ID    string `json:"id"`
+	Text  string `json:"text"`
 }
 
 type PaginatedPosts struct {
@@ -24,14 +40,55 @@ type PaginatedPosts struct {
 	HasMore bool `json:"has_more"`
 }
 
+// Cursor marks the last post of a page. It holds that post's value of the
+// field the listing is sorted by; ties are broken by ID.
 type Cursor struct {
 	ID int `json:"id"`
+	SortBy string `json:"sort_by"`
 	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+	Title string `json:"title"`
+}
+
+// NewCursor returns the cursor just after post in a listing sorted by
+// sortBy.
+func NewCursor(post Post, sortBy string) *Cursor {
+	c := &Cursor{ID: post.ID, SortBy: sortKey(sortBy)}
+	switch c.SortBy {
+	case "updated_at":
+		c.UpdatedAt = post.UpdatedAt
+	case "title":
+		c.Title = post.Title
+	default:
+		c.CreatedAt = post.CreatedAt
+	}
+	return c
+}
+
+// Value is the sort field's value at the cursor.
+func (c *Cursor) Value() interface{} {
+	switch c.SortBy {
+	case "updated_at":
+		return c.UpdatedAt
+	case "title":
+		return c.Title
+	}
+	return c.CreatedAt
 }
 
+// Encode keeps the original id|created_at form for created_at cursors so
+// cursors handed out before other sorts existed still work.
 func (c *Cursor) Encode() string {
-	data := []byte(fmt.Sprintf("%d|%d", c.ID, c.CreatedAt.UnixNano()))
-	return base64.RawURLEncoding.EncodeToString(data)
+	var data string
+	switch sortKey(c.SortBy) {
+	case "updated_at":
+		data = fmt.Sprintf("%d|updated_at|%d", c.ID, c.UpdatedAt.UnixNano())
+	case "title":
+		data = fmt.Sprintf("%d|title|%s", c.ID, c.Title)
+	default:
+		data = fmt.Sprintf("%d|%d", c.ID, c.CreatedAt.UnixNano())
+	}
+	return base64.RawURLEncoding.EncodeToString([]byte(data))
 }
 
 func DecodeCursor(cursorStr string) (*Cursor, error) {
@@ -40,25 +97,44 @@ func DecodeCursor(cursorStr string) (*Cursor, error) {
 		return nil, err
 	}
 
-	parts := strings.Split(string(data), "|")
-	if len(parts) != 2 {
+	// Titles may contain |, so they are always last
+	parts := strings.SplitN(string(data), "|", 3)
+	if len(parts) < 2 {
 		return nil, fmt.Errorf("Invalid cursor format")
 	}
 
 	id, err := strconv.Atoi(parts[0])
-    if err != nil {
-        return nil, err
-    }
-    
-    nano, err := strconv.ParseInt(parts[1], 10, 64)
-    if err != nil {
-        return nil, err
-    }
-    
-    return &Cursor{
-        ID:        id,
-        CreatedAt: time.Unix(0, nano),
-    }, nil
+	if err != nil {
+		return nil, err
+	}
+
+	if len(parts) == 2 {
+		nano, err := strconv.ParseInt(parts[1], 10, 64)
+		if err != nil {
+			return nil, err
+		}
+		return &Cursor{ID: id, SortBy: "created_at", CreatedAt: time.Unix(0, nano)}, nil
+	}
+
+	switch parts[1] {
+	case "updated_at":
+		nano, err := strconv.ParseInt(parts[2], 10, 64)
+		if err != nil {
+			return nil, err
+		}
+		return &Cursor{ID: id, SortBy: "updated_at", UpdatedAt: time.Unix(0, nano)}, nil
+	case "title":
+		return &Cursor{ID: id, SortBy: "title", Title: parts[2]}, nil
+	}
+	return nil, fmt.Errorf("Invalid cursor format")
+}
+
+// sortKey maps an empty sort_by to the default.
+func sortKey(sortBy string) string {
+	if sortBy == "" {
+		return "created_at"
+	}
+	return sortBy
 }
 
 // Query parameters
@@ -95,17 +171,51 @@ func (q *PostQuery) Validate() error {
         return fmt.Errorf("sort_dir must be 'asc' or 'desc'")
     }
     
+    if q.Cursor != "" {
+        cursor, err := DecodeCursor(q.Cursor)
+        if err != nil {
+            return fmt.Errorf("invalid cursor")
+        }
+        if cursor.SortBy != q.SortBy {
+            return fmt.Errorf("cursor was issued for sort_by=%s", cursor.SortBy)
+        }
+    }
+    
     return nil
 }
 
+// Length limits match the posts table columns.
 type CreatePostRequest struct {
-	Title   string `json:"title" validate:"required"`
+	Title   string `json:"title" validate:"required,max=255,singleline"`
 	Content string `json:"content" validate:"required"`
-	Author  string `json:"author" validate:"required"`
+	Author  string `json:"author" validate:"required,max=100,singleline"`
+	Slug    string `json:"slug,omitempty" validate:"slug"` // generated from Title when empty
+}
+
+// Validate normalizes the request in place and applies the same checks as
+// POST /posts. Failures are returned as a *ValidationError.
+func (r *CreatePostRequest) Validate() error {
+	return ValidateStruct(r)
+}
+
+// ToPost converts the request into a Post ready to be stored.
+func (r *CreatePostRequest) ToPost() Post {
+	return Post{
+		Title:   r.Title,
+		Content: r.Content,
+		Author:  r.Author,
+		Slug:    r.Slug,
+	}
 }
 
 type UpdatePostRequest struct {
-	Title   string `json:"title,omitempty"`
+	Title   string `json:"title,omitempty" validate:"max=255,singleline"`
 	Content string `json:"content,omitempty"`
-	Author  string `json:"author,omitempty"`
+	Author  string `json:"author,omitempty" validate:"max=100,singleline"`
+	Slug    string `json:"slug,omitempty" validate:"slug"`
+}
+
+// Validate normalizes the fields that were supplied and checks them.
+func (r *UpdatePostRequest) Validate() error {
+	return ValidateStruct(r)
 }
@@ -0,0 +1,144 @@
+package models
+
+import (
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"golang.org/x/text/unicode/norm"
+)
+
+// FieldError is a validation failure on one request field, named as in JSON.
+type FieldError struct {
+	Field   string `json:"field"`
+	Message string `json:"message"`
+}
+
+// ValidationError lists every field of a request that failed validation.
+type ValidationError struct {
+	Fields []FieldError
+}
+
+func (e *ValidationError) Error() string {
+	parts := make([]string, len(e.Fields))
+	for i, f := range e.Fields {
+		parts[i] = f.Field + " " + f.Message
+	}
+	return strings.Join(parts, "; ")
+}
+
+func (e *ValidationError) add(field, format string, args ...interface{}) {
+	e.Fields = append(e.Fields, FieldError{Field: field, Message: fmt.Sprintf(format, args...)})
+}
+
+// ValidateStruct normalizes the string fields of the struct v points to and
+// checks them against their `validate` tags. Every string is converted to
+// Unicode NFC with CRLF line endings turned into LF, and may not contain
+// control characters other than tab and newline. Tag rules:
+//
+//	required    must not be empty or only whitespace
+//	max=N       at most N characters (not bytes)
+//	min=N       at least N characters
+//	singleline  whitespace runs, including line breaks, collapse to one
+//	            space and the ends are trimmed
+//	slug        see ValidateSlug
+//
+// A field left empty is skipped unless required, so partial updates work;
+// one that is only whitespace is an error.
+func ValidateStruct(v interface{}) error {
+	rv := reflect.ValueOf(v).Elem()
+	rt := rv.Type()
+
+	verr := &ValidationError{}
+	for i := 0; i < rt.NumField(); i++ {
+		sf := rt.Field(i)
+		fv := rv.Field(i)
+		if !sf.IsExported() || fv.Kind() != reflect.String {
+			continue
+		}
+
+		name := strings.Split(sf.Tag.Get("json"), ",")[0]
+		if name == "" {
+			name = sf.Name
+		}
+		rules := parseRules(sf.Tag.Get("validate"))
+
+		raw := fv.String()
+		if !utf8.ValidString(raw) {
+			verr.add(name, "must be valid UTF-8")
+			continue
+		}
+		_, singleline := rules["singleline"]
+		s := normalizeString(raw, singleline)
+		fv.SetString(s)
+
+		if strings.TrimSpace(s) == "" {
+			if _, required := rules["required"]; required {
+				verr.add(name, "is required")
+			} else if raw != "" {
+				verr.add(name, "must not be blank")
+			}
+			continue
+		}
+
+		if hasControlChars(s) {
+			verr.add(name, "must not contain control characters")
+			continue
+		}
+
+		n := utf8.RuneCountInString(s)
+		if max, ok := rules["max"]; ok && n > max {
+			verr.add(name, "must be at most %d characters", max)
+			continue
+		}
+		if min, ok := rules["min"]; ok && n < min {
+			verr.add(name, "must be at least %d characters", min)
+			continue
+		}
+		if _, ok := rules["slug"]; ok {
+			if err := ValidateSlug(s); err != nil {
+				verr.add(name, "%s", strings.TrimPrefix(err.Error(), "slug "))
+			}
+		}
+	}
+
+	if len(verr.Fields) > 0 {
+		return verr
+	}
+	return nil
+}
+
+// parseRules reads a validate tag into rule name and numeric argument.
+func parseRules(tag string) map[string]int {
+	rules := map[string]int{}
+	for _, rule := range strings.Split(tag, ",") {
+		name, arg, _ := strings.Cut(strings.TrimSpace(rule), "=")
+		if name == "" {
+			continue
+		}
+		n, _ := strconv.Atoi(arg)
+		rules[name] = n
+	}
+	return rules
+}
+
+func normalizeString(s string, singleline bool) string {
+	s = norm.NFC.String(s)
+	s = strings.ReplaceAll(s, "\r\n", "\n")
+	if singleline {
+		s = strings.Join(strings.Fields(s), " ")
+	}
+	return s
+}
+
+func hasControlChars(s string) bool {
+	for _, r := range s {
+		if unicode.IsControl(r) && r != '\n' && r != '\t' {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,104 @@
+package models
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+// ruleRequest exercises each validate rule on its own field.
+type ruleRequest struct {
+	Required string `json:"required" validate:"required"`
+	Max      string `json:"max" validate:"max=5"`
+	Min      string `json:"min" validate:"min=3"`
+	Line     string `json:"line" validate:"singleline"`
+	Slug     string `json:"slug" validate:"slug"`
+	Plain    string `json:"plain"`
+}
+
+func TestValidateStructRules(t *testing.T) {
+	valid := func() ruleRequest { return ruleRequest{Required: "x"} }
+	tests := []struct {
+		name  string
+		edit  func(r *ruleRequest)
+		field string // field expected to fail, "" for none
+		msg   string
+	}{
+		{"all empty but required", func(r *ruleRequest) {}, "", ""},
+		{"required empty", func(r *ruleRequest) { r.Required = "" }, "required", "is required"},
+		{"required blank", func(r *ruleRequest) { r.Required = " \n\t" }, "required", "is required"},
+		{"optional blank", func(r *ruleRequest) { r.Plain = "   " }, "plain", "must not be blank"},
+		{"max counts characters", func(r *ruleRequest) { r.Max = "héllo" }, "", ""},
+		{"max exceeded", func(r *ruleRequest) { r.Max = "héllo!" }, "max", "must be at most 5 characters"},
+		{"min reached", func(r *ruleRequest) { r.Min = "abc" }, "", ""},
+		{"min not reached", func(r *ruleRequest) { r.Min = "ab" }, "min", "must be at least 3 characters"},
+		{"singleline collapses", func(r *ruleRequest) { r.Line = "a\nb" }, "", ""},
+		{"control character", func(r *ruleRequest) { r.Plain = "a\x00b" }, "plain", "must not contain control characters"},
+		{"tab and newline allowed", func(r *ruleRequest) { r.Plain = "a\tb\nc" }, "", ""},
+		{"invalid UTF-8", func(r *ruleRequest) { r.Plain = "a\xffb" }, "plain", "must be valid UTF-8"},
+		{"slug", func(r *ruleRequest) { r.Slug = "my-post-2" }, "", ""},
+		{"slug uppercase", func(r *ruleRequest) { r.Slug = "My-Post" }, "slug", "may only contain lowercase letters, digits and single hyphens"},
+		{"slug double hyphen", func(r *ruleRequest) { r.Slug = "my--post" }, "slug", "may only contain lowercase letters, digits and single hyphens"},
+		{"slug too long", func(r *ruleRequest) { r.Slug = strings.Repeat("a", MaxSlugLength+1) }, "slug", "must be at most"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := valid()
+			tt.edit(&req)
+			err := ValidateStruct(&req)
+			if tt.field == "" {
+				if err != nil {
+					t.Fatalf("ValidateStruct = %v, want nil", err)
+				}
+				return
+			}
+
+			var verr *ValidationError
+			if !errors.As(err, &verr) {
+				t.Fatalf("ValidateStruct = %v, want a *ValidationError", err)
+			}
+			if len(verr.Fields) != 1 || verr.Fields[0].Field != tt.field || !strings.HasPrefix(verr.Fields[0].Message, tt.msg) {
+				t.Errorf("ValidateStruct fields = %+v, want %s %q", verr.Fields, tt.field, tt.msg)
+			}
+		})
+	}
+}
+
+func TestValidateStructReportsEveryField(t *testing.T) {
+	req := ruleRequest{Max: "too long", Min: "a"}
+	var verr *ValidationError
+	if !errors.As(ValidateStruct(&req), &verr) {
+		t.Fatal("want a *ValidationError")
+	}
+	var fields []string
+	for _, f := range verr.Fields {
+		fields = append(fields, f.Field)
+	}
+	if got := strings.Join(fields, ","); got != "required,max,min" {
+		t.Errorf("failed fields = %s, want required,max,min", got)
+	}
+}
+
+func TestCreatePostRequestNormalizes(t *testing.T) {
+	req := CreatePostRequest{
+		Title:   "  Cafe\u0301\r\n  notes  ", // decomposed é
+		Content: "line one\r\nline two\n",
+		Author:  " Ann \t Lee ",
+	}
+	if err := req.Validate(); err != nil {
+		t.Fatal(err)
+	}
+	want := CreatePostRequest{
+		Title:   "Café notes",
+		Content: "line one\nline two\n",
+		Author:  "Ann Lee",
+	}
+	if req != want {
+		t.Errorf("normalized to %+q, want %+q", req, want)
+	}
+
+	long := CreatePostRequest{Title: strings.Repeat("é", 256), Content: "x", Author: "Ann"}
+	if err := long.Validate(); err == nil || !strings.Contains(err.Error(), "title must be at most 255 characters") {
+		t.Errorf("256 character title: Validate = %v", err)
+	}
+}
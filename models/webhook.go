@@ -0,0 +1,100 @@
+package models
+
+import (
+	"fmt"
+	"net/url"
+	"time"
+)
+
+// Webhook delivery states.
+const (
+	DeliveryPending   = "pending"
+	DeliverySucceeded = "succeeded"
+	DeliveryFailed    = "failed"
+)
+
+// WebhookSubscription sends the listed event types to URL. The secret is
+// only returned when the subscription is created.
+type WebhookSubscription struct {
+	ID        int       `json:"id"`
+	URL       string    `json:"url"`
+	Secret    string    `json:"secret,omitempty"`
+	Events    []string  `json:"events"`
+	Active    bool      `json:"active"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// WebhookDelivery is one event sent (or to be sent) to one subscription.
+type WebhookDelivery struct {
+	ID             int64      `json:"id"`
+	SubscriptionID int        `json:"subscription_id"`
+	EventID        string     `json:"event_id"`
+	Event          string     `json:"event"`
+	Payload        []byte     `json:"-"`
+	Status         string     `json:"status"`
+	Attempts       int        `json:"attempts"`
+	LastStatusCode int        `json:"last_status_code,omitempty"`
+	LastError      string     `json:"last_error,omitempty"`
+	NextAttemptAt  *time.Time `json:"next_attempt_at,omitempty"`
+	CreatedAt      time.Time  `json:"created_at"`
+	DeliveredAt    *time.Time `json:"delivered_at,omitempty"`
+
+	// Target, filled in when a delivery is claimed for sending
+	URL    string `json:"-"`
+	Secret string `json:"-"`
+}
+
+type CreateWebhookRequest struct {
+	URL    string   `json:"url"`
+	Secret string   `json:"secret,omitempty"` // generated when empty
+	Events []string `json:"events"`
+}
+
+func (r *CreateWebhookRequest) Validate() error {
+	if err := validateWebhookURL(r.URL); err != nil {
+		return err
+	}
+	if len(r.Events) == 0 {
+		return fmt.Errorf("events must list at least one of: %v", EventTypes)
+	}
+	return validateEventTypes(r.Events)
+}
+
+type UpdateWebhookRequest struct {
+	URL    string   `json:"url,omitempty"`
+	Secret string   `json:"secret,omitempty"`
+	Events []string `json:"events,omitempty"`
+	Active *bool    `json:"active,omitempty"`
+}
+
+// Validate checks the fields that were supplied.
+func (r *UpdateWebhookRequest) Validate() error {
+	if r.URL != "" {
+		if err := validateWebhookURL(r.URL); err != nil {
+			return err
+		}
+	}
+	return validateEventTypes(r.Events)
+}
+
+func validateWebhookURL(raw string) error {
+	u, err := url.Parse(raw)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return fmt.Errorf("url must be an absolute http:// or https:// URL")
+	}
+	return nil
+}
+
+func validateEventTypes(events []string) error {
+	for _, event := range events {
+		known := false
+		for _, t := range EventTypes {
+			known = known || event == t
+		}
+		if !known {
+			return fmt.Errorf("unknown event %q, must be one of: %v", event, EventTypes)
+		}
+	}
+	return nil
+}
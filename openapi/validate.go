@@ -0,0 +1,344 @@
+package openapi
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"github.com/gorilla/mux"
+)
+
+// Largest JSON body the validator reads; bigger bodies are rejected.
+const maxValidatedBody = 8 << 20
+
+// muxParam matches a variable with a pattern in a mux path template.
+var muxParam = regexp.MustCompile(`\{([^{}:]+):[^{}]*\}`)
+
+// PathTemplate turns a mux path template into the form used in the spec,
+// dropping variable patterns: /posts/{id:[0-9]+} becomes /posts/{id}.
+func PathTemplate(muxTemplate string) string {
+	return muxParam.ReplaceAllString(muxTemplate, "{$1}")
+}
+
+// Validator rejects requests whose parameters or JSON body do not match the
+// spec. It finds the operation from the mux route that matched, so it must
+// be installed with Router.Use. Routes missing from the spec pass through.
+type Validator struct {
+	doc *Document
+	ops map[string]*Operation // "GET /api/v1/posts/{id}"
+
+	mu       sync.Mutex
+	patterns map[string]*regexp.Regexp
+}
+
+func NewValidator() (*Validator, error) {
+	doc, err := Spec()
+	if err != nil {
+		return nil, err
+	}
+
+	v := &Validator{
+		doc:      doc,
+		ops:      make(map[string]*Operation),
+		patterns: make(map[string]*regexp.Regexp),
+	}
+	for path, item := range doc.Paths {
+		for method, op := range item {
+			v.ops[strings.ToUpper(method)+" "+path] = op
+		}
+	}
+	return v, nil
+}
+
+func (v *Validator) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		route := mux.CurrentRoute(r)
+		if route == nil {
+			next.ServeHTTP(w, r)
+			return
+		}
+		tmpl, err := route.GetPathTemplate()
+		if err != nil {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		method := r.Method
+		if method == http.MethodHead {
+			method = http.MethodGet
+		}
+		op := v.ops[method+" "+PathTemplate(tmpl)]
+		if op == nil {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		if err := v.check(r, op); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+func (v *Validator) check(r *http.Request, op *Operation) error {
+	vars := mux.Vars(r)
+	query := r.URL.Query()
+
+	for _, p := range op.Parameters {
+		p = v.doc.parameter(p)
+		if p == nil {
+			continue
+		}
+
+		// Handlers treat empty values as absent
+		var raw string
+		switch p.In {
+		case "path":
+			raw = vars[p.Name]
+		case "query":
+			raw = query.Get(p.Name)
+		case "header":
+			raw = r.Header.Get(p.Name)
+		default:
+			continue
+		}
+		if raw == "" {
+			if p.Required {
+				return fmt.Errorf("%s parameter %s is required", p.In, p.Name)
+			}
+			continue
+		}
+
+		schema := v.doc.schema(p.Schema)
+		value, err := coerce(raw, schema)
+		if err != nil {
+			return fmt.Errorf("%s parameter %s %v", p.In, p.Name, err)
+		}
+		if err := v.validate(schema, value, p.Name); err != nil {
+			return fmt.Errorf("%s parameter %v", p.In, err)
+		}
+	}
+
+	return v.checkBody(r, op)
+}
+
+// checkBody validates JSON bodies. Other media types are left to the
+// handler.
+func (v *Validator) checkBody(r *http.Request, op *Operation) error {
+	if op.RequestBody == nil {
+		return nil
+	}
+	content, ok := op.RequestBody.Content["application/json"]
+	if !ok {
+		return nil
+	}
+	if ct := r.Header.Get("Content-Type"); ct != "" {
+		if media, _, err := mime.ParseMediaType(ct); err != nil || media != "application/json" {
+			return nil
+		}
+	}
+
+	data, err := io.ReadAll(io.LimitReader(r.Body, maxValidatedBody+1))
+	if err != nil {
+		return fmt.Errorf("read request body: %v", err)
+	}
+	if len(data) > maxValidatedBody {
+		return fmt.Errorf("request body exceeds %d bytes", maxValidatedBody)
+	}
+	r.Body = io.NopCloser(bytes.NewReader(data))
+
+	if len(bytes.TrimSpace(data)) == 0 {
+		if op.RequestBody.Required {
+			return fmt.Errorf("request body is required")
+		}
+		return nil
+	}
+
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	var value interface{}
+	if err := dec.Decode(&value); err != nil {
+		return fmt.Errorf("request body is not valid JSON: %v", err)
+	}
+	return v.validate(content.Schema, value, "body")
+}
+
+// coerce converts a parameter string to the JSON value its schema expects.
+func coerce(raw string, s *Schema) (interface{}, error) {
+	if s == nil {
+		return raw, nil
+	}
+	switch {
+	case s.Type.allows("integer"):
+		if _, err := strconv.ParseInt(raw, 10, 64); err != nil {
+			return nil, fmt.Errorf("must be an integer")
+		}
+		return json.Number(raw), nil
+	case s.Type.allows("number"):
+		if _, err := strconv.ParseFloat(raw, 64); err != nil {
+			return nil, fmt.Errorf("must be a number")
+		}
+		return json.Number(raw), nil
+	case s.Type.allows("boolean"):
+		b, err := strconv.ParseBool(raw)
+		if err != nil {
+			return nil, fmt.Errorf("must be true or false")
+		}
+		return b, nil
+	}
+	return raw, nil
+}
+
+// validate checks value, decoded with UseNumber, against s. at names the
+// value in errors.
+func (v *Validator) validate(s *Schema, value interface{}, at string) error {
+	s = v.doc.schema(s)
+	if s == nil {
+		return nil
+	}
+
+	if len(s.OneOf) > 0 {
+		for _, alt := range s.OneOf {
+			if v.validate(alt, value, at) == nil {
+				return nil
+			}
+		}
+		return fmt.Errorf("%s does not match any allowed shape", at)
+	}
+
+	if len(s.Enum) > 0 {
+		found := false
+		for _, e := range s.Enum {
+			if fmt.Sprint(e) == fmt.Sprint(value) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return fmt.Errorf("%s must be one of %v", at, s.Enum)
+		}
+	}
+
+	switch val := value.(type) {
+	case nil:
+		if len(s.Type) > 0 && !s.Type.allows("null") {
+			return fmt.Errorf("%s must not be null", at)
+		}
+
+	case string:
+		if len(s.Type) > 0 && !s.Type.allows("string") {
+			return fmt.Errorf("%s must be %s", at, s.Type[0])
+		}
+		n := utf8.RuneCountInString(val)
+		if s.MinLength != nil && n < *s.MinLength {
+			return fmt.Errorf("%s must be at least %d characters", at, *s.MinLength)
+		}
+		if s.MaxLength != nil && n > *s.MaxLength {
+			return fmt.Errorf("%s must be at most %d characters", at, *s.MaxLength)
+		}
+		if s.Pattern != "" {
+			re, err := v.pattern(s.Pattern)
+			if err != nil {
+				return err
+			}
+			if !re.MatchString(val) {
+				return fmt.Errorf("%s must match %s", at, s.Pattern)
+			}
+		}
+		switch s.Format {
+		case "uri":
+			if u, err := url.Parse(val); err != nil || !u.IsAbs() {
+				return fmt.Errorf("%s must be an absolute URI", at)
+			}
+		case "date-time":
+			if _, err := time.Parse(time.RFC3339, val); err != nil {
+				return fmt.Errorf("%s must be an RFC 3339 date-time", at)
+			}
+		}
+
+	case json.Number:
+		if s.Type.allows("integer") && !s.Type.allows("number") {
+			if _, err := val.Int64(); err != nil {
+				return fmt.Errorf("%s must be an integer", at)
+			}
+		} else if len(s.Type) > 0 && !s.Type.allows("number") {
+			return fmt.Errorf("%s must be %s", at, s.Type[0])
+		}
+		f, _ := val.Float64()
+		if s.Minimum != nil && f < *s.Minimum {
+			return fmt.Errorf("%s must be at least %v", at, *s.Minimum)
+		}
+		if s.Maximum != nil && f > *s.Maximum {
+			return fmt.Errorf("%s must be at most %v", at, *s.Maximum)
+		}
+
+	case bool:
+		if len(s.Type) > 0 && !s.Type.allows("boolean") {
+			return fmt.Errorf("%s must be %s", at, s.Type[0])
+		}
+
+	case []interface{}:
+		if len(s.Type) > 0 && !s.Type.allows("array") {
+			return fmt.Errorf("%s must be %s", at, s.Type[0])
+		}
+		if s.MinItems != nil && len(val) < *s.MinItems {
+			return fmt.Errorf("%s must have at least %d items", at, *s.MinItems)
+		}
+		for i, item := range val {
+			if err := v.validate(s.Items, item, fmt.Sprintf("%s[%d]", at, i)); err != nil {
+				return err
+			}
+		}
+
+	case map[string]interface{}:
+		if len(s.Type) > 0 && !s.Type.allows("object") {
+			return fmt.Errorf("%s must be %s", at, s.Type[0])
+		}
+		for _, name := range s.Required {
+			if _, ok := val[name]; !ok {
+				return fmt.Errorf("%s.%s is required", at, name)
+			}
+		}
+		if string(s.AdditionalProperties) == "false" {
+			for name := range val {
+				if _, ok := s.Properties[name]; !ok {
+					return fmt.Errorf("%s.%s is not a known field", at, name)
+				}
+			}
+		}
+		for name, prop := range s.Properties {
+			if field, ok := val[name]; ok {
+				if err := v.validate(prop, field, at+"."+name); err != nil {
+					return err
+				}
+			}
+		}
+	}
+	return nil
+}
+
+func (v *Validator) pattern(expr string) (*regexp.Regexp, error) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	if re, ok := v.patterns[expr]; ok {
+		return re, nil
+	}
+	re, err := regexp.Compile(expr)
+	if err != nil {
+		return nil, fmt.Errorf("invalid pattern in spec: %v", err)
+	}
+	v.patterns[expr] = re
+	return re, nil
+}
@@ -0,0 +1,94 @@
+package outbox
+
+import (
+	"blog-api/models"
+	"blog-api/storage"
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+)
+
+// Sink receives events relayed from the outbox. Delivery is at least once:
+// an event is retried on every sink when any sink fails, so sinks should
+// tolerate (or drop, by event ID) duplicates.
+type Sink interface {
+	Publish(ctx context.Context, event models.PostEvent) error
+}
+
+// Options tunes the relay. Zero values use the defaults below.
+type Options struct {
+	PollInterval time.Duration
+	BatchSize    int
+	// Retention is how long published events are kept; zero keeps them.
+	Retention time.Duration
+}
+
+const (
+	defaultPollInterval = time.Second
+	defaultBatchSize    = 100
+	pruneInterval       = time.Hour
+)
+
+// Relay moves events from the outbox table to sinks.
+type Relay struct {
+	store storage.OutboxStore
+	sinks map[string]Sink
+	opts  Options
+}
+
+func NewRelay(store storage.OutboxStore, sinks map[string]Sink, opts Options) *Relay {
+	if opts.PollInterval <= 0 {
+		opts.PollInterval = defaultPollInterval
+	}
+	if opts.BatchSize <= 0 {
+		opts.BatchSize = defaultBatchSize
+	}
+	return &Relay{store: store, sinks: sinks, opts: opts}
+}
+
+// Run relays events until ctx is cancelled.
+func (r *Relay) Run(ctx context.Context) {
+	ticker := time.NewTicker(r.opts.PollInterval)
+	defer ticker.Stop()
+	lastPrune := time.Time{}
+
+	for {
+		// Keep going while full batches come back
+		for {
+			n, err := r.store.ProcessOutbox(ctx, r.opts.BatchSize, r.publish(ctx))
+			if err != nil && ctx.Err() == nil {
+				log.Printf("Outbox relay failed: %v", err)
+			}
+			if err != nil || n < r.opts.BatchSize {
+				break
+			}
+		}
+
+		if r.opts.Retention > 0 && time.Since(lastPrune) > pruneInterval {
+			lastPrune = time.Now()
+			if _, err := r.store.PruneOutbox(ctx, time.Now().Add(-r.opts.Retention)); err != nil && ctx.Err() == nil {
+				log.Printf("Outbox prune failed: %v", err)
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (r *Relay) publish(ctx context.Context) func(models.PostEvent) error {
+	return func(event models.PostEvent) error {
+		var errs []error
+		for name, sink := range r.sinks {
+			if err := sink.Publish(ctx, event); err != nil {
+				errs = append(errs, fmt.Errorf("%s: %w", name, err))
+			}
+		}
+		return errors.Join(errs...)
+	}
+}
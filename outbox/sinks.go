@@ -0,0 +1,49 @@
+package outbox
+
+import (
+	"blog-api/models"
+	"context"
+	"encoding/json"
+	"log"
+	"os"
+	"sync"
+)
+
+// LogSink writes a line per event to the standard logger.
+type LogSink struct{}
+
+func (LogSink) Publish(ctx context.Context, event models.PostEvent) error {
+	log.Printf("Event %s: %s post %d", event.ID, event.Type, event.PostID)
+	return nil
+}
+
+// FileSink appends events as JSON lines to a file.
+type FileSink struct {
+	mu   sync.Mutex
+	file *os.File
+}
+
+func NewFileSink(path string) (*FileSink, error) {
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o644)
+	if err != nil {
+		return nil, err
+	}
+	return &FileSink{file: f}, nil
+}
+
+func (s *FileSink) Publish(ctx context.Context, event models.PostEvent) error {
+	line, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+	line = append(line, '\n')
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	_, err = s.file.Write(line)
+	return err
+}
+
+func (s *FileSink) Close() error {
+	return s.file.Close()
+}
@@ -0,0 +1,185 @@
+package portability
+
+import (
+	"archive/tar"
+	"blog-api/models"
+	"blog-api/storage"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// postWriter serializes posts one at a time onto a stream.
+type postWriter interface {
+	Write(post models.Post) error
+	Close() error
+}
+
+// ExportFormat is a format posts can be exported in.
+type ExportFormat struct {
+	Name        string
+	ContentType string
+	Extension   string // file name extension, without the dot
+	newWriter   func(w io.Writer) postWriter
+}
+
+var exportFormats = map[string]ExportFormat{
+	"jsonl":    {"jsonl", "application/x-ndjson", "jsonl", newJSONLWriter},
+	"csv":      {"csv", "text/csv; charset=utf-8", "csv", newCSVWriter},
+	"markdown": {"markdown", "application/gzip", "tar.gz", newMarkdownArchiveWriter},
+}
+
+// ErrExportFormat is returned for an export format other than jsonl, csv
+// and markdown.
+var ErrExportFormat = errors.New("format must be jsonl, csv or markdown")
+
+// LookupExportFormat returns the export format called name: jsonl, csv or
+// markdown (a tar.gz of Markdown files with YAML front matter).
+func LookupExportFormat(name string) (ExportFormat, error) {
+	format, ok := exportFormats[name]
+	if !ok {
+		return ExportFormat{}, ErrExportFormat
+	}
+	return format, nil
+}
+
+// Export writes every post matching query to w in format and returns how
+// many it wrote.
+func Export(ctx context.Context, exporter storage.PostExporter, query models.PostQuery, format ExportFormat, w io.Writer) (int, error) {
+	n := 0
+	out := format.newWriter(w)
+	err := exporter.ExportPosts(ctx, query, func(post models.Post) error {
+		n++
+		return out.Write(post)
+	})
+	if err == nil {
+		err = out.Close()
+	}
+	return n, err
+}
+
+type jsonlWriter struct {
+	enc *json.Encoder
+}
+
+func newJSONLWriter(w io.Writer) postWriter {
+	return &jsonlWriter{enc: json.NewEncoder(w)}
+}
+
+func (j *jsonlWriter) Write(post models.Post) error {
+	return j.enc.Encode(post)
+}
+
+func (j *jsonlWriter) Close() error {
+	return nil
+}
+
+type csvWriter struct {
+	w      *csv.Writer
+	header bool
+}
+
+func newCSVWriter(w io.Writer) postWriter {
+	return &csvWriter{w: csv.NewWriter(w)}
+}
+
+func (c *csvWriter) writeHeader() error {
+	if c.header {
+		return nil
+	}
+	c.header = true
+	return c.w.Write([]string{"id", "title", "content", "author", "slug", "created_at", "updated_at"})
+}
+
+func (c *csvWriter) Write(post models.Post) error {
+	if err := c.writeHeader(); err != nil {
+		return err
+	}
+	return c.w.Write([]string{
+		strconv.Itoa(post.ID),
+		post.Title,
+		post.Content,
+		post.Author,
+		post.Slug,
+		post.CreatedAt.Format(time.RFC3339Nano),
+		post.UpdatedAt.Format(time.RFC3339Nano),
+	})
+}
+
+func (c *csvWriter) Close() error {
+	// Always emit a header, even for an empty export
+	if err := c.writeHeader(); err != nil {
+		return err
+	}
+	c.w.Flush()
+	return c.w.Error()
+}
+
+// markdownArchiveWriter writes posts/<id>.md entries into a gzipped tarball.
+type markdownArchiveWriter struct {
+	gz  *gzip.Writer
+	tar *tar.Writer
+	buf bytes.Buffer
+}
+
+type frontMatter struct {
+	ID        int       `yaml:"id"`
+	Title     string    `yaml:"title"`
+	Author    string    `yaml:"author"`
+	Slug      string    `yaml:"slug"`
+	CreatedAt time.Time `yaml:"created_at"`
+	UpdatedAt time.Time `yaml:"updated_at"`
+}
+
+func newMarkdownArchiveWriter(w io.Writer) postWriter {
+	gz := gzip.NewWriter(w)
+	return &markdownArchiveWriter{gz: gz, tar: tar.NewWriter(gz)}
+}
+
+func (m *markdownArchiveWriter) Write(post models.Post) error {
+	m.buf.Reset()
+	m.buf.WriteString("---\n")
+	enc := yaml.NewEncoder(&m.buf)
+	if err := enc.Encode(frontMatter{
+		ID:        post.ID,
+		Title:     post.Title,
+		Author:    post.Author,
+		Slug:      post.Slug,
+		CreatedAt: post.CreatedAt.UTC(),
+		UpdatedAt: post.UpdatedAt.UTC(),
+	}); err != nil {
+		return err
+	}
+	enc.Close()
+	m.buf.WriteString("---\n\n")
+	m.buf.WriteString(post.Content)
+	m.buf.WriteString("\n")
+
+	err := m.tar.WriteHeader(&tar.Header{
+		Name:    fmt.Sprintf("posts/%d.md", post.ID),
+		Mode:    0o644,
+		Size:    int64(m.buf.Len()),
+		ModTime: post.UpdatedAt,
+	})
+	if err != nil {
+		return err
+	}
+	_, err = m.tar.Write(m.buf.Bytes())
+	return err
+}
+
+func (m *markdownArchiveWriter) Close() error {
+	if err := m.tar.Close(); err != nil {
+		return err
+	}
+	return m.gz.Close()
+}
@@ -0,0 +1,103 @@
+package portability
+
+import (
+	"archive/tar"
+	"blog-api/models"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"io"
+	"strings"
+	"testing"
+	"time"
+)
+
+// exportStore exports a fixed list of posts.
+type exportStore []models.Post
+
+func (s exportStore) ExportPosts(ctx context.Context, query models.PostQuery, fn func(models.Post) error) error {
+	for _, post := range s {
+		if err := fn(post); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+var exportedPosts = exportStore{
+	{ID: 7, Title: "First", Content: "Body, with \"quotes\"\nand lines", Author: "Ann", Slug: "first-post",
+		CreatedAt: time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC), UpdatedAt: time.Date(2026, 2, 3, 4, 5, 6, 0, time.UTC)},
+	{ID: 9, Title: "Second", Content: "More", Author: "Bo", Slug: "custom-slug",
+		CreatedAt: time.Date(2026, 3, 4, 5, 6, 7, 0, time.UTC), UpdatedAt: time.Date(2026, 3, 4, 5, 6, 7, 0, time.UTC)},
+}
+
+// TestExportRoundTrip imports what jsonl and csv exports write and expects
+// the same posts back, permalinks included.
+func TestExportRoundTrip(t *testing.T) {
+	for _, tt := range []struct{ export, importAs string }{
+		{"jsonl", "ndjson"},
+		{"csv", "csv"},
+	} {
+		t.Run(tt.export, func(t *testing.T) {
+			format, err := LookupExportFormat(tt.export)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var buf bytes.Buffer
+			if _, err := Export(context.Background(), exportedPosts, models.PostQuery{}, format, &buf); err != nil {
+				t.Fatalf("Export: %v", err)
+			}
+
+			store := &importStore{}
+			report, err := Import(context.Background(), store, &buf, ImportOptions{Format: tt.importAs})
+			if err != nil {
+				t.Fatalf("Import: %v", err)
+			}
+			if report.Failed != 0 {
+				t.Fatalf("Import failed rows: %+v", report.Results)
+			}
+			if len(store.committed) != len(exportedPosts) {
+				t.Fatalf("imported %d posts, want %d", len(store.committed), len(exportedPosts))
+			}
+			for i, got := range store.committed {
+				want := exportedPosts[i]
+				if got.Title != want.Title || got.Content != want.Content || got.Author != want.Author || got.Slug != want.Slug {
+					t.Errorf("post %d imported as %+v, want %+v", i, got, want)
+				}
+			}
+		})
+	}
+}
+
+func TestMarkdownExportFrontMatter(t *testing.T) {
+	format, err := LookupExportFormat("markdown")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var buf bytes.Buffer
+	if _, err := Export(context.Background(), exportedPosts[:1], models.PostQuery{}, format, &buf); err != nil {
+		t.Fatalf("Export: %v", err)
+	}
+
+	gz, err := gzip.NewReader(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	archive := tar.NewReader(gz)
+	header, err := archive.Next()
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, err := io.ReadAll(archive)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if header.Name != "posts/7.md" {
+		t.Errorf("entry name = %q, want posts/7.md", header.Name)
+	}
+	for _, want := range []string{"id: 7\n", "title: First\n", "slug: first-post\n"} {
+		if !strings.Contains(string(body), want) {
+			t.Errorf("front matter lacks %q:\n%s", want, body)
+		}
+	}
+}
@@ -0,0 +1,449 @@
+// Package portability moves posts in and out of a store in bulk, in the
+// formats POST /posts:import, GET /posts:export and blogctl share.
+package portability
+
+import (
+	"blog-api/models"
+	"blog-api/storage"
+	"bufio"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+)
+
+const (
+	importBatchSize = 500
+	importMaxLine   = 4 << 20 // longest NDJSON line accepted
+)
+
+// ImportResult reports the outcome for one input line.
+type ImportResult struct {
+	Line   int    `json:"line"`
+	Status string `json:"status"` // created, valid, failed, rolled_back
+	ID     int    `json:"id,omitempty"`
+	Error  string `json:"error,omitempty"`
+}
+
+// ImportReport is the outcome of an import, with one result per row.
+type ImportReport struct {
+	DryRun    bool           `json:"dry_run"`
+	Atomic    bool           `json:"atomic"`
+	Committed bool           `json:"committed"`
+	Total     int            `json:"total"`
+	Succeeded int            `json:"succeeded"`
+	Failed    int            `json:"failed"`
+	Results   []ImportResult `json:"results"`
+	// Error is why the import stopped before the end of the input. Rows
+	// reported as created up to then stay committed unless Atomic is set.
+	Error string `json:"error,omitempty"`
+}
+
+// importLine is one NDJSON line: a CreatePostRequest, or a post as the
+// jsonl export writes it. The fields a post gets from the store are
+// accepted and ignored, so an export can be imported again.
+type importLine struct {
+	models.CreatePostRequest
+	ID        json.RawMessage `json:"id"`
+	CreatedAt json.RawMessage `json:"created_at"`
+	UpdatedAt json.RawMessage `json:"updated_at"`
+}
+
+// importRow is one parsed input line awaiting insertion.
+type importRow struct {
+	line int
+	req  models.CreatePostRequest
+	err  error
+}
+
+// ErrImportFormat is returned for an import format other than ndjson
+// (or jsonl) and csv.
+var ErrImportFormat = errors.New("format must be ndjson or csv")
+
+// InputError is a problem with the input as a whole, such as a malformed
+// CSV header or an overlong line, that stops an import part way.
+type InputError struct {
+	Err error
+}
+
+func (e *InputError) Error() string { return e.Err.Error() }
+func (e *InputError) Unwrap() error { return e.Err }
+
+// ImportOptions controls Import. Format is ndjson (or jsonl) or csv.
+type ImportOptions struct {
+	Format string
+	DryRun bool
+	Atomic bool
+}
+
+// Import validates the rows of body like a created post and inserts them
+// in batches. DryRun only validates; Atomic commits nothing unless every
+// row succeeds. Stores that cannot import in bulk return
+// storage.ErrUnsupported unless DryRun is set.
+//
+// An import that stops part way, on an *InputError or a store error,
+// returns the error together with a report of what was done until then,
+// with Error set.
+func Import(ctx context.Context, store storage.PostStore, body io.Reader, opts ImportOptions) (*ImportReport, error) {
+	rows := importRows(opts.Format, body)
+	if rows == nil {
+		return nil, ErrImportFormat
+	}
+	imp, err := newImporter(ctx, store, opts.DryRun, opts.Atomic)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := rows(imp.add); err != nil {
+		if imp.err == nil {
+			err = &InputError{Err: err}
+		}
+		return imp.stop(err)
+	}
+	if err := imp.finish(); err != nil {
+		return imp.stop(err)
+	}
+	return imp.report, nil
+}
+
+// importRows returns a reader for the rows of body, or nil if the format is
+// not supported.
+func importRows(format string, body io.Reader) func(yield func(importRow) error) error {
+	switch format {
+	case "ndjson", "jsonl":
+		return func(yield func(importRow) error) error { return readNDJSON(body, yield) }
+	case "csv":
+		return func(yield func(importRow) error) error { return readCSV(body, yield) }
+	}
+	return nil
+}
+
+// importer accumulates validated rows and writes them in batches.
+type importer struct {
+	dryRun bool
+	atomic bool
+	begin  func() (storage.ImportTx, error)
+
+	tx      storage.ImportTx // open transaction (atomic mode spans all batches)
+	pending []importRow
+	failed  bool
+	err     error // why writing stopped
+	report  *ImportReport
+}
+
+// newImporter returns storage.ErrUnsupported if rows would have to be
+// written and store cannot import in bulk.
+func newImporter(ctx context.Context, store storage.PostStore, dryRun, atomic bool) (*importer, error) {
+	imp := &importer{dryRun: dryRun, atomic: atomic, report: &ImportReport{DryRun: dryRun, Atomic: atomic}}
+	if !dryRun {
+		bulk, ok := store.(storage.PostImporter)
+		if !ok {
+			return nil, storage.ErrUnsupported
+		}
+		imp.begin = func() (storage.ImportTx, error) { return bulk.BeginImport(ctx) }
+	}
+	return imp, nil
+}
+
+func (imp *importer) add(row importRow) error {
+	imp.report.Total++
+
+	if row.err == nil {
+		row.err = row.req.Validate()
+	}
+	if row.err != nil {
+		imp.fail(row.line, row.err)
+		return nil
+	}
+
+	if imp.dryRun {
+		imp.record(ImportResult{Line: row.line, Status: "valid"})
+		return nil
+	}
+
+	imp.pending = append(imp.pending, row)
+	if len(imp.pending) >= importBatchSize {
+		return imp.flush()
+	}
+	return nil
+}
+
+func (imp *importer) fail(line int, err error) {
+	imp.record(failedResult(line, err))
+}
+
+func failedResult(line int, err error) ImportResult {
+	return ImportResult{Line: line, Status: "failed", Error: err.Error()}
+}
+
+func (imp *importer) record(res ImportResult) {
+	imp.report.Results = append(imp.report.Results, res)
+	if res.Status == "failed" {
+		imp.failed = true
+		imp.report.Failed++
+	} else {
+		imp.report.Succeeded++
+	}
+}
+
+// flush inserts the pending rows. Outside atomic mode each batch is its own
+// transaction, so a failing batch does not undo earlier ones.
+func (imp *importer) flush() error {
+	if len(imp.pending) == 0 {
+		return nil
+	}
+	batch := imp.pending
+	imp.pending = nil
+
+	// Once an atomic import has failed nothing will be committed, so skip
+	// the remaining inserts and just validate.
+	if imp.atomic && imp.failed {
+		for _, row := range batch {
+			imp.record(ImportResult{Line: row.line, Status: "valid"})
+		}
+		return nil
+	}
+
+	if imp.tx == nil {
+		tx, err := imp.begin()
+		if err != nil {
+			imp.err = err
+			for _, row := range batch {
+				imp.fail(row.line, err)
+			}
+			return err
+		}
+		imp.tx = tx
+	}
+
+	results := imp.insert(batch)
+	if !imp.atomic {
+		err := imp.tx.Commit()
+		imp.tx = nil
+		if err != nil {
+			for i := range results {
+				if results[i].Status == "created" {
+					results[i] = failedResult(results[i].Line, err)
+				}
+			}
+		}
+	}
+	for _, res := range results {
+		imp.record(res)
+	}
+	return nil
+}
+
+// insert writes batch in one statement. If that fails it retries the rows
+// one by one, so only the rows at fault fail.
+func (imp *importer) insert(batch []importRow) []ImportResult {
+	posts := make([]models.Post, len(batch))
+	for i, row := range batch {
+		posts[i] = row.req.ToPost()
+	}
+
+	results := make([]ImportResult, len(batch))
+	inserted, err := imp.tx.Insert(posts)
+	if err == nil {
+		for i, row := range batch {
+			results[i] = ImportResult{Line: row.line, Status: "created", ID: inserted[i].ID}
+		}
+		return results
+	}
+	if len(batch) == 1 {
+		results[0] = failedResult(batch[0].line, err)
+		return results
+	}
+
+	for i, row := range batch {
+		inserted, err := imp.tx.Insert(posts[i : i+1])
+		if err != nil {
+			results[i] = failedResult(row.line, err)
+		} else {
+			results[i] = ImportResult{Line: row.line, Status: "created", ID: inserted[0].ID}
+		}
+	}
+	return results
+}
+
+func (imp *importer) abort() {
+	if imp.tx != nil {
+		imp.tx.Rollback()
+		imp.tx = nil
+	}
+}
+
+// stop ends an import that could not run to the end. Rows read so far are
+// still written outside atomic mode; an atomic import commits nothing.
+func (imp *importer) stop(err error) (*ImportReport, error) {
+	if imp.err == nil {
+		imp.failed = true
+		imp.finish()
+	} else {
+		imp.abort()
+		if imp.atomic {
+			imp.rollBackResults()
+		}
+		imp.sortResults()
+	}
+	if !imp.atomic && !imp.dryRun {
+		imp.report.Committed = imp.report.Succeeded > 0
+	}
+	imp.report.Error = err.Error()
+	return imp.report, err
+}
+
+// finish flushes the last batch, settles the atomic transaction and puts
+// the results in input order.
+func (imp *importer) finish() error {
+	err := imp.settle()
+	imp.sortResults()
+	return err
+}
+
+// sortResults restores input order; rows are reported when their batch is
+// written.
+func (imp *importer) sortResults() {
+	sort.SliceStable(imp.report.Results, func(i, j int) bool {
+		return imp.report.Results[i].Line < imp.report.Results[j].Line
+	})
+}
+
+func (imp *importer) settle() error {
+	if imp.dryRun {
+		return nil
+	}
+	if err := imp.flush(); err != nil {
+		return err
+	}
+
+	if !imp.atomic {
+		imp.report.Committed = imp.report.Succeeded > 0
+		return nil
+	}
+
+	if imp.failed {
+		imp.abort()
+		imp.rollBackResults()
+		return nil
+	}
+	if imp.tx != nil {
+		if err := imp.tx.Commit(); err != nil {
+			imp.tx = nil
+			return err
+		}
+		imp.tx = nil
+	}
+	imp.report.Committed = true
+	return nil
+}
+
+// rollBackResults marks rows that were inserted or validated in a failed
+// atomic import as rolled back.
+func (imp *importer) rollBackResults() {
+	for i := range imp.report.Results {
+		res := &imp.report.Results[i]
+		if res.Status != "failed" {
+			res.Status = "rolled_back"
+			res.ID = 0
+		}
+	}
+	imp.report.Succeeded = 0
+}
+
+func readNDJSON(body io.Reader, yield func(importRow) error) error {
+	scanner := bufio.NewScanner(body)
+	scanner.Buffer(make([]byte, 64*1024), importMaxLine)
+
+	line := 0
+	for scanner.Scan() {
+		line++
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" {
+			continue
+		}
+
+		row := importRow{line: line}
+		var in importLine
+		dec := json.NewDecoder(strings.NewReader(text))
+		dec.DisallowUnknownFields()
+		if err := dec.Decode(&in); err != nil {
+			row.err = fmt.Errorf("invalid JSON: %v", err)
+		}
+		row.req = in.CreatePostRequest
+		if err := yield(row); err != nil {
+			return err
+		}
+	}
+
+	if err := scanner.Err(); err != nil {
+		if errors.Is(err, bufio.ErrTooLong) {
+			return fmt.Errorf("line %d: longer than %d bytes", line+1, importMaxLine)
+		}
+		return err
+	}
+	return nil
+}
+
+func readCSV(body io.Reader, yield func(importRow) error) error {
+	reader := csv.NewReader(body)
+	reader.FieldsPerRecord = -1
+
+	header, err := reader.Read()
+	if err == io.EOF {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("invalid CSV header: %w", err)
+	}
+
+	columns := map[string]int{}
+	for i, name := range header {
+		columns[strings.ToLower(strings.TrimSpace(name))] = i
+	}
+	for _, name := range []string{"title", "content", "author"} {
+		if _, ok := columns[name]; !ok {
+			return fmt.Errorf("CSV header must include title, content and author columns")
+		}
+	}
+
+	field := func(record []string, name string) string {
+		if i, ok := columns[name]; ok && i < len(record) {
+			return record[i]
+		}
+		return ""
+	}
+
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			return nil
+		}
+
+		var row importRow
+		if err != nil {
+			var parseErr *csv.ParseError
+			if !errors.As(err, &parseErr) {
+				return err
+			}
+			row.line = parseErr.StartLine
+			row.err = fmt.Errorf("invalid CSV: %v", parseErr.Err)
+		} else {
+			row.line, _ = reader.FieldPos(0)
+			row.req = models.CreatePostRequest{
+				Title:   field(record, "title"),
+				Content: field(record, "content"),
+				Author:  field(record, "author"),
+				Slug:    field(record, "slug"),
+			}
+		}
+
+		if err := yield(row); err != nil {
+			return err
+		}
+	}
+}
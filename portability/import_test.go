@@ -0,0 +1,105 @@
+package portability
+
+import (
+	"blog-api/models"
+	"blog-api/storage"
+	"context"
+	"errors"
+	"strings"
+	"testing"
+)
+
+// importStore accepts imports and rejects any batch holding a post titled
+// "bad", like a constraint violation fails a whole INSERT.
+type importStore struct {
+	storage.PostStore
+	committed []models.Post
+}
+
+func (s *importStore) BeginImport(ctx context.Context) (storage.ImportTx, error) {
+	return &importTx{store: s}, nil
+}
+
+type importTx struct {
+	store   *importStore
+	pending []models.Post
+}
+
+func (t *importTx) Insert(posts []models.Post) ([]models.Post, error) {
+	for _, post := range posts {
+		if post.Title == "bad" {
+			return nil, errors.New("constraint violated")
+		}
+	}
+	for i := range posts {
+		posts[i].ID = len(t.store.committed) + len(t.pending) + 1
+		t.pending = append(t.pending, posts[i])
+	}
+	return posts, nil
+}
+
+func (t *importTx) Commit() error {
+	t.store.committed = append(t.store.committed, t.pending...)
+	return nil
+}
+
+func (t *importTx) Rollback() error { return nil }
+
+func ndjson(titles ...string) string {
+	var b strings.Builder
+	for _, title := range titles {
+		b.WriteString(`{"title":"` + title + `","content":"Body","author":"Ann"}` + "\n")
+	}
+	return b.String()
+}
+
+func statuses(report *ImportReport) string {
+	var s []string
+	for _, res := range report.Results {
+		s = append(s, res.Status)
+	}
+	return strings.Join(s, " ")
+}
+
+func TestImport(t *testing.T) {
+	tests := []struct {
+		name      string
+		input     string
+		atomic    bool
+		statuses  string
+		committed int
+		inputErr  bool
+	}{
+		{"all good", ndjson("One", "Two"), false, "created created", 2, false},
+		{"bad row fails alone", ndjson("One", "bad", "Three"), false, "created failed created", 2, false},
+		{"atomic bad row", ndjson("One", "bad", "Three"), true, "rolled_back failed rolled_back", 0, false},
+		{"broken input", ndjson("One") + "{not json\n" + strings.Repeat("x", importMaxLine+1), false, "created failed", 1, true},
+		{"atomic broken input", ndjson("One") + strings.Repeat("x", importMaxLine+1), true, "rolled_back", 0, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store := &importStore{}
+			report, err := Import(context.Background(), store, strings.NewReader(tt.input), ImportOptions{Format: "ndjson", Atomic: tt.atomic})
+
+			var inputErr *InputError
+			if errors.As(err, &inputErr) != tt.inputErr || (err != nil && !tt.inputErr) {
+				t.Fatalf("Import error = %v, want input error %v", err, tt.inputErr)
+			}
+			if report == nil {
+				t.Fatal("Import returned no report")
+			}
+			if tt.inputErr && report.Error == "" {
+				t.Error("report of a stopped import has no error")
+			}
+			if got := statuses(report); got != tt.statuses {
+				t.Errorf("statuses = %q, want %q", got, tt.statuses)
+			}
+			if len(store.committed) != tt.committed {
+				t.Errorf("committed %d posts, want %d", len(store.committed), tt.committed)
+			}
+			if report.Committed != (tt.committed > 0) {
+				t.Errorf("report.Committed = %v with %d posts committed", report.Committed, tt.committed)
+			}
+		})
+	}
+}
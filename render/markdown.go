@@ -0,0 +1,150 @@
+package render
+
+import (
+	"blog-api/cache"
+	"blog-api/models"
+	"bytes"
+	"fmt"
+	"html"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/microcosm-cc/bluemonday"
+	"github.com/yuin/goldmark"
+	"github.com/yuin/goldmark/ast"
+	"github.com/yuin/goldmark/extension"
+	"github.com/yuin/goldmark/parser"
+	"github.com/yuin/goldmark/text"
+)
+
+// Output formats accepted by the ?format= parameter.
+const (
+	FormatMarkdown = "markdown"
+	FormatHTML     = "html"
+	FormatText     = "text"
+)
+
+// ParseFormat validates a ?format= value; empty means markdown.
+func ParseFormat(value string) (string, error) {
+	switch value {
+	case "", FormatMarkdown:
+		return FormatMarkdown, nil
+	case FormatHTML, FormatText:
+		return value, nil
+	}
+	return "", fmt.Errorf("format must be one of: markdown, html, text")
+}
+
+// DefaultCacheBytes is a reasonable bound on memory used by rendered posts.
+const DefaultCacheBytes = 32 << 20
+
+// blankLines collapses the gaps left behind by stripped block elements.
+var blankLines = regexp.MustCompile(`\n(?:[ \t]*\n)+`)
+
+// Rendered is the output of rendering one post's Markdown content.
+type Rendered struct {
+	HTML string
+	Text string
+	TOC  []models.TOCEntry
+}
+
+// Renderer turns CommonMark (with GFM tables, strikethrough, task lists and
+// autolinks) into sanitized HTML. Results are cached per post version.
+type Renderer struct {
+	md     goldmark.Markdown
+	policy *bluemonday.Policy
+	text   *bluemonday.Policy
+	cache  *cache.LRU
+}
+
+// NewRenderer creates a Renderer whose cache holds at most cacheBytes of
+// rendered output.
+func NewRenderer(cacheBytes int64) *Renderer {
+	md := goldmark.New(
+		goldmark.WithExtensions(extension.GFM),
+		goldmark.WithParserOptions(parser.WithAutoHeadingID()),
+	)
+
+	// Start from the user-generated-content policy and allow only what the
+	// renderer itself emits: heading ids for anchors and language classes on
+	// fenced code for client-side highlighting.
+	policy := bluemonday.UGCPolicy()
+	policy.AllowAttrs("id").Matching(regexp.MustCompile(`^[A-Za-z0-9_-]+$`)).
+		OnElements("h1", "h2", "h3", "h4", "h5", "h6")
+	policy.AllowAttrs("class").Matching(regexp.MustCompile(`^language-[A-Za-z0-9_+#.-]+$`)).
+		OnElements("code")
+	policy.AllowAttrs("type", "checked", "disabled").OnElements("input")
+	policy.AddTargetBlankToFullyQualifiedLinks(true)
+
+	return &Renderer{
+		md:     md,
+		policy: policy,
+		text:   bluemonday.StrictPolicy(),
+		cache:  cache.NewLRU(cacheBytes, 24*time.Hour),
+	}
+}
+
+// Render returns the post's content as sanitized HTML, plain text and a
+// table of contents. A post version is identified by its id and UpdatedAt.
+func (r *Renderer) Render(post models.Post) (*Rendered, error) {
+	key := fmt.Sprintf("%d@%d", post.ID, post.UpdatedAt.UnixNano())
+	if v, ok := r.cache.Get(key); ok {
+		return v.(*Rendered), nil
+	}
+
+	out, err := r.render([]byte(post.Content))
+	if err != nil {
+		return nil, err
+	}
+
+	size := int64(len(out.HTML) + len(out.Text))
+	for _, e := range out.TOC {
+		size += int64(len(e.ID) + len(e.Text) + 16)
+	}
+	r.cache.Set(key, out, size)
+	return out, nil
+}
+
+func (r *Renderer) render(source []byte) (*Rendered, error) {
+	doc := r.md.Parser().Parse(text.NewReader(source))
+
+	var buf bytes.Buffer
+	if err := r.md.Renderer().Render(&buf, source, doc); err != nil {
+		return nil, err
+	}
+
+	safe := r.policy.SanitizeBytes(buf.Bytes())
+	plain := html.UnescapeString(r.text.Sanitize(string(safe)))
+	plain = blankLines.ReplaceAllString(plain, "\n\n")
+
+	return &Rendered{
+		HTML: string(safe),
+		Text: strings.TrimSpace(plain),
+		TOC:  tableOfContents(doc, source),
+	}, nil
+}
+
+// tableOfContents lists the document's headings in order.
+func tableOfContents(doc ast.Node, source []byte) []models.TOCEntry {
+	var toc []models.TOCEntry
+	ast.Walk(doc, func(n ast.Node, entering bool) (ast.WalkStatus, error) {
+		heading, ok := n.(*ast.Heading)
+		if !entering || !ok {
+			return ast.WalkContinue, nil
+		}
+
+		entry := models.TOCEntry{
+			Level: heading.Level,
+			Text:  string(heading.Text(source)),
+		}
+		if id, ok := heading.AttributeString("id"); ok {
+			if b, ok := id.([]byte); ok {
+				entry.ID = string(b)
+			}
+		}
+		toc = append(toc, entry)
+		return ast.WalkSkipChildren, nil
+	})
+	return toc
+}
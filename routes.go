@@ -0,0 +1,142 @@
+package main
+
+import (
+	"blog-api/handlers"
+	"blog-api/middleware"
+	"blog-api/openapi"
+	"expvar"
+	"fmt"
+	"net/http"
+
+	"github.com/gorilla/mux"
+)
+
+// routeHandlers are the handlers newRouter dispatches to.
+type routeHandlers struct {
+	health     *handlers.HealthHandler
+	post       *handlers.PostHandler
+	feed       *handlers.FeedHandler
+	sitemap    *handlers.SitemapHandler
+	webhook    *handlers.WebhookHandler
+	stream     *handlers.StreamHandler
+	changeFeed *handlers.ChangeFeedHandler
+	trash      *handlers.TrashHandler
+	graphql    *handlers.GraphQLHandler
+}
+
+// routeOptions configures the middleware newRouter applies.
+type routeOptions struct {
+	// adminToken guards webhook management and the trash; empty keeps them
+	// closed
+	adminToken string
+	// idempotency replays responses to retried unsafe requests; nil skips it
+	idempotency func(http.Handler) http.Handler
+}
+
+// newRouter registers every route the server answers. Each one must be
+// described in openapi/openapi.json; routes_test.go checks that they match.
+func newRouter(h routeHandlers, opts routeOptions) *mux.Router {
+	r := mux.NewRouter()
+
+	// Idempotency keys are scoped by client, so idempotency runs after
+	// ClientID
+	idempotency := opts.idempotency
+	if idempotency == nil {
+		idempotency = func(next http.Handler) http.Handler { return next }
+	}
+
+	// Health probes are registered on the root router so they are never
+	// rate limited
+	r.HandleFunc("/livez", h.health.Livez).Methods("GET")
+	r.HandleFunc("/readyz", h.health.Readyz).Methods("GET")
+	r.HandleFunc("/api/v1/health", h.health.Readyz).Methods("GET")
+
+	// Feeds, filtered by ?author= and ?search=
+	r.HandleFunc("/feeds/rss.xml", h.feed.RSS).Methods("GET", "HEAD")
+	r.HandleFunc("/feeds/atom.xml", h.feed.Atom).Methods("GET", "HEAD")
+	r.HandleFunc("/feeds/feed.json", h.feed.JSONFeed).Methods("GET", "HEAD")
+
+	// Crawler discovery
+	r.HandleFunc("/sitemap.xml", h.sitemap.Sitemap).Methods("GET")
+	r.HandleFunc("/sitemaps/posts-{page:[0-9]+}.xml", h.sitemap.SitemapPage).Methods("GET")
+	r.HandleFunc("/robots.txt", h.sitemap.Robots).Methods("GET")
+
+	// GraphQL, limited like the REST API
+	r.Handle("/graphql", middleware.RateLimit(middleware.ClientID(idempotency(
+		http.HandlerFunc(h.graphql.ServeGraphQL))))).Methods("GET", "POST")
+
+	// API routes
+	api := r.PathPrefix("/api/v1").Subrouter()
+
+	// API description
+	api.HandleFunc("/openapi.json", openapi.ServeSpec).Methods("GET")
+	api.HandleFunc("/docs", openapi.ServeDocs).Methods("GET")
+
+	// Posts endpoints
+	api.HandleFunc("/posts", h.post.GetAllPosts).Methods("GET")
+	api.HandleFunc("/posts/paginated", h.post.GetPostsPaginated).Methods("GET")
+	api.HandleFunc("/posts", h.post.CreatePost).Methods("POST")
+	api.HandleFunc("/posts:import", h.post.ImportPosts).Methods("POST")
+	api.HandleFunc("/posts:export", h.post.ExportPosts).Methods("GET")
+	api.HandleFunc("/posts/stream", h.stream.StreamPosts).Methods("GET")
+	api.HandleFunc("/posts/changes", h.changeFeed.GetChanges).Methods("GET")
+
+	// The trash shows deleted content and can destroy it for good, so it is
+	// for holders of the admin token only
+	trash := api.PathPrefix("/posts/trash").Subrouter()
+	trash.Use(middleware.AdminToken(opts.adminToken))
+	trash.HandleFunc("", h.trash.ListTrash).Methods("GET")
+	trash.HandleFunc("/{id:[0-9]+}:restore", h.trash.RestorePost).Methods("POST")
+	trash.HandleFunc("/{id:[0-9]+}", h.trash.PurgePost).Methods("DELETE")
+
+	api.HandleFunc("/posts/by-slug/{slug}", h.post.GetPostBySlug).Methods("GET")
+	api.HandleFunc("/posts/{id}", h.post.GetPost).Methods("GET")
+	api.HandleFunc("/posts/{id}", h.post.UpdatePost).Methods("PUT")
+	api.HandleFunc("/posts/{id}", h.post.DeletePost).Methods("DELETE")
+
+	// Webhook subscriptions, for holders of the admin token only
+	hooks := api.PathPrefix("/webhooks").Subrouter()
+	hooks.Use(middleware.AdminToken(opts.adminToken))
+	hooks.HandleFunc("", h.webhook.ListWebhooks).Methods("GET")
+	hooks.HandleFunc("", h.webhook.CreateWebhook).Methods("POST")
+	hooks.HandleFunc("/{id:[0-9]+}:ping", h.webhook.PingWebhook).Methods("POST")
+	hooks.HandleFunc("/{id:[0-9]+}", h.webhook.GetWebhook).Methods("GET")
+	hooks.HandleFunc("/{id:[0-9]+}", h.webhook.UpdateWebhook).Methods("PUT")
+	hooks.HandleFunc("/{id:[0-9]+}", h.webhook.DeleteWebhook).Methods("DELETE")
+	hooks.HandleFunc("/{id:[0-9]+}/deliveries", h.webhook.ListDeliveries).Methods("GET")
+	hooks.HandleFunc("/{id:[0-9]+}/deliveries/{delivery:[0-9]+}:redeliver", h.webhook.Redeliver).Methods("POST")
+
+	// Apply rate limiting to API endpoints
+	api.Use(middleware.RateLimit)
+	api.Use(middleware.ClientID)
+	api.Use(idempotency)
+
+	return r
+}
+
+// newAdminRouter serves runtime and cache counters. It runs on its own
+// listener (ADMIN_ADDR) and is not part of the public API.
+func newAdminRouter() *mux.Router {
+	r := mux.NewRouter()
+	r.HandleFunc("/debug/vars", debugVars).Methods("GET")
+	return r
+}
+
+// debugVars writes the published expvars like expvar.Handler, except
+// cmdline: command-line flags can hold secrets such as --db-password.
+func debugVars(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	fmt.Fprint(w, "{\n")
+	first := true
+	expvar.Do(func(kv expvar.KeyValue) {
+		if kv.Key == "cmdline" {
+			return
+		}
+		if !first {
+			fmt.Fprint(w, ",\n")
+		}
+		first = false
+		fmt.Fprintf(w, "%q: %s", kv.Key, kv.Value)
+	})
+	fmt.Fprint(w, "\n}\n")
+}
@@ -0,0 +1,99 @@
+package main
+
+import (
+	"blog-api/openapi"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/gorilla/mux"
+)
+
+// TestRoutesMatchOpenAPISpec fails when a route is added without being
+// described in openapi/openapi.json, or the spec describes a route that no
+// longer exists.
+func TestRoutesMatchOpenAPISpec(t *testing.T) {
+	doc, err := openapi.Spec()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	documented := map[string]bool{}
+	for path, item := range doc.Paths {
+		for method := range item {
+			documented[strings.ToUpper(method)+" "+path] = true
+		}
+	}
+
+	registered := map[string]bool{}
+	err = newRouter(routeHandlers{}, routeOptions{}).Walk(func(route *mux.Route, _ *mux.Router, _ []*mux.Route) error {
+		tmpl, err := route.GetPathTemplate()
+		if err != nil {
+			return nil
+		}
+		// Subrouter prefixes have no methods of their own
+		methods, err := route.GetMethods()
+		if err != nil {
+			return nil
+		}
+		for _, method := range methods {
+			// HEAD is served alongside GET and documented with it
+			if method == http.MethodHead {
+				continue
+			}
+			registered[method+" "+openapi.PathTemplate(tmpl)] = true
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, route := range sortedKeys(registered) {
+		if !documented[route] {
+			t.Errorf("%s is routed but missing from openapi.json", route)
+		}
+	}
+	for _, route := range sortedKeys(documented) {
+		if !registered[route] {
+			t.Errorf("%s is in openapi.json but not routed", route)
+		}
+	}
+}
+
+// TestAdminRoutesNeedToken checks that routes holding deleted content or
+// webhook secrets refuse requests without the admin token.
+func TestAdminRoutesNeedToken(t *testing.T) {
+	router := newRouter(routeHandlers{}, routeOptions{adminToken: "secret"})
+	for _, route := range []string{
+		"GET /api/v1/posts/trash",
+		"POST /api/v1/posts/trash/1:restore",
+		"DELETE /api/v1/posts/trash/1",
+		"GET /api/v1/webhooks",
+		"DELETE /api/v1/webhooks/1",
+	} {
+		for _, auth := range []string{"", "Bearer wrong"} {
+			method, path, _ := strings.Cut(route, " ")
+			req := httptest.NewRequest(method, path, nil)
+			if auth != "" {
+				req.Header.Set("Authorization", auth)
+			}
+			rec := httptest.NewRecorder()
+			router.ServeHTTP(rec, req)
+			if rec.Code != http.StatusUnauthorized {
+				t.Errorf("%s with Authorization %q = %d, want 401", route, auth, rec.Code)
+			}
+		}
+	}
+}
+
+func sortedKeys(m map[string]bool) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,370 @@
+package storage
+
+import (
+	"blog-api/cache"
+	"blog-api/models"
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+// Rough per-value overhead added to string lengths when sizing entries.
+const postOverhead = 128
+
+// CachedStore is a read-through cache in front of another PostStore. Single
+// posts and paginated listings are cached; writes invalidate them.
+//
+// Shortly after an invalidation the cache is filled from the primary
+// database only, for as long as the read-your-writes window of a
+// PostgresStore underneath; other misses may be served by replicas.
+type CachedStore struct {
+	next  PostStore
+	lru   *cache.LRU
+	group cache.Group
+
+	// generation is bumped on every invalidation. Loads that started under
+	// an older generation do not populate the cache, so a slow read racing
+	// a write cannot store stale data.
+	generation atomic.Uint64
+	// invalidated is when the last invalidation happened, in Unix
+	// nanoseconds.
+	invalidated atomic.Int64
+}
+
+func NewCachedStore(next PostStore, maxBytes int64, ttl time.Duration) *CachedStore {
+	return &CachedStore{
+		next: next,
+		lru:  cache.NewLRU(maxBytes, ttl),
+	}
+}
+
+// Stats reports cache hit, miss and size counters.
+func (s *CachedStore) Stats() cache.Stats {
+	return s.lru.Stats()
+}
+
+// Invalidate drops the cached copy of a post and every cached listing and
+// slug lookup.
+func (s *CachedStore) Invalidate(id int) {
+	s.bump()
+	s.lru.Remove(postKey(id))
+	s.lru.RemoveFunc(isDerivedKey)
+}
+
+// invalidateDerived drops every cached listing and slug lookup, e.g. after an
+// insert.
+func (s *CachedStore) invalidateDerived() {
+	s.bump()
+	s.lru.RemoveFunc(isDerivedKey)
+}
+
+// InvalidateAll empties the cache.
+func (s *CachedStore) InvalidateAll() {
+	s.bump()
+	s.lru.Purge()
+}
+
+// bump starts a new generation, so loads already under way are not cached.
+func (s *CachedStore) bump() {
+	s.invalidated.Store(time.Now().UnixNano())
+	s.generation.Add(1)
+}
+
+// fillContext returns the context a cache fill reads under.
+func (s *CachedStore) fillContext(ctx context.Context) context.Context {
+	return withCacheFill(ctx, time.Unix(0, s.invalidated.Load()))
+}
+
+func postKey(id int) string {
+	return "post:" + strconv.Itoa(id)
+}
+
+func listKey(q models.PostQuery) string {
+	return fmt.Sprintf("list:%q|%d|%q|%q|%q|%q", q.Cursor, q.Limit, q.SortBy, q.SortDir, q.Author, q.Search)
+}
+
+func slugKey(slug string) string {
+	return "slug:" + slug
+}
+
+// isDerivedKey matches entries that may change when any post changes:
+// listings, and slug lookups, which can't be traced back to a post id.
+func isDerivedKey(key string) bool {
+	return strings.HasPrefix(key, "list:") || strings.HasPrefix(key, "slug:")
+}
+
+func postSize(p *models.Post) int64 {
+	return int64(len(p.Title)+len(p.Content)+len(p.Author)) + postOverhead
+}
+
+// load returns the cached value for key or calls fn once for all concurrent
+// misses on that key. fn reports the value's size; a negative size means the
+// value is returned but not cached.
+func (s *CachedStore) load(ctx context.Context, key string, fn func(ctx context.Context) (interface{}, int64, error)) (interface{}, error) {
+	if v, ok := s.lru.Get(key); ok {
+		return v, nil
+	}
+
+	return s.group.Do(key, func() (interface{}, error) {
+		gen := s.generation.Load()
+
+		// Other callers share this load, so it must not be cut short when
+		// the first caller goes away.
+		v, size, err := fn(s.fillContext(context.WithoutCancel(ctx)))
+		if err != nil {
+			return nil, err
+		}
+
+		if size >= 0 && s.generation.Load() == gen {
+			s.lru.Set(key, v, size)
+		}
+		return v, nil
+	})
+}
+
+func (s *CachedStore) GetPostsPaginated(ctx context.Context, query models.PostQuery) (*models.PaginatedPosts, error) {
+	v, err := s.load(ctx, listKey(query), func(ctx context.Context) (interface{}, int64, error) {
+		page, err := s.next.GetPostsPaginated(ctx, query)
+		if err != nil {
+			return nil, 0, err
+		}
+
+		size := int64(len(page.NextCursor)+len(page.PrevCursor)) + postOverhead
+		for i := range page.Posts {
+			size += postSize(&page.Posts[i])
+		}
+		return page, size, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	page := *v.(*models.PaginatedPosts)
+	page.Posts = append([]models.Post(nil), page.Posts...)
+	return &page, nil
+}
+
+// GetAll bypasses the cache; full-table results are too large to be worth
+// keeping.
+func (s *CachedStore) GetAll(ctx context.Context) ([]models.Post, error) {
+	return s.next.GetAll(ctx)
+}
+
+func (s *CachedStore) GetByID(ctx context.Context, id int) (*models.Post, error) {
+	v, err := s.load(ctx, postKey(id), func(ctx context.Context) (interface{}, int64, error) {
+		post, err := s.next.GetByID(ctx, id)
+		if err != nil {
+			return nil, 0, err
+		}
+		if post == nil {
+			// Not-found results are not cached
+			return (*models.Post)(nil), -1, nil
+		}
+		return post, postSize(post), nil
+	})
+	if err != nil || v.(*models.Post) == nil {
+		return nil, err
+	}
+
+	post := *v.(*models.Post)
+	return &post, nil
+}
+
+// GetByIDs serves what it can from the cache and fetches the rest in one
+// batch.
+func (s *CachedStore) GetByIDs(ctx context.Context, ids []int) ([]models.Post, error) {
+	var posts []models.Post
+	var missing []int
+	for _, id := range ids {
+		if v, ok := s.lru.Get(postKey(id)); ok {
+			posts = append(posts, *v.(*models.Post))
+		} else {
+			missing = append(missing, id)
+		}
+	}
+	if len(missing) == 0 {
+		return posts, nil
+	}
+
+	gen := s.generation.Load()
+	fetched, err := getByIDs(s.fillContext(ctx), s.next, missing)
+	if err != nil {
+		return nil, err
+	}
+	for i := range fetched {
+		if s.generation.Load() == gen {
+			post := fetched[i]
+			s.lru.Set(postKey(post.ID), &post, postSize(&post))
+		}
+		posts = append(posts, fetched[i])
+	}
+	return posts, nil
+}
+
+func (s *CachedStore) GetBySlug(ctx context.Context, slug string) (*models.Post, error) {
+	v, err := s.load(ctx, slugKey(slug), func(ctx context.Context) (interface{}, int64, error) {
+		post, err := s.next.GetBySlug(ctx, slug)
+		if err != nil {
+			return nil, 0, err
+		}
+		if post == nil {
+			return (*models.Post)(nil), -1, nil
+		}
+		return post, postSize(post), nil
+	})
+	if err != nil || v.(*models.Post) == nil {
+		return nil, err
+	}
+
+	post := *v.(*models.Post)
+	return &post, nil
+}
+
+func (s *CachedStore) Create(ctx context.Context, post models.Post) (*models.Post, error) {
+	created, err := s.next.Create(ctx, post)
+	if err == nil {
+		s.invalidateDerived()
+	}
+	return created, err
+}
+
+func (s *CachedStore) Update(ctx context.Context, id int, post models.Post) (*models.Post, error) {
+	updated, err := s.next.Update(ctx, id, post)
+	if err == nil {
+		s.Invalidate(id)
+	}
+	return updated, err
+}
+
+func (s *CachedStore) Delete(ctx context.Context, id int) error {
+	err := s.next.Delete(ctx, id)
+	if err == nil {
+		s.Invalidate(id)
+	}
+	return err
+}
+
+func (s *CachedStore) Remove(ctx context.Context, id int) (bool, error) {
+	removed, err := removePost(ctx, s.next, id)
+	if err == nil {
+		s.Invalidate(id)
+	}
+	return removed, err
+}
+
+func (s *CachedStore) Close() error {
+	return s.next.Close()
+}
+
+func (s *CachedStore) BeginImport(ctx context.Context) (ImportTx, error) {
+	tx, err := beginImport(ctx, s.next)
+	if err != nil {
+		return nil, err
+	}
+	return &cachedImportTx{ImportTx: tx, store: s}, nil
+}
+
+// cachedImportTx drops cached listings once imported posts become visible.
+type cachedImportTx struct {
+	ImportTx
+	store *CachedStore
+}
+
+func (t *cachedImportTx) Commit() error {
+	err := t.ImportTx.Commit()
+	if err == nil {
+		t.store.invalidateDerived()
+	}
+	return err
+}
+
+// ExportPosts bypasses the cache.
+func (s *CachedStore) ExportPosts(ctx context.Context, query models.PostQuery, fn func(models.Post) error) error {
+	return exportPosts(ctx, s.next, query, fn)
+}
+
+// MaxPostID bypasses the cache.
+func (s *CachedStore) MaxPostID(ctx context.Context) (int, error) {
+	reader, err := rangeReaderOf(s.next)
+	if err != nil {
+		return 0, err
+	}
+	return reader.MaxPostID(ctx)
+}
+
+// ExportRange bypasses the cache.
+func (s *CachedStore) ExportRange(ctx context.Context, after, through int, fn func(models.Post) error) error {
+	reader, err := rangeReaderOf(s.next)
+	if err != nil {
+		return err
+	}
+	return reader.ExportRange(ctx, after, through, fn)
+}
+
+// CountPosts bypasses the cache.
+func (s *CachedStore) CountPosts(ctx context.Context, query models.PostQuery) (int, error) {
+	return countPosts(ctx, s.next, query)
+}
+
+// CountByAuthor bypasses the cache.
+func (s *CachedStore) CountByAuthor(ctx context.Context, authors []string) (map[string]int, error) {
+	reader, err := authorReaderOf(s.next)
+	if err != nil {
+		return nil, err
+	}
+	return reader.CountByAuthor(ctx, authors)
+}
+
+// GetAuthorPages bypasses the cache.
+func (s *CachedStore) GetAuthorPages(ctx context.Context, authors []string, query models.PostQuery) (map[string]*models.PaginatedPosts, error) {
+	reader, err := authorReaderOf(s.next)
+	if err != nil {
+		return nil, err
+	}
+	return reader.GetAuthorPages(ctx, authors, query)
+}
+
+// PostChanges bypasses the cache.
+func (s *CachedStore) PostChanges(ctx context.Context, since models.ChangeToken, limit int) (*models.ChangeSet, error) {
+	return postChanges(ctx, s.next, since, limit)
+}
+
+// ListTrash bypasses the cache; trashed posts are never cached.
+func (s *CachedStore) ListTrash(ctx context.Context, limit int) ([]models.Post, error) {
+	trash, err := trashOf(s.next)
+	if err != nil {
+		return nil, err
+	}
+	return trash.ListTrash(ctx, limit)
+}
+
+func (s *CachedStore) Restore(ctx context.Context, id int) (*models.Post, error) {
+	trash, err := trashOf(s.next)
+	if err != nil {
+		return nil, err
+	}
+	post, err := trash.Restore(ctx, id)
+	if err == nil {
+		s.Invalidate(id)
+	}
+	return post, err
+}
+
+func (s *CachedStore) Purge(ctx context.Context, id int) (bool, error) {
+	trash, err := trashOf(s.next)
+	if err != nil {
+		return false, err
+	}
+	return trash.Purge(ctx, id)
+}
+
+func (s *CachedStore) LastDeleted(ctx context.Context) (time.Time, error) {
+	trash, err := trashOf(s.next)
+	if err != nil {
+		return time.Time{}, err
+	}
+	return trash.LastDeleted(ctx)
+}
@@ -0,0 +1,65 @@
+package storage_test
+
+import (
+	"blog-api/storage"
+	"blog-api/storage/storagetest"
+	"os"
+	"testing"
+	"time"
+)
+
+func TestInMemoryPostStore(t *testing.T) {
+	storagetest.Run(t, func(t *testing.T) storage.PostStore {
+		return storage.NewInMemoryPostStore()
+	})
+}
+
+// TestPostgresStore runs the suite against the database in
+// TEST_DATABASE_URL. It writes posts there, so never point it at real data.
+func TestPostgresStore(t *testing.T) {
+	dsn := testDatabaseURL(t)
+	storagetest.Run(t, func(t *testing.T) storage.PostStore {
+		return openPostgres(t, dsn)
+	})
+}
+
+// The server wraps its store in the cache and tracing decorators, which must
+// not change what the store answers.
+
+func TestDecoratedInMemoryStore(t *testing.T) {
+	storagetest.Run(t, func(t *testing.T) storage.PostStore {
+		return decorate(storage.NewInMemoryPostStore())
+	})
+}
+
+func TestDecoratedPostgresStore(t *testing.T) {
+	dsn := testDatabaseURL(t)
+	storagetest.Run(t, func(t *testing.T) storage.PostStore {
+		return decorate(openPostgres(t, dsn))
+	})
+}
+
+// decorate builds the chain main uses.
+func decorate(store storage.PostStore) storage.PostStore {
+	return storage.NewCachedStore(storage.NewTracedStore(store), 1<<20, time.Minute)
+}
+
+func testDatabaseURL(t *testing.T) string {
+	dsn := os.Getenv("TEST_DATABASE_URL")
+	if dsn == "" {
+		t.Skip("TEST_DATABASE_URL not set")
+	}
+	return dsn
+}
+
+func openPostgres(t *testing.T, dsn string) *storage.PostgresStore {
+	store, err := storage.NewPostgresStore(dsn, storage.PostgresOptions{ConnectTimeout: 5 * time.Second})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { store.Close() })
+	if err := store.Init(); err != nil {
+		t.Fatal(err)
+	}
+	return store
+}
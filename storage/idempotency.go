@@ -0,0 +1,57 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"time"
+)
+
+// ErrLeaseLost is returned when a request no longer holds the
+// Idempotency-Key it reserved, because its lease ran out and a retry took
+// the key over.
+var ErrLeaseLost = errors.New("idempotency key lease lost")
+
+// IdempotencyStore remembers the first response sent for each
+// Idempotency-Key so retried requests can be answered with it. Keys are
+// scoped: the same key sent by two clients names two records.
+type IdempotencyStore interface {
+	// ReserveIdempotencyKey claims an unused or expired key for a new
+	// request, keeping it for ttl, and returns the lease the request
+	// holds it by. If the key is taken it returns the stored record
+	// instead.
+	ReserveIdempotencyKey(ctx context.Context, scope, key, fingerprint string, ttl time.Duration) (*IdempotencyLease, *IdempotencyRecord, error)
+	// RenewIdempotencyKey extends a lease while its request is running.
+	RenewIdempotencyKey(ctx context.Context, lease IdempotencyLease) error
+	// CompleteIdempotencyKey stores the response to a reserved key.
+	CompleteIdempotencyKey(ctx context.Context, lease IdempotencyLease, resp IdempotentResponse) error
+	// ReleaseIdempotencyKey frees a reserved key so the request can be
+	// retried, e.g. after a server error.
+	ReleaseIdempotencyKey(ctx context.Context, lease IdempotencyLease) error
+}
+
+// IdempotencyLease is one request's hold on a key. Renew, complete and
+// release only succeed while the lease is current, and fail with
+// ErrLeaseLost otherwise.
+type IdempotencyLease struct {
+	Scope string
+	Key   string
+	Token string
+
+	// Duration is how long the lease lasts without being renewed.
+	Duration time.Duration
+}
+
+// IdempotencyRecord is a key's fingerprint of the request that claimed it
+// and, once that request has finished, its response.
+type IdempotencyRecord struct {
+	Fingerprint string
+	Response    *IdempotentResponse // nil while the first request is running
+}
+
+// IdempotentResponse is a stored response, replayed to retries.
+type IdempotentResponse struct {
+	StatusCode int
+	Header     http.Header
+	Body       []byte
+}
@@ -1,102 +1,252 @@
 package storage
 
-// import (
-// 	"blog-api/models"
-// 	"sync"
-// 	"time"
-// )
-
-// type PostStore interface {
-// 	GetAll() ([]models.Post, error)
-// 	GetByID(id int) (*models.Post, error)
-// 	Create(post models.Post) (*models.Post, error)
-// 	Update(id int, post models.Post) (*models.Post, error)
-// 	Delete(id int) error
-// }
-
-// type InMemoryPostStore struct {
-// 	mu    sync.RWMutex
-// 	posts map[int]models.Post
-// 	id    int
-// }
-
-// func NewInMemoryPostStore() *InMemoryPostStore {
-// 	return &InMemoryPostStore{
-// 		posts: make(map[int]models.Post),
-// 		id:    1,
-// 	}
-// }
-
-// func (ps *InMemoryPostStore) GetAll() ([]models.Post, error) {
-// 	ps.mu.RLock()
-// 	defer ps.mu.RUnlock()
-
-// 	posts := make([]models.Post, 0, len(ps.posts))
-
-// 	for _, post := range ps.posts {
-// 		posts = append(posts, post)
-// 	}
-// 	return posts, nil
-// }
-
-// func (ps *InMemoryPostStore) GetByID(id int) (*models.Post, error) {
-// 	ps.mu.RLock()
-// 	defer ps.mu.RUnlock()
-
-// 	post, exists := ps.posts[id]
-// 	if !exists {
-// 		return nil, nil
-// 	}
-// 	return &post, nil
-// }
-
-// func (ps *InMemoryPostStore) Create(post models.Post) (*models.Post, error) {
-// 	ps.mu.Lock()
-// 	defer ps.mu.Unlock()
-
-// 	post.ID = ps.id
-// 	post.CreatedAt = time.Now()
-// 	post.UpdatedAt = time.Now()
-
-// 	ps.posts[ps.id] = post
-// 	ps.id++
-
-// 	return &post, nil
-// }
-
-// func (s *InMemoryPostStore) Update(id int, updated models.Post) (*models.Post, error) {
-// 	s.mu.Lock()
-// 	defer s.mu.Unlock()
-
-// 	existing, exists := s.posts[id]
-// 	if !exists {
-// 		return nil, nil
-// 	}
-
-// 	if updated.Title != "" {
-// 		existing.Title = updated.Title
-// 	}
-// 	if updated.Content != "" {
-// 		existing.Content = updated.Content
-// 	}
-// 	if updated.Author != "" {
-// 		existing.Author = updated.Author
-// 	}
-
-// 	existing.UpdatedAt = time.Now()
-// 	s.posts[id] = existing
-
-// 	return &existing, nil
-// }
-
-// func (s *InMemoryPostStore) Delete(id int) error {
-// 	s.mu.Lock()
-// 	defer s.mu.Unlock()
-
-// 	if _, exists := s.posts[id]; !exists {
-// 		return nil
-// 	}
-
-// 	delete(s.posts, id)
-// 	return nil
-// }
+import (
+	"blog-api/models"
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// InMemoryPostStore keeps posts in memory with the same listing, slug and
+// not-found semantics as PostgresStore. Deleted posts are gone for good;
+// it has no trash. It is meant for tests and local experiments.
+type InMemoryPostStore struct {
+	mu        sync.RWMutex
+	posts     map[int]models.Post
+	redirects map[string]int // old slug -> post ID
+	id        int
+}
+
+func NewInMemoryPostStore() *InMemoryPostStore {
+	return &InMemoryPostStore{
+		posts:     make(map[int]models.Post),
+		redirects: make(map[string]int),
+		id:        1,
+	}
+}
+
+// now matches the microsecond resolution of Postgres timestamps.
+func now() time.Time {
+	return time.Now().Truncate(time.Microsecond)
+}
+
+func (s *InMemoryPostStore) GetPostsPaginated(ctx context.Context, query models.PostQuery) (*models.PaginatedPosts, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	less := postLess(query)
+	var cursor *models.Post
+	if query.Cursor != "" {
+		c, err := models.DecodeCursor(query.Cursor)
+		if err == nil && c.SortBy == sortColumn(query) {
+			cursor = &models.Post{ID: c.ID, CreatedAt: c.CreatedAt, UpdatedAt: c.UpdatedAt, Title: c.Title}
+		}
+	}
+
+	var posts []models.Post
+	for _, post := range s.posts {
+		if !matchesQuery(post, query) {
+			continue
+		}
+		if cursor != nil && !less(*cursor, post) {
+			continue
+		}
+		posts = append(posts, post)
+	}
+	sort.Slice(posts, func(i, j int) bool { return less(posts[i], posts[j]) })
+
+	page := &models.PaginatedPosts{Posts: posts}
+	if len(posts) > query.Limit {
+		page.Posts = posts[:query.Limit]
+		page.NextCursor = models.NewCursor(page.Posts[query.Limit-1], query.SortBy).Encode()
+		page.HasMore = true
+	}
+	if query.Cursor != "" && len(page.Posts) > 0 {
+		page.PrevCursor = models.NewCursor(page.Posts[0], query.SortBy).Encode()
+	}
+	return page, nil
+}
+
+// postLess orders posts as query lists them, with ID breaking ties.
+func postLess(query models.PostQuery) func(a, b models.Post) bool {
+	compare := func(a, b models.Post) int {
+		switch sortColumn(query) {
+		case "updated_at":
+			return a.UpdatedAt.Compare(b.UpdatedAt)
+		case "title":
+			return strings.Compare(a.Title, b.Title)
+		}
+		return a.CreatedAt.Compare(b.CreatedAt)
+	}
+	return func(a, b models.Post) bool {
+		c := compare(a, b)
+		if c == 0 {
+			c = a.ID - b.ID
+		}
+		if query.SortDir == "asc" {
+			return c < 0
+		}
+		return c > 0
+	}
+}
+
+// matchesQuery applies the author and search filters. Search is a
+// case-insensitive substring match on title and content, like ILIKE.
+func matchesQuery(post models.Post, query models.PostQuery) bool {
+	if query.Author != "" && post.Author != query.Author {
+		return false
+	}
+	if query.Search != "" {
+		term := strings.ToLower(query.Search)
+		return strings.Contains(strings.ToLower(post.Title), term) ||
+			strings.Contains(strings.ToLower(post.Content), term)
+	}
+	return true
+}
+
+func (s *InMemoryPostStore) GetAll(ctx context.Context) ([]models.Post, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	posts := make([]models.Post, 0, len(s.posts))
+	for _, post := range s.posts {
+		posts = append(posts, post)
+	}
+	less := postLess(models.DefaultPostQuery())
+	sort.Slice(posts, func(i, j int) bool { return less(posts[i], posts[j]) })
+	return posts, nil
+}
+
+func (s *InMemoryPostStore) GetByID(ctx context.Context, id int) (*models.Post, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	post, exists := s.posts[id]
+	if !exists {
+		return nil, nil
+	}
+	return &post, nil
+}
+
+func (s *InMemoryPostStore) GetBySlug(ctx context.Context, slug string) (*models.Post, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	for _, post := range s.posts {
+		if post.Slug == slug {
+			return &post, nil
+		}
+	}
+	if id, ok := s.redirects[slug]; ok {
+		post := s.posts[id]
+		return &post, nil
+	}
+	return nil, nil
+}
+
+func (s *InMemoryPostStore) Create(ctx context.Context, post models.Post) (*models.Post, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if post.Slug == "" {
+		post.Slug = s.allocateSlug(models.Slugify(post.Title))
+	} else if s.slugInUse(post.Slug, 0) {
+		return nil, ErrSlugTaken
+	}
+
+	post.ID = s.id
+	post.CreatedAt = now()
+	post.UpdatedAt = post.CreatedAt
+	post.DeletedAt = nil
+
+	s.posts[s.id] = post
+	s.id++
+
+	return &post, nil
+}
+
+func (s *InMemoryPostStore) Update(ctx context.Context, id int, updated models.Post) (*models.Post, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	existing, exists := s.posts[id]
+	if !exists {
+		return nil, nil
+	}
+
+	if updated.Slug != "" && updated.Slug != existing.Slug {
+		if s.slugInUse(updated.Slug, id) {
+			return nil, ErrSlugTaken
+		}
+		delete(s.redirects, updated.Slug)
+		s.redirects[existing.Slug] = id
+		existing.Slug = updated.Slug
+	}
+	if updated.Title != "" {
+		existing.Title = updated.Title
+	}
+	if updated.Content != "" {
+		existing.Content = updated.Content
+	}
+	if updated.Author != "" {
+		existing.Author = updated.Author
+	}
+
+	existing.UpdatedAt = now()
+	s.posts[id] = existing
+
+	return &existing, nil
+}
+
+func (s *InMemoryPostStore) Delete(ctx context.Context, id int) error {
+	_, err := s.Remove(ctx, id)
+	return err
+}
+
+// Remove deletes a post and reports whether there was one.
+func (s *InMemoryPostStore) Remove(ctx context.Context, id int) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, exists := s.posts[id]; !exists {
+		return false, nil
+	}
+
+	delete(s.posts, id)
+	for slug, postID := range s.redirects {
+		if postID == id {
+			delete(s.redirects, slug)
+		}
+	}
+	return true, nil
+}
+
+func (s *InMemoryPostStore) Close() error {
+	return nil
+}
+
+// slugInUse reports whether slug belongs to any post other than exceptID,
+// either as its current slug or as a redirect.
+func (s *InMemoryPostStore) slugInUse(slug string, exceptID int) bool {
+	if id, ok := s.redirects[slug]; ok && id != exceptID {
+		return true
+	}
+	for _, post := range s.posts {
+		if post.Slug == slug && post.ID != exceptID {
+			return true
+		}
+	}
+	return false
+}
+
+// allocateSlug returns base, or base-2, base-3 and so on, whichever is free.
+func (s *InMemoryPostStore) allocateSlug(base string) string {
+	candidate := base
+	for n := 2; s.slugInUse(candidate, 0); n++ {
+		candidate = fmt.Sprintf("%s-%d", base, n)
+	}
+	return candidate
+}
@@ -0,0 +1,105 @@
+package storage
+
+import (
+	"blog-api/models"
+	"context"
+	"encoding/json"
+	"log"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// PostsChangedChannel is the NOTIFY channel written by the posts trigger.
+const PostsChangedChannel = "posts_changed"
+
+// PostEventsChannel is notified of every event written to the outbox.
+const PostEventsChannel = "post_events"
+
+// PostEventNotice is the payload of a post_events notification: the event
+// itself, or only OutboxID when the event was too large to include.
+type PostEventNotice struct {
+	models.PostEvent
+	OutboxID int64 `json:"outbox_id,omitempty"`
+}
+
+// PostChange is the payload of a posts_changed notification.
+type PostChange struct {
+	ID int    `json:"id"`
+	Op string `json:"op"` // insert, update, delete
+}
+
+// ChangeHandler receives post change notifications. OnReset is called after
+// the listen connection is re-established, since notifications sent while it
+// was down are lost.
+type ChangeHandler struct {
+	OnChange func(PostChange)
+	OnEvent  func(PostEventNotice)
+	OnReset  func()
+}
+
+// ListenForChanges subscribes to PostsChangedChannel and PostEventsChannel
+// on a dedicated connection and calls h for every notification until ctx
+// is cancelled.
+// Dropped connections are re-established automatically.
+func ListenForChanges(ctx context.Context, connectionString string, h ChangeHandler) error {
+	listener := pq.NewListener(connectionString, time.Second, time.Minute,
+		func(ev pq.ListenerEventType, err error) {
+			switch ev {
+			case pq.ListenerEventDisconnected:
+				log.Printf("Change listener disconnected: %v", err)
+			case pq.ListenerEventReconnected:
+				log.Println("Change listener reconnected")
+			case pq.ListenerEventConnectionAttemptFailed:
+				log.Printf("Change listener reconnect failed: %v", err)
+			}
+		})
+	defer listener.Close()
+
+	for _, channel := range []string{PostsChangedChannel, PostEventsChannel} {
+		if err := listener.Listen(channel); err != nil {
+			return err
+		}
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+
+		case n := <-listener.Notify:
+			// A nil notification means the connection was re-established
+			if n == nil {
+				if h.OnReset != nil {
+					h.OnReset()
+				}
+				continue
+			}
+
+			switch n.Channel {
+			case PostsChangedChannel:
+				var change PostChange
+				if err := json.Unmarshal([]byte(n.Extra), &change); err != nil {
+					log.Printf("Ignoring malformed %s payload %q: %v", n.Channel, n.Extra, err)
+					continue
+				}
+				if h.OnChange != nil {
+					h.OnChange(change)
+				}
+			case PostEventsChannel:
+				var notice PostEventNotice
+				if err := json.Unmarshal([]byte(n.Extra), &notice); err != nil {
+					log.Printf("Ignoring malformed %s payload: %v", n.Channel, err)
+					continue
+				}
+				if h.OnEvent != nil {
+					h.OnEvent(notice)
+				}
+			}
+
+		case <-time.After(90 * time.Second):
+			// Detect half-open connections while idle
+			go listener.Ping()
+		}
+	}
+}
@@ -0,0 +1,220 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"io/fs"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Migration is one numbered schema change, see database/migrations.
+type Migration struct {
+	Version int
+	Name    string
+	Up      string
+	Down    string // empty if the change cannot be reverted
+}
+
+var migrationFile = regexp.MustCompile(`^(\d+)_(.+?)(\.down)?\.sql$`)
+
+// LoadMigrations reads NNN_name.sql files and their NNN_name.down.sql
+// reverts from fsys, ordered by version.
+func LoadMigrations(fsys fs.FS) ([]Migration, error) {
+	names, err := fs.Glob(fsys, "*.sql")
+	if err != nil {
+		return nil, err
+	}
+
+	byVersion := map[int]*Migration{}
+	for _, name := range names {
+		m := migrationFile.FindStringSubmatch(name)
+		if m == nil {
+			return nil, fmt.Errorf("migration %s: name must look like 001_name.sql", name)
+		}
+		version, _ := strconv.Atoi(m[1])
+		data, err := fs.ReadFile(fsys, name)
+		if err != nil {
+			return nil, err
+		}
+
+		mig := byVersion[version]
+		if mig == nil {
+			mig = &Migration{Version: version, Name: m[2]}
+			byVersion[version] = mig
+		}
+		if mig.Name != m[2] {
+			return nil, fmt.Errorf("migration %s: version %d is also used by %s", name, version, mig.Name)
+		}
+		if m[3] != "" {
+			mig.Down = string(data)
+		} else {
+			mig.Up = string(data)
+		}
+	}
+
+	migrations := make([]Migration, 0, len(byVersion))
+	for _, mig := range byVersion {
+		if strings.TrimSpace(mig.Up) == "" {
+			return nil, fmt.Errorf("migration %d_%s has no up file", mig.Version, mig.Name)
+		}
+		migrations = append(migrations, *mig)
+	}
+	sort.Slice(migrations, func(i, j int) bool { return migrations[i].Version < migrations[j].Version })
+	return migrations, nil
+}
+
+// AppliedMigration is a migration the database has.
+type AppliedMigration struct {
+	Version   int       `json:"version"`
+	AppliedAt time.Time `json:"applied_at"`
+}
+
+// migrationLock serializes migrations between servers starting at once and
+// blogctl (pg_advisory_xact_lock key).
+const migrationLock = 7368240001
+
+// AppliedMigrations lists the applied migrations in version order. It only
+// reads: a database without schema_migrations has none.
+func (s *PostgresStore) AppliedMigrations(ctx context.Context) ([]AppliedMigration, error) {
+	var exists bool
+	if err := s.db.QueryRowContext(ctx, `SELECT to_regclass('schema_migrations') IS NOT NULL`).Scan(&exists); err != nil {
+		return nil, err
+	}
+	if !exists {
+		return nil, nil
+	}
+
+	rows, err := s.db.QueryContext(ctx, `SELECT version, applied_at FROM schema_migrations ORDER BY version`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var applied []AppliedMigration
+	for rows.Next() {
+		var m AppliedMigration
+		if err := rows.Scan(&m.Version, &m.AppliedAt); err != nil {
+			return nil, err
+		}
+		applied = append(applied, m)
+	}
+	return applied, rows.Err()
+}
+
+// Migrate applies the migrations in all that the database does not have
+// yet, oldest first, stopping after version to (0 for no limit). It returns
+// the ones it applied.
+func (s *PostgresStore) Migrate(ctx context.Context, all []Migration, to int) ([]Migration, error) {
+	if err := s.createMigrationsTable(ctx); err != nil {
+		return nil, err
+	}
+
+	var applied []Migration
+	for _, m := range all {
+		if to > 0 && m.Version > to {
+			break
+		}
+		ok, err := s.applyMigration(ctx, m)
+		if err != nil {
+			return applied, err
+		}
+		if ok {
+			applied = append(applied, m)
+		}
+	}
+	return applied, nil
+}
+
+// createMigrationsTable creates schema_migrations, which records every
+// applied version, if it does not exist yet.
+func (s *PostgresStore) createMigrationsTable(ctx context.Context) error {
+	return s.migrationTx(ctx, func(tx *sql.Tx) error {
+		_, err := tx.ExecContext(ctx, `
+        CREATE TABLE IF NOT EXISTS schema_migrations (
+            version INT PRIMARY KEY,
+            applied_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP
+        )
+    `)
+		return err
+	})
+}
+
+// applyMigration runs m.Up and records its version in one transaction,
+// unless the version is already recorded. It reports whether it ran.
+func (s *PostgresStore) applyMigration(ctx context.Context, m Migration) (bool, error) {
+	ran := false
+	err := s.migrationTx(ctx, func(tx *sql.Tx) error {
+		var done bool
+		err := tx.QueryRowContext(ctx,
+			`SELECT EXISTS (SELECT 1 FROM schema_migrations WHERE version = $1)`, m.Version).Scan(&done)
+		if err != nil || done {
+			return err
+		}
+
+		if _, err := tx.ExecContext(ctx, m.Up); err != nil {
+			return fmt.Errorf("migration %03d_%s: %w", m.Version, m.Name, err)
+		}
+		// 004 leaves generating slugs for existing posts to the application
+		if m.Version == 4 {
+			if err := backfillSlugs(ctx, tx); err != nil {
+				return err
+			}
+		}
+		if _, err := tx.ExecContext(ctx, `INSERT INTO schema_migrations (version) VALUES ($1)`, m.Version); err != nil {
+			return err
+		}
+		ran = true
+		return nil
+	})
+	return ran && err == nil, err
+}
+
+// RevertMigration runs m.Down and removes its version from
+// schema_migrations, in one transaction.
+func (s *PostgresStore) RevertMigration(ctx context.Context, m Migration) error {
+	if strings.TrimSpace(m.Down) == "" {
+		return fmt.Errorf("migration %03d_%s cannot be reverted", m.Version, m.Name)
+	}
+	return s.migrationTx(ctx, func(tx *sql.Tx) error {
+		if _, err := tx.ExecContext(ctx, m.Down); err != nil {
+			return fmt.Errorf("revert migration %03d_%s: %w", m.Version, m.Name, err)
+		}
+		_, err := tx.ExecContext(ctx, `DELETE FROM schema_migrations WHERE version = $1`, m.Version)
+		return err
+	})
+}
+
+// migrationTx runs fn in a transaction holding the migration lock.
+func (s *PostgresStore) migrationTx(ctx context.Context, fn func(tx *sql.Tx) error) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, `SELECT pg_advisory_xact_lock($1)`, migrationLock); err != nil {
+		return err
+	}
+	if err := fn(tx); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// SchemaVersionOf is the newest version such that it and every version
+// before it are applied.
+func SchemaVersionOf(applied []AppliedMigration) int {
+	version := 0
+	for _, m := range applied {
+		if m.Version != version+1 {
+			break
+		}
+		version = m.Version
+	}
+	return version
+}
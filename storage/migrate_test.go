@@ -0,0 +1,49 @@
+package storage
+
+import (
+	"blog-api/database/migrations"
+	"strings"
+	"testing"
+)
+
+func TestEmbeddedMigrations(t *testing.T) {
+	all, err := LoadMigrations(migrations.FS)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, m := range all {
+		if m.Version != i+1 {
+			t.Errorf("migration %03d_%s is out of sequence, want version %d", m.Version, m.Name, i+1)
+		}
+		if strings.TrimSpace(m.Down) == "" {
+			t.Errorf("migration %03d_%s has no down file", m.Version, m.Name)
+		}
+	}
+	if n := len(all); n == 0 || all[n-1].Version != SchemaVersion {
+		t.Errorf("newest migration is not SchemaVersion %d", SchemaVersion)
+	}
+}
+
+func TestSchemaVersionOf(t *testing.T) {
+	tests := []struct {
+		name    string
+		applied []int
+		version int
+	}{
+		{"empty", nil, 0},
+		{"applied one by one", []int{1, 2, 3}, 3},
+		{"gap", []int{1, 3, 4}, 1},
+		{"first missing", []int{2, 3}, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var applied []AppliedMigration
+			for _, v := range tt.applied {
+				applied = append(applied, AppliedMigration{Version: v})
+			}
+			if v := SchemaVersionOf(applied); v != tt.version {
+				t.Errorf("schema version is %d, want %d", v, tt.version)
+			}
+		})
+	}
+}
@@ -0,0 +1,41 @@
+package storage
+
+import (
+	"blog-api/models"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"time"
+)
+
+// OutboxStore is implemented by stores that record a post event in an
+// outbox table in the same transaction as the change itself, so an event
+// exists if and only if its change was committed.
+type OutboxStore interface {
+	// ProcessOutbox locks up to limit unpublished events, oldest first,
+	// and passes each to handle. Events handled without error are marked
+	// published; the others are retried later with backoff. Rows locked by
+	// another instance are skipped. It returns how many events it saw.
+	ProcessOutbox(ctx context.Context, limit int, handle func(models.PostEvent) error) (int, error)
+
+	// PruneOutbox deletes events published before the given time.
+	PruneOutbox(ctx context.Context, before time.Time) (int, error)
+}
+
+// NewEventID returns a random identifier consumers can use to drop
+// duplicate deliveries of the same event.
+func NewEventID() string {
+	b := make([]byte, 16)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+func newPostEvent(eventType string, postID int, post *models.Post) models.PostEvent {
+	return models.PostEvent{
+		ID:         NewEventID(),
+		Type:       eventType,
+		OccurredAt: time.Now().UTC(),
+		PostID:     postID,
+		Post:       post,
+	}
+}
@@ -0,0 +1,91 @@
+package storage
+
+import (
+	"blog-api/models"
+	"context"
+	"fmt"
+
+	"github.com/lib/pq"
+)
+
+// CountByAuthor counts the posts of every author in one grouped query.
+func (s *PostgresStore) CountByAuthor(ctx context.Context, authors []string) (map[string]int, error) {
+	query := `
+    SELECT author, COUNT(*)
+    FROM posts
+    WHERE author = ANY($1) AND deleted_at IS NULL
+    GROUP BY author
+    `
+
+	var counts map[string]int
+	err := s.retryRead(ctx, func() error {
+		rows, err := s.replicas.reader(ctx).QueryContext(ctx, query, pq.StringArray(authors))
+		if err != nil {
+			return err
+		}
+		defer rows.Close()
+
+		counts = make(map[string]int, len(authors))
+		for rows.Next() {
+			var author string
+			var n int
+			if err := rows.Scan(&author, &n); err != nil {
+				return err
+			}
+			counts[author] = n
+		}
+		return rows.Err()
+	})
+	if err != nil {
+		return nil, err
+	}
+	return counts, nil
+}
+
+// GetAuthorPages fetches one page per author in a single query, numbering
+// each author's posts in listing order and keeping the first Limit+1.
+func (s *PostgresStore) GetAuthorPages(ctx context.Context, authors []string, query models.PostQuery) (map[string]*models.PaginatedPosts, error) {
+	query.Author = ""
+	whereClause, args := s.buildWhereClause(query)
+	orderClause := s.buildOrderClause(query)
+
+	args = append(args, pq.StringArray(authors), query.Limit+1)
+	sql := fmt.Sprintf(`
+        SELECT %s
+        FROM (
+            SELECT *, ROW_NUMBER() OVER (PARTITION BY author %s) AS author_rank
+            FROM posts
+            %s AND author = ANY($%d)
+        ) AS ranked
+        WHERE author_rank <= $%d
+        ORDER BY author, author_rank
+    `, postColumns, orderClause, whereClause, len(args)-1, len(args))
+
+	byAuthor := map[string][]models.Post{}
+	err := s.retryRead(ctx, func() error {
+		rows, err := s.replicas.reader(ctx).QueryContext(ctx, sql, args...)
+		if err != nil {
+			return fmt.Errorf("failed to query posts: %w", err)
+		}
+		defer rows.Close()
+
+		clear(byAuthor)
+		for rows.Next() {
+			post, err := scanPost(rows)
+			if err != nil {
+				return err
+			}
+			byAuthor[post.Author] = append(byAuthor[post.Author], post)
+		}
+		return rows.Err()
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	pages := make(map[string]*models.PaginatedPosts, len(byAuthor))
+	for author, posts := range byAuthor {
+		pages[author] = newPage(posts, query)
+	}
+	return pages, nil
+}
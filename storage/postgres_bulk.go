@@ -0,0 +1,80 @@
+package storage
+
+import (
+	"blog-api/models"
+	"context"
+	"encoding/json"
+
+	"github.com/lib/pq"
+)
+
+// LoadPosts copies posts into the table as they are, in one transaction,
+// and returns how many were written. It is meant for loading synthetic data:
+// slugs must already be unique, timestamps are kept and no events are
+// written to the outbox, so stream subscribers and webhooks never hear of
+// the posts.
+//
+// The row triggers on posts are switched off for the copy, which needs a
+// role allowed to set session_replication_role (a superuser, or one granted
+// it). The change feed then gets one entry per post and the cache listeners
+// one posts_changed notification for the whole batch.
+func (s *PostgresStore) LoadPosts(ctx context.Context, posts []models.Post) (int, error) {
+	if len(posts) == 0 {
+		return 0, nil
+	}
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, "SET LOCAL session_replication_role = replica"); err != nil {
+		return 0, err
+	}
+
+	stmt, err := tx.PrepareContext(ctx, pq.CopyIn("posts", "title", "content", "author", "slug", "created_at", "updated_at"))
+	if err != nil {
+		return 0, err
+	}
+	slugs := make([]string, len(posts))
+	for i, post := range posts {
+		if _, err := stmt.ExecContext(ctx, post.Title, post.Content, post.Author, post.Slug, post.CreatedAt, post.UpdatedAt); err != nil {
+			stmt.Close()
+			return 0, err
+		}
+		slugs[i] = post.Slug
+	}
+	if _, err := stmt.ExecContext(ctx); err != nil {
+		stmt.Close()
+		return 0, err
+	}
+	if err := stmt.Close(); err != nil {
+		return 0, err
+	}
+
+	// What the skipped triggers would have done, once per batch
+	var lastID int
+	err = tx.QueryRowContext(ctx, `
+		WITH loaded AS (
+			INSERT INTO post_changes (post_id, op)
+			SELECT id, 'insert' FROM posts WHERE slug = ANY($1) ORDER BY id
+			RETURNING post_id
+		)
+		SELECT MAX(post_id) FROM loaded`, pq.Array(slugs)).Scan(&lastID)
+	if err != nil {
+		return 0, err
+	}
+	payload, err := json.Marshal(PostChange{ID: lastID, Op: "insert"})
+	if err != nil {
+		return 0, err
+	}
+	if _, err := tx.ExecContext(ctx, "SELECT pg_notify($1, $2)", PostsChangedChannel, string(payload)); err != nil {
+		return 0, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, err
+	}
+	return len(posts), nil
+}
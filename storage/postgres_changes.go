@@ -0,0 +1,138 @@
+package storage
+
+import (
+	"blog-api/models"
+	"context"
+	"database/sql"
+	"strconv"
+	"time"
+)
+
+var changeOps = map[string]string{
+	"insert": models.ChangeCreated,
+	"update": models.ChangeUpdated,
+	"delete": models.ChangeDeleted,
+}
+
+// PostChanges returns up to limit changes after since. Only transactions
+// older than every transaction still running are included, so a change can
+// never appear behind a token that was already handed out. It reads from
+// the primary.
+//
+// "Running" means holding a transaction ID anywhere in the cluster, so one
+// long write transaction, such as a large atomic import or a forgotten psql
+// session, holds the feed back until it ends. Read-only transactions,
+// exports included, have no ID and do not. FeedHorizon reports the
+// transaction at the horizon.
+func (s *PostgresStore) PostChanges(ctx context.Context, since models.ChangeToken, limit int) (*models.ChangeSet, error) {
+	query := `
+    SELECT c.tx_id::text, c.seq, c.post_id, c.op, c.changed_at,
+        p.id, p.title, p.content, p.author, COALESCE(p.slug, ''), p.created_at, p.updated_at
+    FROM post_changes c
+    LEFT JOIN posts p ON p.id = c.post_id AND c.op <> 'delete'
+    WHERE (c.tx_id, c.seq) > ($1::text::xid8, $2)
+        AND c.tx_id < pg_snapshot_xmin(pg_current_snapshot())
+    ORDER BY c.tx_id, c.seq
+    LIMIT $3
+    `
+
+	// One extra row tells us whether there is more
+	rows, err := s.db.QueryContext(ctx, query, strconv.FormatUint(since.TxID, 10), since.Seq, limit+1)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	set := &models.ChangeSet{Changes: []models.PostChange{}}
+	next := since
+	n := 0
+	for rows.Next() {
+		n++
+		if n > limit {
+			set.HasMore = true
+			break
+		}
+
+		var (
+			txID                   string
+			change                 models.PostChange
+			op                     string
+			postID                 sql.NullInt64
+			post                   models.Post
+			slug                   string
+			title, content, author sql.NullString
+			created, updated       sql.NullTime
+		)
+		err := rows.Scan(&txID, &next.Seq, &change.PostID, &op, &change.ChangedAt,
+			&postID, &title, &content, &author, &slug, &created, &updated)
+		if err != nil {
+			return nil, err
+		}
+		if next.TxID, err = strconv.ParseUint(txID, 10, 64); err != nil {
+			return nil, err
+		}
+
+		change.Op = changeOps[op]
+		if postID.Valid {
+			post = models.Post{
+				ID:        int(postID.Int64),
+				Title:     title.String,
+				Content:   content.String,
+				Author:    author.String,
+				Slug:      slug,
+				CreatedAt: created.Time,
+				UpdatedAt: updated.Time,
+			}
+			change.Post = &post
+		}
+		set.Changes = append(set.Changes, change)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	next.IssuedAt = time.Now()
+	set.NextToken = next.Encode()
+	return set, nil
+}
+
+// PruneTombstones deletes tombstones for posts deleted before the given
+// time.
+func (s *PostgresStore) PruneTombstones(ctx context.Context, before time.Time) (int, error) {
+	result, err := s.db.ExecContext(ctx, `DELETE FROM post_changes WHERE op = 'delete' AND changed_at < $1`, before)
+	if err != nil {
+		return 0, err
+	}
+	n, err := result.RowsAffected()
+	return int(n), err
+}
+
+// FeedHorizon is the oldest transaction still writing, which PostChanges
+// cannot see past.
+type FeedHorizon struct {
+	PID         int       `json:"pid"`
+	Application string    `json:"application"`
+	Since       time.Time `json:"since"`
+}
+
+// ChangeFeedHorizon returns the oldest transaction holding a transaction
+// ID, or nil if none is running. Sessions of other roles are only seen with
+// pg_read_all_stats.
+func (s *PostgresStore) ChangeFeedHorizon(ctx context.Context) (*FeedHorizon, error) {
+	query := `
+    SELECT pid, application_name, xact_start
+    FROM pg_stat_activity
+    WHERE backend_xid IS NOT NULL AND xact_start IS NOT NULL
+    ORDER BY xact_start
+    LIMIT 1
+    `
+	var h FeedHorizon
+	err := s.db.QueryRowContext(ctx, query).Scan(&h.PID, &h.Application, &h.Since)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &h, nil
+}
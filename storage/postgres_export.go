@@ -0,0 +1,117 @@
+package storage
+
+import (
+	"blog-api/models"
+	"context"
+	"database/sql"
+	"fmt"
+)
+
+// exportFetchSize is how many rows each FETCH pulls from the server-side
+// cursor; memory use is bounded by this rather than the table size.
+const exportFetchSize = 500
+
+// ExportPosts walks every matching post through a server-side cursor inside
+// a read-only transaction, so the export sees one consistent snapshot.
+func (s *PostgresStore) ExportPosts(ctx context.Context, query models.PostQuery, fn func(models.Post) error) error {
+	query.Cursor = ""
+	whereClause, args := s.buildWhereClause(query)
+	orderClause := s.buildOrderClause(query)
+
+	tx, err := s.replicas.reader(ctx).BeginTx(ctx, &sql.TxOptions{ReadOnly: true, Isolation: sql.LevelRepeatableRead})
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	declare := fmt.Sprintf(`
+        DECLARE export_posts NO SCROLL CURSOR FOR
+        SELECT %s 
+        FROM posts 
+        %s 
+        %s
+    `, postColumns, whereClause, orderClause)
+	if _, err := tx.ExecContext(ctx, declare, args...); err != nil {
+		return fmt.Errorf("failed to open export cursor: %w", err)
+	}
+
+	fetch := fmt.Sprintf("FETCH %d FROM export_posts", exportFetchSize)
+	for {
+		rows, err := tx.QueryContext(ctx, fetch)
+		if err != nil {
+			return fmt.Errorf("failed to fetch posts: %w", err)
+		}
+
+		n := 0
+		for rows.Next() {
+			post, err := scanPost(rows)
+			if err == nil {
+				err = fn(post)
+			}
+			if err != nil {
+				rows.Close()
+				return err
+			}
+			n++
+		}
+		if err := rows.Err(); err != nil {
+			rows.Close()
+			return err
+		}
+		rows.Close()
+
+		if n < exportFetchSize {
+			return nil
+		}
+	}
+}
+
+// CountPosts counts the posts matching query's filters.
+func (s *PostgresStore) CountPosts(ctx context.Context, query models.PostQuery) (int, error) {
+	query.Cursor = ""
+	whereClause, args := s.buildWhereClause(query)
+
+	var count int
+	err := s.retryRead(ctx, func() error {
+		return s.replicas.reader(ctx).QueryRowContext(ctx, "SELECT COUNT(*) FROM posts "+whereClause, args...).Scan(&count)
+	})
+	return count, err
+}
+
+// MaxPostID returns the largest id of a post that is not in the trash.
+func (s *PostgresStore) MaxPostID(ctx context.Context) (int, error) {
+	var id int
+	err := s.retryRead(ctx, func() error {
+		return s.replicas.reader(ctx).QueryRowContext(ctx,
+			`SELECT COALESCE(MAX(id), 0) FROM posts WHERE deleted_at IS NULL`).Scan(&id)
+	})
+	return id, err
+}
+
+// ExportRange streams the posts in an id range straight off the primary key
+// index, so a range costs the same wherever it starts.
+func (s *PostgresStore) ExportRange(ctx context.Context, after, through int, fn func(models.Post) error) error {
+	query := `
+    SELECT ` + postColumns + `
+    FROM posts
+    WHERE id > $1 AND id <= $2 AND deleted_at IS NULL
+    ORDER BY id
+    `
+
+	rows, err := s.replicas.reader(ctx).QueryContext(ctx, query, after, through)
+	if err != nil {
+		return fmt.Errorf("failed to query posts: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		post, err := scanPost(rows)
+		if err != nil {
+			return err
+		}
+		if err := fn(post); err != nil {
+			return err
+		}
+	}
+	return rows.Err()
+}
@@ -0,0 +1,142 @@
+package storage
+
+import (
+	"context"
+	"crypto/rand"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+// A reservation is held for this long at a time. The request renews it
+// while it runs; one that crashed is taken over once it lapses, so its key
+// is not blocked until it expires.
+const idempotencyLease = time.Minute
+
+func newLeaseToken() string {
+	b := make([]byte, 16)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+func (s *PostgresStore) ReserveIdempotencyKey(ctx context.Context, scope, key, fingerprint string, ttl time.Duration) (*IdempotencyLease, *IdempotencyRecord, error) {
+	reserve := `
+    INSERT INTO idempotency_keys (scope, key, fingerprint, lease_token, leased_until, expires_at)
+    VALUES ($1, $2, $3, $4,
+        CURRENT_TIMESTAMP + $6::double precision * INTERVAL '1 millisecond',
+        CURRENT_TIMESTAMP + $5::double precision * INTERVAL '1 millisecond')
+    ON CONFLICT (scope, key) DO UPDATE
+    SET fingerprint = EXCLUDED.fingerprint,
+        status_code = NULL,
+        headers = '{}',
+        body = NULL,
+        lease_token = EXCLUDED.lease_token,
+        leased_until = EXCLUDED.leased_until,
+        created_at = CURRENT_TIMESTAMP,
+        expires_at = EXCLUDED.expires_at
+    WHERE idempotency_keys.expires_at <= CURRENT_TIMESTAMP
+       OR (idempotency_keys.status_code IS NULL
+           AND COALESCE(idempotency_keys.leased_until, idempotency_keys.created_at) <= CURRENT_TIMESTAMP)
+    RETURNING key
+    `
+	lookup := `
+    SELECT fingerprint, status_code, headers, body
+    FROM idempotency_keys
+    WHERE scope = $1 AND key = $2
+    `
+
+	lease := &IdempotencyLease{Scope: scope, Key: key, Token: newLeaseToken(), Duration: idempotencyLease}
+
+	// The existing row may be purged between the two statements; the
+	// next attempt then reserves the key
+	for attempt := 0; attempt < 3; attempt++ {
+		var reserved string
+		err := s.db.QueryRowContext(ctx, reserve, scope, key, fingerprint, lease.Token,
+			ttl.Milliseconds(), idempotencyLease.Milliseconds()).Scan(&reserved)
+		if err == nil {
+			return lease, nil, nil
+		}
+		if err != sql.ErrNoRows {
+			return nil, nil, err
+		}
+
+		var rec IdempotencyRecord
+		var status sql.NullInt64
+		var headers []byte
+		var body []byte
+		err = s.db.QueryRowContext(ctx, lookup, scope, key).Scan(&rec.Fingerprint, &status, &headers, &body)
+		if err == sql.ErrNoRows {
+			continue
+		}
+		if err != nil {
+			return nil, nil, err
+		}
+		if status.Valid {
+			rec.Response = &IdempotentResponse{StatusCode: int(status.Int64), Body: body}
+			if err := json.Unmarshal(headers, &rec.Response.Header); err != nil {
+				return nil, nil, fmt.Errorf("idempotency key %q: stored headers: %w", key, err)
+			}
+		}
+		return nil, &rec, nil
+	}
+	return nil, nil, fmt.Errorf("idempotency key %q changed concurrently", key)
+}
+
+func (s *PostgresStore) RenewIdempotencyKey(ctx context.Context, lease IdempotencyLease) error {
+	query := `
+    UPDATE idempotency_keys
+    SET leased_until = CURRENT_TIMESTAMP + $4::double precision * INTERVAL '1 millisecond'
+    WHERE scope = $1 AND key = $2 AND lease_token = $3 AND status_code IS NULL
+    `
+	return s.execLease(ctx, query, lease.Scope, lease.Key, lease.Token, lease.Duration.Milliseconds())
+}
+
+func (s *PostgresStore) CompleteIdempotencyKey(ctx context.Context, lease IdempotencyLease, resp IdempotentResponse) error {
+	headers, err := json.Marshal(resp.Header)
+	if err != nil {
+		return err
+	}
+	query := `
+    UPDATE idempotency_keys
+    SET status_code = $4, headers = $5, body = $6, lease_token = NULL, leased_until = NULL
+    WHERE scope = $1 AND key = $2 AND lease_token = $3 AND status_code IS NULL
+    `
+	return s.execLease(ctx, query, lease.Scope, lease.Key, lease.Token, resp.StatusCode, string(headers), resp.Body)
+}
+
+func (s *PostgresStore) ReleaseIdempotencyKey(ctx context.Context, lease IdempotencyLease) error {
+	query := `
+    DELETE FROM idempotency_keys
+    WHERE scope = $1 AND key = $2 AND lease_token = $3 AND status_code IS NULL
+    `
+	return s.execLease(ctx, query, lease.Scope, lease.Key, lease.Token)
+}
+
+// execLease runs a statement on the row held by a lease, returning
+// ErrLeaseLost if it matched nothing.
+func (s *PostgresStore) execLease(ctx context.Context, query string, args ...interface{}) error {
+	result, err := s.db.ExecContext(ctx, query, args...)
+	if err != nil {
+		return err
+	}
+	n, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return ErrLeaseLost
+	}
+	return nil
+}
+
+// PurgeIdempotencyKeys deletes expired keys and returns how many there were.
+func (s *PostgresStore) PurgeIdempotencyKeys(ctx context.Context) (int, error) {
+	result, err := s.db.ExecContext(ctx, `DELETE FROM idempotency_keys WHERE expires_at <= CURRENT_TIMESTAMP`)
+	if err != nil {
+		return 0, err
+	}
+	n, err := result.RowsAffected()
+	return int(n), err
+}
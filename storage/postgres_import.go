@@ -0,0 +1,115 @@
+package storage
+
+import (
+	"blog-api/models"
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+)
+
+// postgresImportTx inserts posts with multi-row INSERT statements inside a
+// single transaction.
+type postgresImportTx struct {
+	ctx   context.Context
+	store *PostgresStore
+	tx    *sql.Tx
+}
+
+func (s *PostgresStore) BeginImport(ctx context.Context) (ImportTx, error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	return &postgresImportTx{ctx: ctx, store: s, tx: tx}, nil
+}
+
+// Insert runs inside a savepoint, so a failed batch is undone without
+// aborting the transaction.
+func (t *postgresImportTx) Insert(posts []models.Post) ([]models.Post, error) {
+	if len(posts) == 0 {
+		return nil, nil
+	}
+
+	if _, err := t.tx.ExecContext(t.ctx, `SAVEPOINT import_batch`); err != nil {
+		return nil, err
+	}
+	inserted, err := t.insert(posts)
+	if err != nil {
+		if _, rbErr := t.tx.ExecContext(t.ctx, `ROLLBACK TO SAVEPOINT import_batch`); rbErr != nil {
+			return nil, fmt.Errorf("%w (and rolling back the batch failed: %v)", err, rbErr)
+		}
+		return nil, err
+	}
+	if _, err := t.tx.ExecContext(t.ctx, `RELEASE SAVEPOINT import_batch`); err != nil {
+		return nil, err
+	}
+	return inserted, nil
+}
+
+func (t *postgresImportTx) insert(posts []models.Post) ([]models.Post, error) {
+
+	// Rows in one batch may share a title, so slugs handed out earlier in
+	// the batch are reserved alongside those already in the table.
+	reserved := make(map[string]bool, len(posts))
+	values := make([]string, 0, len(posts))
+	args := make([]interface{}, 0, len(posts)*4)
+	for i, post := range posts {
+		slug, err := t.store.chooseSlug(t.ctx, t.tx, post, reserved)
+		if err != nil {
+			return nil, err
+		}
+		reserved[slug] = true
+
+		values = append(values, fmt.Sprintf("($%d, $%d, $%d, $%d)", i*4+1, i*4+2, i*4+3, i*4+4))
+		args = append(args, post.Title, post.Content, post.Author, slug)
+	}
+
+	query := `
+    INSERT INTO posts (title, content, author, slug) 
+    VALUES ` + strings.Join(values, ", ") + `
+    RETURNING ` + postColumns + `
+    `
+
+	rows, err := t.tx.QueryContext(t.ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	// Postgres returns rows from a multi-row INSERT in VALUES order
+	inserted := make([]models.Post, 0, len(posts))
+	for rows.Next() {
+		post, err := scanPost(rows)
+		if err != nil {
+			return nil, err
+		}
+		inserted = append(inserted, post)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	rows.Close()
+
+	events := make([]models.PostEvent, len(inserted))
+	for i := range inserted {
+		events[i] = newPostEvent(models.EventPostCreated, inserted[i].ID, &inserted[i])
+	}
+	if err := writeOutbox(t.ctx, t.tx, events...); err != nil {
+		return nil, err
+	}
+
+	return inserted, nil
+}
+
+func (t *postgresImportTx) Commit() error {
+	if err := t.tx.Commit(); err != nil {
+		return err
+	}
+	t.store.replicas.pin(t.ctx)
+	return nil
+}
+
+func (t *postgresImportTx) Rollback() error {
+	return t.tx.Rollback()
+}
@@ -0,0 +1,128 @@
+package storage
+
+import (
+	"blog-api/models"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// outboxBackoff spaces retries of events a sink failed to accept.
+var outboxBackoff = Backoff{Initial: time.Second, Max: 5 * time.Minute}
+
+// writeOutbox records events inside tx, the transaction making the change.
+func writeOutbox(ctx context.Context, tx *sql.Tx, events ...models.PostEvent) error {
+	if len(events) == 0 {
+		return nil
+	}
+
+	values := make([]string, 0, len(events))
+	args := make([]interface{}, 0, len(events)*4)
+	for i, event := range events {
+		payload, err := json.Marshal(event)
+		if err != nil {
+			return err
+		}
+		values = append(values, fmt.Sprintf("($%d, $%d, $%d, $%d::jsonb)", i*4+1, i*4+2, i*4+3, i*4+4))
+		args = append(args, event.ID, event.Type, event.PostID, string(payload))
+	}
+
+	query := `INSERT INTO outbox (event_id, event_type, post_id, payload) VALUES ` + strings.Join(values, ", ")
+	_, err := tx.ExecContext(ctx, query, args...)
+	return err
+}
+
+func (s *PostgresStore) ProcessOutbox(ctx context.Context, limit int, handle func(models.PostEvent) error) (int, error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	// The row locks are held until commit: if this process dies while
+	// handling, the events become claimable again (at-least-once).
+	query := `
+    SELECT id, payload, attempts
+    FROM outbox
+    WHERE published_at IS NULL AND next_attempt_at <= CURRENT_TIMESTAMP
+    ORDER BY id
+    LIMIT $1
+    FOR UPDATE SKIP LOCKED
+    `
+	rows, err := tx.QueryContext(ctx, query, limit)
+	if err != nil {
+		return 0, err
+	}
+
+	type pending struct {
+		id       int64
+		payload  []byte
+		attempts int
+	}
+	var batch []pending
+	for rows.Next() {
+		var p pending
+		if err := rows.Scan(&p.id, &p.payload, &p.attempts); err != nil {
+			rows.Close()
+			return 0, err
+		}
+		batch = append(batch, p)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, err
+	}
+
+	for _, p := range batch {
+		var event models.PostEvent
+		err := json.Unmarshal(p.payload, &event)
+		if err == nil {
+			err = handle(event)
+		}
+
+		if err == nil {
+			_, err = tx.ExecContext(ctx, `UPDATE outbox SET published_at = CURRENT_TIMESTAMP, last_error = NULL WHERE id = $1`, p.id)
+		} else {
+			retryAt := time.Now().Add(outboxBackoff.Delay(p.attempts))
+			_, err = tx.ExecContext(ctx,
+				`UPDATE outbox SET attempts = attempts + 1, last_error = $1, next_attempt_at = $2 WHERE id = $3`,
+				err.Error(), retryAt, p.id)
+		}
+		if err != nil {
+			return 0, err
+		}
+	}
+
+	return len(batch), tx.Commit()
+}
+
+// OutboxEvent reads one event from the outbox on the primary, or returns
+// nil if it has been pruned.
+func (s *PostgresStore) OutboxEvent(ctx context.Context, id int64) (*models.PostEvent, error) {
+	var payload []byte
+	err := s.db.QueryRowContext(ctx, `SELECT payload FROM outbox WHERE id = $1`, id).Scan(&payload)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var event models.PostEvent
+	if err := json.Unmarshal(payload, &event); err != nil {
+		return nil, err
+	}
+	return &event, nil
+}
+
+func (s *PostgresStore) PruneOutbox(ctx context.Context, before time.Time) (int, error) {
+	result, err := s.db.ExecContext(ctx, `DELETE FROM outbox WHERE published_at < $1`, before)
+	if err != nil {
+		return 0, err
+	}
+	n, err := result.RowsAffected()
+	return int(n), err
+}
@@ -0,0 +1,21 @@
+package storage
+
+import "context"
+
+// ReindexSearch rebuilds the trigram search index from migration 002,
+// creating it if it is missing, and refreshes the planner statistics for
+// posts. The rebuild does not block writes.
+func (s *PostgresStore) ReindexSearch(ctx context.Context) error {
+	statements := []string{
+		`CREATE EXTENSION IF NOT EXISTS pg_trgm`,
+		`CREATE INDEX IF NOT EXISTS idx_posts_search_gin ON posts USING gin((title || ' ' || content) gin_trgm_ops)`,
+		`REINDEX INDEX CONCURRENTLY idx_posts_search_gin`,
+		`ANALYZE posts`,
+	}
+	for _, stmt := range statements {
+		if _, err := s.db.ExecContext(ctx, stmt); err != nil {
+			return err
+		}
+	}
+	return nil
+}
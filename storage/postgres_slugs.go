@@ -0,0 +1,187 @@
+package storage
+
+import (
+	"blog-api/models"
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+
+	"github.com/lib/pq"
+)
+
+// ErrSlugTaken is returned when an author asks for a slug that belongs to
+// another post, currently or as a redirect.
+var ErrSlugTaken = errors.New("slug is already in use")
+
+type queryer interface {
+	QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error)
+	QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row
+}
+
+func isSlugConflict(err error) bool {
+	var pqErr *pq.Error
+	return errors.As(err, &pqErr) && pqErr.Code == "23505" && pqErr.Constraint == "idx_posts_slug"
+}
+
+// backfillSlugs generates slugs for posts created before slugs existed.
+func backfillSlugs(ctx context.Context, tx *sql.Tx) error {
+	rows, err := tx.QueryContext(ctx, `SELECT id, title FROM posts WHERE slug IS NULL ORDER BY id`)
+	if err != nil {
+		return err
+	}
+	type pending struct {
+		id    int
+		title string
+	}
+	var posts []pending
+	for rows.Next() {
+		var p pending
+		if err := rows.Scan(&p.id, &p.title); err != nil {
+			rows.Close()
+			return err
+		}
+		posts = append(posts, p)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	for _, p := range posts {
+		slug, err := allocateSlug(ctx, tx, models.Slugify(p.title), nil)
+		if err != nil {
+			return err
+		}
+		if _, err := tx.ExecContext(ctx, `UPDATE posts SET slug = $1 WHERE id = $2`, slug, p.id); err != nil {
+			return err
+		}
+	}
+	if len(posts) > 0 {
+		log.Printf("Generated slugs for %d existing posts", len(posts))
+	}
+	return nil
+}
+
+// chooseSlug returns the slug a new post should get: the author's choice if
+// it is free, otherwise a unique slug generated from the title. reserved
+// holds slugs already handed out in the current batch.
+func (s *PostgresStore) chooseSlug(ctx context.Context, q queryer, post models.Post, reserved map[string]bool) (string, error) {
+	if post.Slug == "" {
+		return allocateSlug(ctx, q, models.Slugify(post.Title), reserved)
+	}
+
+	taken, err := slugInUse(ctx, q, post.Slug, 0)
+	if err != nil {
+		return "", err
+	}
+	if taken || reserved[post.Slug] {
+		return "", ErrSlugTaken
+	}
+	return post.Slug, nil
+}
+
+// allocateSlug returns base, or base-2, base-3 and so on, whichever is the
+// first not used by a post, a redirect or the reserved set.
+func allocateSlug(ctx context.Context, q queryer, base string, reserved map[string]bool) (string, error) {
+	// Slugs only contain [a-z0-9-], so base needs no LIKE escaping
+	rows, err := q.QueryContext(ctx, `
+        SELECT slug FROM posts WHERE slug = $1 OR slug LIKE $2
+        UNION
+        SELECT slug FROM post_slug_redirects WHERE slug = $1 OR slug LIKE $2
+    `, base, base+"-%")
+	if err != nil {
+		return "", err
+	}
+	defer rows.Close()
+
+	taken := make(map[string]bool)
+	for rows.Next() {
+		var slug string
+		if err := rows.Scan(&slug); err != nil {
+			return "", err
+		}
+		taken[slug] = true
+	}
+	if err := rows.Err(); err != nil {
+		return "", err
+	}
+
+	candidate := base
+	for n := 2; taken[candidate] || reserved[candidate]; n++ {
+		candidate = fmt.Sprintf("%s-%d", base, n)
+	}
+	return candidate, nil
+}
+
+// slugInUse reports whether slug belongs to any post other than exceptID,
+// either as its current slug or as a redirect.
+func slugInUse(ctx context.Context, q queryer, slug string, exceptID int) (bool, error) {
+	var taken bool
+	err := q.QueryRowContext(ctx, `
+        SELECT EXISTS (SELECT 1 FROM posts WHERE slug = $1 AND id <> $2)
+            OR EXISTS (SELECT 1 FROM post_slug_redirects WHERE slug = $1 AND post_id <> $2)
+    `, slug, exceptID).Scan(&taken)
+	return taken, err
+}
+
+// moveSlug records oldSlug as a redirect to the post and releases newSlug
+// if it was one of the post's own earlier slugs.
+func (s *PostgresStore) moveSlug(ctx context.Context, tx *sql.Tx, id int, oldSlug, newSlug string) error {
+	taken, err := slugInUse(ctx, tx, newSlug, id)
+	if err != nil {
+		return err
+	}
+	if taken {
+		return ErrSlugTaken
+	}
+
+	if _, err := tx.ExecContext(ctx, `DELETE FROM post_slug_redirects WHERE slug = $1 AND post_id = $2`, newSlug, id); err != nil {
+		return err
+	}
+	if oldSlug == "" {
+		return nil
+	}
+	_, err = tx.ExecContext(ctx, `
+        INSERT INTO post_slug_redirects (slug, post_id) VALUES ($1, $2)
+        ON CONFLICT (slug) DO UPDATE SET post_id = EXCLUDED.post_id, created_at = CURRENT_TIMESTAMP
+    `, oldSlug, id)
+	return err
+}
+
+// GetBySlug returns the post a slug points to. If slug is an old slug kept
+// as a redirect, the returned post carries its current slug, which the
+// caller can compare against to redirect.
+func (s *PostgresStore) GetBySlug(ctx context.Context, slug string) (*models.Post, error) {
+	query := `
+    SELECT ` + postColumns + ` 
+    FROM posts 
+    WHERE slug = $1 AND deleted_at IS NULL
+    `
+
+	var post models.Post
+	err := s.retryRead(ctx, func() (err error) {
+		post, err = scanPost(s.replicas.reader(ctx).QueryRowContext(ctx, query, slug))
+		return err
+	})
+	if err == nil {
+		return &post, nil
+	}
+	if err != sql.ErrNoRows {
+		return nil, err
+	}
+
+	var id int
+	err = s.retryRead(ctx, func() error {
+		return s.replicas.reader(ctx).QueryRowContext(ctx,
+			`SELECT post_id FROM post_slug_redirects WHERE slug = $1`, slug).Scan(&id)
+	})
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return s.GetByID(ctx, id)
+}
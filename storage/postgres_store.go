@@ -1,44 +1,112 @@
 package storage
 
 import (
+	"blog-api/database/migrations"
 	"blog-api/models"
+	"context"
 	"database/sql"
 	"fmt"
 	"log"
 	"strings"
 	"time"
 
-	_ "github.com/lib/pq"
+	"github.com/lib/pq"
 )
 
 type PostgresStore struct {
-	db *sql.DB
+	db          *sql.DB
+	readRetries int
+	replicas    *replicaSet
 }
 
-func (s *PostgresStore) GetPostsPaginated(query models.PostQuery) (*models.PaginatedPosts, error) {
+// PostgresOptions tunes the connection pool and retry behaviour.
+// Zero values leave the database/sql defaults in place.
+type PostgresOptions struct {
+	MaxOpenConns    int
+	MaxIdleConns    *int // nil keeps the database/sql default, 0 keeps none
+	ConnMaxLifetime time.Duration
+	ConnMaxIdleTime time.Duration
+
+	// ConnectTimeout bounds how long NewPostgresStore keeps retrying the
+	// initial connection while the database comes up.
+	ConnectTimeout time.Duration
+
+	// ReadRetries is how many times idempotent reads are retried after a
+	// transient error.
+	ReadRetries int
+
+	// ReplicaDSNs lists read replicas. GetAll, GetByID and
+	// GetPostsPaginated are spread across the healthy ones.
+	ReplicaDSNs          []string
+	ReplicaCheckInterval time.Duration
+
+	// ReadYourWritesWindow pins a client (see WithClientID) to the primary
+	// for this long after it writes, so it sees its own changes despite
+	// replication lag. Zero disables pinning.
+	ReadYourWritesWindow time.Duration
+}
+
+// postColumns lists the columns scanPost reads, in order.
+const postColumns = "id, title, content, author, COALESCE(slug, ''), created_at, updated_at"
+
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+func scanPost(row rowScanner) (models.Post, error) {
+	var post models.Post
+	err := row.Scan(
+		&post.ID,
+		&post.Title,
+		&post.Content,
+		&post.Author,
+		&post.Slug,
+		&post.CreatedAt,
+		&post.UpdatedAt,
+	)
+	return post, err
+}
+
+func (s *PostgresStore) GetPostsPaginated(ctx context.Context, query models.PostQuery) (*models.PaginatedPosts, error) {
 	whereClause, args := s.buildWhereClause(query)
 	orderClause := s.buildOrderClause(query)
 
 	// Build main query with cursor
 	mainQuery, args := s.buildPaginatedQuery(query, whereClause, orderClause, args)
 
-	// Execute query
-	rows, err := s.db.Query(mainQuery, args...)
-	if err != nil {
-		return nil, fmt.Errorf("failed to query posts: %w", err)
-	}
-	defer rows.Close()
+	// Execute query and parse results
+	var posts []models.Post
+	err := s.retryRead(ctx, func() error {
+		rows, err := s.replicas.reader(ctx).QueryContext(ctx, mainQuery, args...)
+		if err != nil {
+			return fmt.Errorf("failed to query posts: %w", err)
+		}
+		defer rows.Close()
 
-	// Parse results
-	posts, nextCursor, err := s.scanPaginatedPosts(rows, query)
+		posts = nil
+		for rows.Next() {
+			post, err := scanPost(rows)
+			if err != nil {
+				return err
+			}
+			posts = append(posts, post)
+		}
+		return rows.Err()
+	})
 	if err != nil {
 		return nil, err
 	}
+	return newPage(posts, query), nil
+}
 
-	// Determine if there are more results
-	hasMore := false
-	if len(posts) > 0 && len(posts) == query.Limit {
-		hasMore = true
+// newPage turns up to query.Limit+1 posts, fetched in the listing's order,
+// into a page. The extra post only signals that there are more.
+func newPage(posts []models.Post, query models.PostQuery) *models.PaginatedPosts {
+	var nextCursor string
+	if len(posts) > query.Limit {
+		posts = posts[:query.Limit] // Remove the extra
+		// Create cursor for next page
+		nextCursor = models.NewCursor(posts[len(posts)-1], query.SortBy).Encode()
 	}
 
 	// Generate previous cursor (if we have a cursor)
@@ -47,11 +115,7 @@ func (s *PostgresStore) GetPostsPaginated(query models.PostQuery) (*models.Pagin
 		// For simplicity, we'll use the first post's cursor as prev
 		// In production, you might want to implement proper backward pagination
 		if len(posts) > 0 {
-			firstCursor := &models.Cursor{
-				ID:        posts[0].ID,
-				CreatedAt: posts[0].CreatedAt,
-			}
-			prevCursor = firstCursor.Encode()
+			prevCursor = models.NewCursor(posts[0], query.SortBy).Encode()
 		}
 	}
 
@@ -59,22 +123,28 @@ func (s *PostgresStore) GetPostsPaginated(query models.PostQuery) (*models.Pagin
 		Posts:      posts,
 		PrevCursor: prevCursor,
 		NextCursor: nextCursor,
-		HasMore:    hasMore,
+		HasMore:    nextCursor != "",
 		//Total: total
-	}, nil
+	}
 }
 
 func (s *PostgresStore) buildWhereClause(query models.PostQuery) (string, []interface{}) {
-	var conditions []string
+	// Trashed posts are only reachable through the trash endpoints
+	conditions := []string{"deleted_at IS NULL"}
 	var args []interface{}
 	argIndex := 1
 
-	// Handle cursor
+	// Handle cursor: rows after it in the listing's order
 	if query.Cursor != "" {
 		cursor, err := models.DecodeCursor(query.Cursor)
-		if err == nil {
-			conditions = append(conditions, fmt.Sprintf("(created_at, id) < ($%d, $%d)", argIndex, argIndex+1))
-			args = append(args, cursor.CreatedAt, cursor.ID)
+		if err == nil && cursor.SortBy == sortColumn(query) {
+			op := "<"
+			if query.SortDir == "asc" {
+				op = ">"
+			}
+			conditions = append(conditions, fmt.Sprintf("(%s, id) %s ($%d, $%d)",
+				sortExpr(query), op, argIndex, argIndex+1))
+			args = append(args, cursor.Value(), cursor.ID)
 			argIndex += 2
 		}
 	}
@@ -87,22 +157,46 @@ func (s *PostgresStore) buildWhereClause(query models.PostQuery) (string, []inte
 	}
 	//Search
 	if query.Search != "" {
+		// Match the term literally, not as a LIKE pattern
+		pattern := "%" + likeEscaper.Replace(query.Search) + "%"
 		conditions = append(conditions,
 			fmt.Sprintf("(title ILIKE $%d OR content ILIKE $%d)",
 				argIndex, argIndex+1))
-		args = append(args, "%"+query.Search+"%", "%"+query.Search+"%")
+		args = append(args, pattern, pattern)
 		argIndex += 2
 	}
 
-	if len(conditions) == 0 {
-		return "", args
-	}
-
 	return "WHERE " + strings.Join(conditions, " AND "), args
 }
 
+var likeEscaper = strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`)
+
 func (s *PostgresStore) buildOrderClause(query models.PostQuery) string {
-	return "ORDER BY created_at DESC, id DESC"
+	dir := "DESC"
+	if query.SortDir == "asc" {
+		dir = "ASC"
+	}
+	return fmt.Sprintf("ORDER BY %s %s, id %s", sortExpr(query), dir, dir)
+}
+
+// sortColumn is the column a query sorts by, created_at unless it asks for
+// another allowed one.
+func sortColumn(query models.PostQuery) string {
+	switch query.SortBy {
+	case "updated_at", "title":
+		return query.SortBy
+	}
+	return "created_at"
+}
+
+// sortExpr is sortColumn as used in ORDER BY and cursor comparisons. Titles
+// compare by code point so the order does not depend on the database
+// locale.
+func sortExpr(query models.PostQuery) string {
+	if column := sortColumn(query); column != "title" {
+		return column
+	}
+	return `title COLLATE "C"`
 }
 
 func (s *PostgresStore) buildPaginatedQuery(
@@ -116,146 +210,171 @@ func (s *PostgresStore) buildPaginatedQuery(
 
 	// Build final query
 	sql := fmt.Sprintf(`
-        SELECT id, title, content, author, created_at, updated_at 
+        SELECT %s 
         FROM posts 
         %s 
         %s 
         %s
-    `, whereClause, orderClause, limitClause)
+    `, postColumns, whereClause, orderClause, limitClause)
 
 	return sql, args
 }
 
-func (s *PostgresStore) scanPaginatedPosts(
-	rows *sql.Rows,
-	query models.PostQuery,
-) ([]models.Post, string, error) {
-	var posts []models.Post
-	var nextCursor string
-
-	for rows.Next() {
-		var post models.Post
-		err := rows.Scan(
-			&post.ID,
-			&post.Title,
-			&post.Content,
-			&post.Author,
-			&post.CreatedAt,
-			&post.UpdatedAt,
-		)
-		if err != nil {
-			return nil, "", err
-		}
-		posts = append(posts, post)
+func NewPostgresStore(connectionString string, opts PostgresOptions) (*PostgresStore, error) {
+	db, err := openDB(connectionString, opts)
+	if err != nil {
+		return nil, err
 	}
 
-	if err := rows.Err(); err != nil {
-		return nil, "", err
+	if err := waitForDB(db, opts.ConnectTimeout); err != nil {
+		db.Close()
+		return nil, err
 	}
 
-	// Check if we have extra row for "has more"
-	if len(posts) > query.Limit {
-		posts = posts[:query.Limit] // Remove the extra
-		lastPost := posts[len(posts)-1]
+	log.Println("Connected to PostgreSQL database")
 
-		// Create cursor for next page
-		cursor := &models.Cursor{
-			ID:        lastPost.ID,
-			CreatedAt: lastPost.CreatedAt,
-		}
-		nextCursor = cursor.Encode()
+	replicas, err := newReplicaSet(db, opts)
+	if err != nil {
+		db.Close()
+		return nil, err
 	}
 
-	return posts, nextCursor, nil
+	return &PostgresStore{db: db, readRetries: opts.ReadRetries, replicas: replicas}, nil
 }
 
-func NewPostgresStore(connectionString string) (*PostgresStore, error) {
+func openDB(connectionString string, opts PostgresOptions) (*sql.DB, error) {
 	db, err := sql.Open("postgres", connectionString)
 	if err != nil {
 		return nil, err
 	}
 
-	if err := db.Ping(); err != nil {
-		return nil, err
+	if opts.MaxOpenConns > 0 {
+		db.SetMaxOpenConns(opts.MaxOpenConns)
+	}
+	if opts.MaxIdleConns != nil {
+		db.SetMaxIdleConns(*opts.MaxIdleConns)
+	}
+	if opts.ConnMaxLifetime > 0 {
+		db.SetConnMaxLifetime(opts.ConnMaxLifetime)
+	}
+	if opts.ConnMaxIdleTime > 0 {
+		db.SetConnMaxIdleTime(opts.ConnMaxIdleTime)
 	}
 
-	log.Println("Connected to PostgreSQL database")
-	return &PostgresStore{db: db}, nil
+	return db, nil
 }
 
-func (s *PostgresStore) Init() error {
-	return s.createPostsTable()
+var connectBackoff = Backoff{Initial: 250 * time.Millisecond, Max: 5 * time.Second}
+
+// waitForDB pings the database with exponential backoff until it answers or
+// the timeout expires, so the server can start before Postgres is ready.
+func waitForDB(db *sql.DB, timeout time.Duration) error {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	for attempt := 0; ; attempt++ {
+		err := db.PingContext(ctx)
+		if err == nil {
+			return nil
+		}
+		if ctx.Err() != nil {
+			return fmt.Errorf("database not reachable after %s: %w", timeout, err)
+		}
+
+		delay := connectBackoff.Delay(attempt)
+		log.Printf("Database not ready (attempt %d): %v; retrying in %s", attempt+1, err, delay.Round(time.Millisecond))
+
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("database not reachable after %s: %w", timeout, err)
+		case <-time.After(delay):
+		}
+	}
 }
 
-func (s *PostgresStore) createPostsTable() error {
-	query := `
-	CREATE TABLE IF NOT EXISTS posts (
-		id SERIAL PRIMARY KEY,
-		title VARCHAR(255) NOT NULL,
-		content TEXT NOT NULL,
-		author VARCHAR(100) NOT NULL,
-		created_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP,
-		updated_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP
-	);
-
-	CREATE INDEX IF NOT EXISTS idx_posts_created_at ON posts(created_at);
-	CREATE INDEX IF NOT EXISTS idx_posts_author ON posts(author);
-	`
-	_, err := s.db.Exec(query)
+// SchemaVersion is the migration version this build of the store expects.
+// It matches the newest file in database/migrations; bump both together.
+const SchemaVersion = 12
+
+// Init brings the schema up to date by applying the migrations in
+// database/migrations the database does not have yet.
+func (s *PostgresStore) Init() error {
+	all, err := LoadMigrations(migrations.FS)
+	if err != nil {
+		return err
+	}
+	if len(all) == 0 || all[len(all)-1].Version != SchemaVersion {
+		return fmt.Errorf("newest migration does not match SchemaVersion %d", SchemaVersion)
+	}
+
+	applied, err := s.Migrate(context.Background(), all, 0)
+	for _, m := range applied {
+		log.Printf("Applied migration %03d_%s", m.Version, m.Name)
+	}
 	return err
 }
 
-func (s *PostgresStore) GetAll() ([]models.Post, error) {
-	query := `
-    SELECT id, title, content, author, created_at, updated_at 
-    FROM posts 
-    ORDER BY created_at DESC
-    `
+// Ping verifies the database connection is alive.
+func (s *PostgresStore) Ping(ctx context.Context) error {
+	return s.db.PingContext(ctx)
+}
 
-	rows, err := s.db.Query(query)
+// MigrationVersion returns the schema version: the newest migration applied
+// with every one before it.
+func (s *PostgresStore) MigrationVersion(ctx context.Context) (int, error) {
+	applied, err := s.AppliedMigrations(ctx)
 	if err != nil {
-		return nil, err
+		return 0, err
 	}
-	defer rows.Close()
+	return SchemaVersionOf(applied), nil
+}
+
+func (s *PostgresStore) GetAll(ctx context.Context) ([]models.Post, error) {
+	query := `
+    SELECT ` + postColumns + ` 
+    FROM posts 
+    WHERE deleted_at IS NULL
+    ORDER BY created_at DESC, id DESC
+    `
 
 	var posts []models.Post
-	for rows.Next() {
-		var post models.Post
-		err := rows.Scan(
-			&post.ID,
-			&post.Title,
-			&post.Content,
-			&post.Author,
-			&post.CreatedAt,
-			&post.UpdatedAt,
-		)
+	err := s.retryRead(ctx, func() error {
+		rows, err := s.replicas.reader(ctx).QueryContext(ctx, query)
 		if err != nil {
-			return nil, err
+			return err
+		}
+		defer rows.Close()
+
+		posts = nil
+		for rows.Next() {
+			post, err := scanPost(rows)
+			if err != nil {
+				return err
+			}
+			posts = append(posts, post)
 		}
-		posts = append(posts, post)
+
+		return rows.Err()
+	})
+	if err != nil {
+		return nil, err
 	}
 
 	return posts, nil
 }
 
-func (s *PostgresStore) GetByID(id int) (*models.Post, error) {
+func (s *PostgresStore) GetByID(ctx context.Context, id int) (*models.Post, error) {
 	query := `
-    SELECT id, title, content, author, created_at, updated_at 
+    SELECT ` + postColumns + ` 
     FROM posts 
-    WHERE id = $1
+    WHERE id = $1 AND deleted_at IS NULL
     `
 
-	row := s.db.QueryRow(query, id)
-
 	var post models.Post
-	err := row.Scan(
-		&post.ID,
-		&post.Title,
-		&post.Content,
-		&post.Author,
-		&post.CreatedAt,
-		&post.UpdatedAt,
-	)
+	err := s.retryRead(ctx, func() (err error) {
+		post, err = scanPost(s.replicas.reader(ctx).QueryRowContext(ctx, query, id))
+		return err
+	})
 
 	if err == sql.ErrNoRows {
 		return nil, nil
@@ -268,96 +387,216 @@ func (s *PostgresStore) GetByID(id int) (*models.Post, error) {
 	return &post, nil
 }
 
-func (s *PostgresStore) Create(post models.Post) (*models.Post, error) {
+// GetByIDs returns the posts with the given ids, in no particular order.
+func (s *PostgresStore) GetByIDs(ctx context.Context, ids []int) ([]models.Post, error) {
+	query := `
+    SELECT ` + postColumns + ` 
+    FROM posts 
+    WHERE id = ANY($1) AND deleted_at IS NULL
+    `
+
+	keys := make(pq.Int64Array, len(ids))
+	for i, id := range ids {
+		keys[i] = int64(id)
+	}
+
+	var posts []models.Post
+	err := s.retryRead(ctx, func() error {
+		rows, err := s.replicas.reader(ctx).QueryContext(ctx, query, keys)
+		if err != nil {
+			return err
+		}
+		defer rows.Close()
+
+		posts = nil
+		for rows.Next() {
+			post, err := scanPost(rows)
+			if err != nil {
+				return err
+			}
+			posts = append(posts, post)
+		}
+		return rows.Err()
+	})
+	if err != nil {
+		return nil, err
+	}
+	return posts, nil
+}
+
+func (s *PostgresStore) Create(ctx context.Context, post models.Post) (*models.Post, error) {
+	// A concurrent insert can claim the same generated slug between
+	// allocation and INSERT; pick the next free one and try again.
+	for attempt := 0; ; attempt++ {
+		created, err := s.insertPost(ctx, post)
+		if isSlugConflict(err) {
+			if post.Slug != "" || attempt >= 3 {
+				return nil, ErrSlugTaken
+			}
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		s.replicas.pin(ctx)
+		return created, nil
+	}
+}
+
+// insertPost inserts one post and its post.created event.
+func (s *PostgresStore) insertPost(ctx context.Context, post models.Post) (*models.Post, error) {
 	query := `
-    INSERT INTO posts (title, content, author) 
-    VALUES ($1, $2, $3) 
-    RETURNING id, created_at, updated_at
+    INSERT INTO posts (title, content, author, slug) 
+    VALUES ($1, $2, $3, $4) 
+    RETURNING ` + postColumns + `
     `
 
-	var id int
-	var createdAt, updatedAt time.Time
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	slug, err := s.chooseSlug(ctx, tx, post, nil)
+	if err != nil {
+		return nil, err
+	}
 
-	err := s.db.QueryRow(
+	created, err := scanPost(tx.QueryRowContext(
+		ctx,
 		query,
 		post.Title,
 		post.Content,
 		post.Author,
-	).Scan(&id, &createdAt, &updatedAt)
+		slug,
+	))
+	if err != nil {
+		return nil, err
+	}
+
+	if err := writeOutbox(ctx, tx, newPostEvent(models.EventPostCreated, created.ID, &created)); err != nil {
+		return nil, err
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return &created, nil
+}
 
+// Update changes the supplied fields of a post. Changing the slug keeps the
+// old one as a redirect so existing permalinks still resolve.
+func (s *PostgresStore) Update(ctx context.Context, id int, updated models.Post) (*models.Post, error) {
+	tx, err := s.db.BeginTx(ctx, nil)
 	if err != nil {
 		return nil, err
 	}
+	defer tx.Rollback()
 
-	post.ID = id
-	post.CreatedAt = createdAt
-	post.UpdatedAt = updatedAt
+	if updated.Slug != "" {
+		var current string
+		err := tx.QueryRowContext(ctx, `SELECT COALESCE(slug, '') FROM posts WHERE id = $1 AND deleted_at IS NULL FOR UPDATE`, id).Scan(&current)
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		if err != nil {
+			return nil, err
+		}
 
-	return &post, nil
-}
+		if updated.Slug != current {
+			if err := s.moveSlug(ctx, tx, id, current, updated.Slug); err != nil {
+				return nil, err
+			}
+		}
+	}
 
-func (s *PostgresStore) Update(id int, updated models.Post) (*models.Post, error) {
 	query := `
     UPDATE posts 
     SET 
         title = COALESCE(NULLIF($1, ''), title),
         content = COALESCE(NULLIF($2, ''), content),
         author = COALESCE(NULLIF($3, ''), author),
+        slug = COALESCE(NULLIF($4, ''), slug),
         updated_at = CURRENT_TIMESTAMP
-    WHERE id = $4
-    RETURNING title, content, author, created_at, updated_at
+    WHERE id = $5 AND deleted_at IS NULL
+    RETURNING ` + postColumns + `
     `
 
-	row := s.db.QueryRow(
+	post, err := scanPost(tx.QueryRowContext(
+		ctx,
 		query,
 		updated.Title,
 		updated.Content,
 		updated.Author,
+		updated.Slug,
 		id,
-	)
-
-	var post models.Post
-	post.ID = id
-
-	err := row.Scan(
-		&post.Title,
-		&post.Content,
-		&post.Author,
-		&post.CreatedAt,
-		&post.UpdatedAt,
-	)
+	))
 
 	if err == sql.ErrNoRows {
 		return nil, nil
 	}
 
+	if isSlugConflict(err) {
+		return nil, ErrSlugTaken
+	}
+
 	if err != nil {
 		return nil, err
 	}
 
+	if err := writeOutbox(ctx, tx, newPostEvent(models.EventPostUpdated, post.ID, &post)); err != nil {
+		return nil, err
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+
+	s.replicas.pin(ctx)
 	return &post, nil
 }
 
-func (s *PostgresStore) Delete(id int) error {
-	query := `DELETE FROM posts WHERE id = $1`
+// Delete moves a post to the trash. It can be restored until it is purged.
+func (s *PostgresStore) Delete(ctx context.Context, id int) error {
+	_, err := s.Remove(ctx, id)
+	return err
+}
+
+// Remove moves a post to the trash and reports whether there was one.
+func (s *PostgresStore) Remove(ctx context.Context, id int) (bool, error) {
+	query := `UPDATE posts SET deleted_at = CURRENT_TIMESTAMP WHERE id = $1 AND deleted_at IS NULL`
 
-	result, err := s.db.Exec(query, id)
+	tx, err := s.db.BeginTx(ctx, nil)
 	if err != nil {
-		return err
+		return false, err
+	}
+	defer tx.Rollback()
+
+	result, err := tx.ExecContext(ctx, query, id)
+	if err != nil {
+		return false, err
 	}
 
 	rowsAffected, err := result.RowsAffected()
 	if err != nil {
-		return err
+		return false, err
 	}
 
 	if rowsAffected == 0 {
-		return nil // Or return an error if you want to indicate not found
+		return false, nil
+	}
+
+	if err := writeOutbox(ctx, tx, newPostEvent(models.EventPostDeleted, id, nil)); err != nil {
+		return false, err
+	}
+	if err := tx.Commit(); err != nil {
+		return false, err
 	}
 
-	return nil
+	s.replicas.pin(ctx)
+	return true, nil
 }
 
 func (s *PostgresStore) Close() error {
+	s.replicas.close()
 	return s.db.Close()
 }
@@ -0,0 +1,132 @@
+package storage
+
+import (
+	"blog-api/models"
+	"context"
+	"database/sql"
+	"time"
+)
+
+func scanTrashedPost(row rowScanner) (models.Post, error) {
+	var post models.Post
+	var deletedAt time.Time
+	err := row.Scan(
+		&post.ID,
+		&post.Title,
+		&post.Content,
+		&post.Author,
+		&post.Slug,
+		&post.CreatedAt,
+		&post.UpdatedAt,
+		&deletedAt,
+	)
+	if err != nil {
+		return models.Post{}, err
+	}
+	post.DeletedAt = &deletedAt
+	return post, nil
+}
+
+func (s *PostgresStore) ListTrash(ctx context.Context, limit int) ([]models.Post, error) {
+	query := `
+    SELECT ` + postColumns + `, deleted_at
+    FROM posts
+    WHERE deleted_at IS NOT NULL
+    ORDER BY deleted_at DESC, id DESC
+    LIMIT $1
+    `
+
+	var posts []models.Post
+	err := s.retryRead(ctx, func() error {
+		rows, err := s.replicas.reader(ctx).QueryContext(ctx, query, limit)
+		if err != nil {
+			return err
+		}
+		defer rows.Close()
+
+		posts = nil
+		for rows.Next() {
+			post, err := scanTrashedPost(rows)
+			if err != nil {
+				return err
+			}
+			posts = append(posts, post)
+		}
+		return rows.Err()
+	})
+	if err != nil {
+		return nil, err
+	}
+	return posts, nil
+}
+
+// LastDeleted reads the newest deleted_at off its partial index.
+func (s *PostgresStore) LastDeleted(ctx context.Context) (time.Time, error) {
+	var last sql.NullTime
+	err := s.retryRead(ctx, func() error {
+		return s.replicas.reader(ctx).QueryRowContext(ctx,
+			`SELECT MAX(deleted_at) FROM posts WHERE deleted_at IS NOT NULL`).Scan(&last)
+	})
+	return last.Time, err
+}
+
+// Restore brings a trashed post back. Subscribers see it as created again.
+func (s *PostgresStore) Restore(ctx context.Context, id int) (*models.Post, error) {
+	query := `
+    UPDATE posts
+    SET deleted_at = NULL,
+        updated_at = CURRENT_TIMESTAMP
+    WHERE id = $1 AND deleted_at IS NOT NULL
+    RETURNING ` + postColumns + `
+    `
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	post, err := scanPost(tx.QueryRowContext(ctx, query, id))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if err := writeOutbox(ctx, tx, newPostEvent(models.EventPostCreated, post.ID, &post)); err != nil {
+		return nil, err
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+
+	s.replicas.pin(ctx)
+	return &post, nil
+}
+
+// Purge deletes a trashed post for good. Its deletion was announced when it
+// was trashed, so no event is written.
+func (s *PostgresStore) Purge(ctx context.Context, id int) (bool, error) {
+	result, err := s.db.ExecContext(ctx, `DELETE FROM posts WHERE id = $1 AND deleted_at IS NOT NULL`, id)
+	if err != nil {
+		return false, err
+	}
+	n, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+
+	s.replicas.pin(ctx)
+	return n > 0, nil
+}
+
+// PurgeTrash deletes every post trashed before the given time.
+func (s *PostgresStore) PurgeTrash(ctx context.Context, before time.Time) (int, error) {
+	result, err := s.db.ExecContext(ctx, `DELETE FROM posts WHERE deleted_at < $1`, before)
+	if err != nil {
+		return 0, err
+	}
+	n, err := result.RowsAffected()
+	return int(n), err
+}
@@ -0,0 +1,282 @@
+package storage
+
+import (
+	"blog-api/models"
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+const subscriptionColumns = "id, url, secret, events, active, created_at, updated_at"
+
+func scanSubscription(row rowScanner) (models.WebhookSubscription, error) {
+	var sub models.WebhookSubscription
+	err := row.Scan(
+		&sub.ID,
+		&sub.URL,
+		&sub.Secret,
+		pq.Array(&sub.Events),
+		&sub.Active,
+		&sub.CreatedAt,
+		&sub.UpdatedAt,
+	)
+	return sub, err
+}
+
+const deliveryColumns = `d.id, d.subscription_id, d.event_id, d.event, d.payload, d.status, d.attempts,
+	COALESCE(d.last_status_code, 0), COALESCE(d.last_error, ''), d.next_attempt_at, d.created_at, d.delivered_at`
+
+func scanDelivery(row rowScanner, extra ...interface{}) (models.WebhookDelivery, error) {
+	var d models.WebhookDelivery
+	var next, delivered sql.NullTime
+	dest := []interface{}{
+		&d.ID,
+		&d.SubscriptionID,
+		&d.EventID,
+		&d.Event,
+		&d.Payload,
+		&d.Status,
+		&d.Attempts,
+		&d.LastStatusCode,
+		&d.LastError,
+		&next,
+		&d.CreatedAt,
+		&delivered,
+	}
+	if err := row.Scan(append(dest, extra...)...); err != nil {
+		return d, err
+	}
+	if next.Valid && d.Status == models.DeliveryPending {
+		d.NextAttemptAt = &next.Time
+	}
+	if delivered.Valid {
+		d.DeliveredAt = &delivered.Time
+	}
+	return d, nil
+}
+
+func (s *PostgresStore) CreateSubscription(ctx context.Context, sub models.WebhookSubscription) (*models.WebhookSubscription, error) {
+	query := `
+    INSERT INTO webhook_subscriptions (url, secret, events, active)
+    VALUES ($1, $2, $3, TRUE)
+    RETURNING ` + subscriptionColumns
+
+	created, err := scanSubscription(s.db.QueryRowContext(ctx, query, sub.URL, sub.Secret, pq.Array(sub.Events)))
+	if err != nil {
+		return nil, err
+	}
+	return &created, nil
+}
+
+func (s *PostgresStore) ListSubscriptions(ctx context.Context) ([]models.WebhookSubscription, error) {
+	rows, err := s.db.QueryContext(ctx, `SELECT `+subscriptionColumns+` FROM webhook_subscriptions ORDER BY id`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	subs := []models.WebhookSubscription{}
+	for rows.Next() {
+		sub, err := scanSubscription(rows)
+		if err != nil {
+			return nil, err
+		}
+		subs = append(subs, sub)
+	}
+	return subs, rows.Err()
+}
+
+func (s *PostgresStore) GetSubscription(ctx context.Context, id int) (*models.WebhookSubscription, error) {
+	sub, err := scanSubscription(s.db.QueryRowContext(ctx,
+		`SELECT `+subscriptionColumns+` FROM webhook_subscriptions WHERE id = $1`, id))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &sub, nil
+}
+
+// UpdateSubscription changes the supplied fields of a subscription.
+func (s *PostgresStore) UpdateSubscription(ctx context.Context, id int, req models.UpdateWebhookRequest) (*models.WebhookSubscription, error) {
+	query := `
+    UPDATE webhook_subscriptions
+    SET
+        url = COALESCE(NULLIF($1, ''), url),
+        secret = COALESCE(NULLIF($2, ''), secret),
+        events = COALESCE($3, events),
+        active = COALESCE($4, active),
+        updated_at = CURRENT_TIMESTAMP
+    WHERE id = $5
+    RETURNING ` + subscriptionColumns
+
+	var events interface{}
+	if len(req.Events) > 0 {
+		events = pq.Array(req.Events)
+	}
+
+	sub, err := scanSubscription(s.db.QueryRowContext(ctx, query, req.URL, req.Secret, events, req.Active, id))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &sub, nil
+}
+
+func (s *PostgresStore) DeleteSubscription(ctx context.Context, id int) (bool, error) {
+	result, err := s.db.ExecContext(ctx, `DELETE FROM webhook_subscriptions WHERE id = $1`, id)
+	if err != nil {
+		return false, err
+	}
+	n, err := result.RowsAffected()
+	return n > 0, err
+}
+
+func (s *PostgresStore) EnqueueDeliveries(ctx context.Context, eventID, eventType string, payload []byte) (int, error) {
+	query := `
+    INSERT INTO webhook_deliveries (subscription_id, event_id, event, payload, next_attempt_at)
+    SELECT id, $1, $2, $3::jsonb, CURRENT_TIMESTAMP
+    FROM webhook_subscriptions
+    WHERE active AND $2 = ANY(events)
+    ON CONFLICT (event_id, subscription_id) WHERE redelivery_of IS NULL DO NOTHING
+    `
+	result, err := s.db.ExecContext(ctx, query, eventID, eventType, string(payload))
+	if err != nil {
+		return 0, err
+	}
+	n, err := result.RowsAffected()
+	return int(n), err
+}
+
+func (s *PostgresStore) ClaimDeliveries(ctx context.Context, limit int, lease time.Duration) ([]models.WebhookDelivery, error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	// SKIP LOCKED lets several instances claim disjoint batches at once
+	query := `
+    SELECT ` + deliveryColumns + `, s.url, s.secret
+    FROM webhook_deliveries d
+    JOIN webhook_subscriptions s ON s.id = d.subscription_id
+    WHERE d.status = 'pending' AND d.next_attempt_at <= CURRENT_TIMESTAMP AND s.active
+    ORDER BY d.next_attempt_at
+    LIMIT $1
+    FOR UPDATE OF d SKIP LOCKED
+    `
+	rows, err := tx.QueryContext(ctx, query, limit)
+	if err != nil {
+		return nil, err
+	}
+
+	var claimed []models.WebhookDelivery
+	var ids []int64
+	for rows.Next() {
+		var d models.WebhookDelivery
+		var url, secret string
+		d, err = scanDelivery(rows, &url, &secret)
+		if err != nil {
+			break
+		}
+		d.URL, d.Secret = url, secret
+		claimed = append(claimed, d)
+		ids = append(ids, d.ID)
+	}
+	rows.Close()
+	if err == nil {
+		err = rows.Err()
+	}
+	if err != nil {
+		return nil, err
+	}
+	if len(claimed) == 0 {
+		return nil, nil
+	}
+
+	_, err = tx.ExecContext(ctx,
+		`UPDATE webhook_deliveries SET next_attempt_at = CURRENT_TIMESTAMP + $1::double precision * INTERVAL '1 millisecond' WHERE id = ANY($2)`,
+		lease.Milliseconds(), pq.Array(ids))
+	if err != nil {
+		return nil, err
+	}
+	return claimed, tx.Commit()
+}
+
+func (s *PostgresStore) RecordAttempt(ctx context.Context, id int64, result DeliveryResult) error {
+	status := models.DeliveryPending
+	var next, delivered interface{}
+	switch {
+	case result.Succeeded:
+		status, delivered = models.DeliverySucceeded, time.Now()
+	case result.RetryAt.IsZero():
+		status = models.DeliveryFailed
+	default:
+		next = result.RetryAt
+	}
+
+	query := `
+    UPDATE webhook_deliveries
+    SET
+        status = $1,
+        attempts = attempts + 1,
+        last_status_code = NULLIF($2, 0),
+        last_error = NULLIF($3, ''),
+        next_attempt_at = $4,
+        delivered_at = $5
+    WHERE id = $6
+    `
+	_, err := s.db.ExecContext(ctx, query, status, result.StatusCode, result.Error, next, delivered, id)
+	return err
+}
+
+func (s *PostgresStore) ListDeliveries(ctx context.Context, subscriptionID, limit int) ([]models.WebhookDelivery, error) {
+	query := `
+    SELECT ` + deliveryColumns + `
+    FROM webhook_deliveries d
+    WHERE d.subscription_id = $1
+    ORDER BY d.id DESC
+    LIMIT $2
+    `
+	rows, err := s.db.QueryContext(ctx, query, subscriptionID, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	deliveries := []models.WebhookDelivery{}
+	for rows.Next() {
+		d, err := scanDelivery(rows)
+		if err != nil {
+			return nil, err
+		}
+		deliveries = append(deliveries, d)
+	}
+	return deliveries, rows.Err()
+}
+
+func (s *PostgresStore) Redeliver(ctx context.Context, subscriptionID int, deliveryID int64) (*models.WebhookDelivery, error) {
+	query := `
+    WITH d AS (
+        INSERT INTO webhook_deliveries (subscription_id, event_id, event, payload, next_attempt_at, redelivery_of)
+        SELECT subscription_id, event_id, event, payload, CURRENT_TIMESTAMP, COALESCE(redelivery_of, id)
+        FROM webhook_deliveries
+        WHERE id = $1 AND subscription_id = $2
+        RETURNING *
+    )
+    SELECT ` + deliveryColumns + ` FROM d
+    `
+	d, err := scanDelivery(s.db.QueryRowContext(ctx, query, deliveryID, subscriptionID))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &d, nil
+}
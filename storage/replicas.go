@@ -0,0 +1,194 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"log"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+type clientIDKey struct{}
+
+// WithClientID tags ctx with the identity of the calling client. Writes made
+// under a client ID pin that client's reads to the primary for the
+// read-your-writes window.
+func WithClientID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, clientIDKey{}, id)
+}
+
+type cacheFillKey struct{}
+
+// withCacheFill marks reads under ctx as filling a cache shared by every
+// client, last invalidated at invalidated. Within the read-your-writes
+// window after that they use the primary: a replica that has not caught up
+// would put the old version back for everyone until the entry expires.
+func withCacheFill(ctx context.Context, invalidated time.Time) context.Context {
+	return context.WithValue(ctx, cacheFillKey{}, invalidated)
+}
+
+// ClientIDFrom returns the client ID ctx was tagged with, or "".
+func ClientIDFrom(ctx context.Context) string {
+	id, _ := ctx.Value(clientIDKey{}).(string)
+	return id
+}
+
+type replica struct {
+	db      *sql.DB
+	healthy atomic.Bool
+}
+
+// replicaSet routes reads to healthy replicas in round-robin order and
+// falls back to the primary when none are available.
+type replicaSet struct {
+	primary  *sql.DB
+	replicas []*replica
+	next     atomic.Uint64
+
+	pinWindow time.Duration
+	mu        sync.Mutex
+	pinned    map[string]time.Time
+
+	stop chan struct{}
+	wg   sync.WaitGroup
+}
+
+func newReplicaSet(primary *sql.DB, opts PostgresOptions) (*replicaSet, error) {
+	rs := &replicaSet{
+		primary:   primary,
+		pinWindow: opts.ReadYourWritesWindow,
+		pinned:    make(map[string]time.Time),
+		stop:      make(chan struct{}),
+	}
+
+	for _, dsn := range opts.ReplicaDSNs {
+		db, err := openDB(dsn, opts)
+		if err != nil {
+			rs.close()
+			return nil, err
+		}
+		rs.replicas = append(rs.replicas, &replica{db: db})
+	}
+
+	if len(rs.replicas) == 0 {
+		return rs, nil
+	}
+
+	interval := opts.ReplicaCheckInterval
+	if interval <= 0 {
+		interval = 5 * time.Second
+	}
+
+	rs.checkAll(interval)
+	log.Printf("Routing reads across %d replica(s)", len(rs.replicas))
+
+	rs.wg.Add(1)
+	go rs.healthLoop(interval)
+
+	return rs, nil
+}
+
+// reader returns the connection pool a read for ctx should use.
+func (rs *replicaSet) reader(ctx context.Context) *sql.DB {
+	if len(rs.replicas) == 0 || rs.isPinned(ctx) {
+		return rs.primary
+	}
+	if invalidated, ok := ctx.Value(cacheFillKey{}).(time.Time); ok && time.Since(invalidated) < rs.pinWindow {
+		return rs.primary
+	}
+
+	n := uint64(len(rs.replicas))
+	start := rs.next.Add(1)
+	for i := uint64(0); i < n; i++ {
+		r := rs.replicas[(start+i)%n]
+		if r.healthy.Load() {
+			return r.db
+		}
+	}
+
+	return rs.primary
+}
+
+// pin routes the client's reads to the primary for the read-your-writes window.
+func (rs *replicaSet) pin(ctx context.Context) {
+	if len(rs.replicas) == 0 || rs.pinWindow <= 0 {
+		return
+	}
+	id := ClientIDFrom(ctx)
+	if id == "" {
+		return
+	}
+
+	rs.mu.Lock()
+	rs.pinned[id] = time.Now().Add(rs.pinWindow)
+	rs.mu.Unlock()
+}
+
+func (rs *replicaSet) isPinned(ctx context.Context) bool {
+	id := ClientIDFrom(ctx)
+	if id == "" {
+		return false
+	}
+
+	rs.mu.Lock()
+	defer rs.mu.Unlock()
+	until, ok := rs.pinned[id]
+	return ok && time.Now().Before(until)
+}
+
+func (rs *replicaSet) healthLoop(interval time.Duration) {
+	defer rs.wg.Done()
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-rs.stop:
+			return
+		case <-ticker.C:
+			rs.checkAll(interval)
+			rs.expirePins()
+		}
+	}
+}
+
+func (rs *replicaSet) checkAll(timeout time.Duration) {
+	for i, r := range rs.replicas {
+		ctx, cancel := context.WithTimeout(context.Background(), timeout)
+		err := r.db.PingContext(ctx)
+		cancel()
+
+		healthy := err == nil
+		if r.healthy.Swap(healthy) != healthy {
+			if healthy {
+				log.Printf("Replica %d is healthy", i)
+			} else {
+				log.Printf("Replica %d is unhealthy: %v", i, err)
+			}
+		}
+	}
+}
+
+func (rs *replicaSet) expirePins() {
+	now := time.Now()
+
+	rs.mu.Lock()
+	defer rs.mu.Unlock()
+	for id, until := range rs.pinned {
+		if now.After(until) {
+			delete(rs.pinned, id)
+		}
+	}
+}
+
+func (rs *replicaSet) close() {
+	if len(rs.replicas) > 0 {
+		close(rs.stop)
+		rs.wg.Wait()
+	}
+	for _, r := range rs.replicas {
+		r.db.Close()
+	}
+}
@@ -0,0 +1,45 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+	"time"
+)
+
+func TestReaderForCacheFills(t *testing.T) {
+	// sql.Open does not connect, so these never reach a server
+	open := func() *sql.DB {
+		db, err := sql.Open("postgres", "host=localhost dbname=unused")
+		if err != nil {
+			t.Fatal(err)
+		}
+		t.Cleanup(func() { db.Close() })
+		return db
+	}
+	r := &replica{db: open()}
+	r.healthy.Store(true)
+	rs := &replicaSet{
+		primary:   open(),
+		replicas:  []*replica{r},
+		pinWindow: time.Minute,
+		pinned:    make(map[string]time.Time),
+	}
+
+	ctx := context.Background()
+	tests := []struct {
+		name    string
+		ctx     context.Context
+		primary bool
+	}{
+		{"plain read", ctx, false},
+		{"fill never invalidated", withCacheFill(ctx, time.Unix(0, 0)), false},
+		{"fill long after invalidation", withCacheFill(ctx, time.Now().Add(-time.Hour)), false},
+		{"fill right after invalidation", withCacheFill(ctx, time.Now()), true},
+	}
+	for _, tt := range tests {
+		if got := rs.reader(tt.ctx) == rs.primary; got != tt.primary {
+			t.Errorf("%s: reads from primary = %v, want %v", tt.name, got, tt.primary)
+		}
+	}
+}
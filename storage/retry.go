@@ -0,0 +1,83 @@
+package storage
+
+import (
+	"context"
+	"database/sql/driver"
+	"errors"
+	"io"
+	"math/rand"
+	"net"
+	"syscall"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// Backoff describes an exponential backoff schedule with full jitter.
+type Backoff struct {
+	Initial time.Duration
+	Max     time.Duration
+}
+
+// Delay returns how long to wait before retry number attempt (0-based).
+func (b Backoff) Delay(attempt int) time.Duration {
+	d := b.Initial << attempt
+	if d <= 0 || d > b.Max {
+		d = b.Max
+	}
+	return time.Duration(rand.Int63n(int64(d)) + 1)
+}
+
+var readBackoff = Backoff{Initial: 20 * time.Millisecond, Max: 500 * time.Millisecond}
+
+// retryRead runs an idempotent read, retrying it up to s.readRetries times
+// when it fails with a transient error.
+func (s *PostgresStore) retryRead(ctx context.Context, fn func() error) error {
+	var err error
+	for attempt := 0; ; attempt++ {
+		err = fn()
+		if err == nil || attempt >= s.readRetries || !isTransient(err) {
+			return err
+		}
+
+		select {
+		case <-ctx.Done():
+			return err
+		case <-time.After(readBackoff.Delay(attempt)):
+		}
+	}
+}
+
+// isTransient reports whether err is worth retrying: serialization
+// failures, deadlocks, dropped connections and server restarts.
+func isTransient(err error) bool {
+	if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return false
+	}
+
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) {
+		switch pqErr.Code {
+		case "40001", // serialization_failure
+			"40P01", // deadlock_detected
+			"57P01", // admin_shutdown
+			"57P02", // crash_shutdown
+			"57P03": // cannot_connect_now
+			return true
+		}
+		// Class 08: connection exceptions
+		return pqErr.Code.Class() == "08"
+	}
+
+	if errors.Is(err, driver.ErrBadConn) ||
+		errors.Is(err, io.EOF) ||
+		errors.Is(err, io.ErrUnexpectedEOF) ||
+		errors.Is(err, syscall.ECONNRESET) ||
+		errors.Is(err, syscall.ECONNREFUSED) ||
+		errors.Is(err, syscall.EPIPE) {
+		return true
+	}
+
+	var netErr net.Error
+	return errors.As(err, &netErr) && netErr.Timeout()
+}
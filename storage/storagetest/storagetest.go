@@ -0,0 +1,685 @@
+// Package storagetest is a conformance suite for storage.PostStore
+// implementations. A backend passes if it behaves like the others: the
+// same not-found results, slug handling, sort orders, filters and cursor
+// pagination.
+//
+//	func TestConformance(t *testing.T) {
+//		storagetest.Run(t, func(t *testing.T) storage.PostStore {
+//			return storage.NewInMemoryPostStore()
+//		})
+//	}
+package storagetest
+
+import (
+	"blog-api/models"
+	"blog-api/storage"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// Run runs the suite against stores from newStore, which is called once per
+// subtest and should arrange for the store to be closed. Stores need not be
+// empty: each subtest writes posts under an author of its own and only
+// lists that author's posts.
+func Run(t *testing.T, newStore func(t *testing.T) storage.PostStore) {
+	tests := []struct {
+		name string
+		fn   func(t *testing.T, s *suite)
+	}{
+		{"CRUD", testCRUD},
+		{"NotFound", testNotFound},
+		{"Slugs", testSlugs},
+		{"SortOrders", testSortOrders},
+		{"AuthorFilter", testAuthorFilter},
+		{"Search", testSearch},
+		{"CursorRoundTrip", testCursorRoundTrip},
+		{"LastPage", testLastPage},
+		{"ConcurrentInserts", testConcurrentInserts},
+		{"Remove", testRemove},
+		{"AuthorBatch", testAuthorBatch},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.fn(t, &suite{store: newStore(t), author: "storagetest " + token(t)})
+		})
+	}
+}
+
+type suite struct {
+	store  storage.PostStore
+	author string
+}
+
+// sorts lists every sort order a listing accepts.
+var sorts = []struct{ by, dir string }{
+	{"created_at", "desc"}, {"created_at", "asc"},
+	{"updated_at", "desc"}, {"updated_at", "asc"},
+	{"title", "desc"}, {"title", "asc"},
+}
+
+// token returns a random string to keep this run's data apart.
+func token(t *testing.T) string {
+	b := make([]byte, 6)
+	if _, err := rand.Read(b); err != nil {
+		t.Fatal(err)
+	}
+	return hex.EncodeToString(b)
+}
+
+func (s *suite) create(t *testing.T, post models.Post) models.Post {
+	t.Helper()
+	if post.Author == "" {
+		post.Author = s.author
+	}
+	if post.Content == "" {
+		post.Content = "Content of " + post.Title
+	}
+	created, err := s.store.Create(context.Background(), post)
+	if err != nil {
+		t.Fatalf("Create(%q): %v", post.Title, err)
+	}
+	if created == nil {
+		t.Fatalf("Create(%q) returned no post", post.Title)
+	}
+	return *created
+}
+
+func (s *suite) update(t *testing.T, id int, post models.Post) models.Post {
+	t.Helper()
+	updated, err := s.store.Update(context.Background(), id, post)
+	if err != nil {
+		t.Fatalf("Update(%d): %v", id, err)
+	}
+	if updated == nil {
+		t.Fatalf("Update(%d) found no post", id)
+	}
+	return *updated
+}
+
+func (s *suite) get(t *testing.T, id int) *models.Post {
+	t.Helper()
+	post, err := s.store.GetByID(context.Background(), id)
+	if err != nil {
+		t.Fatalf("GetByID(%d): %v", id, err)
+	}
+	return post
+}
+
+func (s *suite) query(sortBy, sortDir string, limit int) models.PostQuery {
+	query := models.DefaultPostQuery()
+	query.Author = s.author
+	query.SortBy = sortBy
+	query.SortDir = sortDir
+	query.Limit = limit
+	return query
+}
+
+func (s *suite) page(t *testing.T, query models.PostQuery) *models.PaginatedPosts {
+	t.Helper()
+	if err := query.Validate(); err != nil {
+		t.Fatalf("invalid query %+v: %v", query, err)
+	}
+	page, err := s.store.GetPostsPaginated(context.Background(), query)
+	if err != nil {
+		t.Fatalf("GetPostsPaginated(%+v): %v", query, err)
+	}
+	if len(page.Posts) > query.Limit {
+		t.Fatalf("page has %d posts, limit is %d", len(page.Posts), query.Limit)
+	}
+	if page.HasMore != (page.NextCursor != "") {
+		t.Fatalf("has_more is %v but next_cursor is %q", page.HasMore, page.NextCursor)
+	}
+	return page
+}
+
+// listAll follows next_cursor from the first page to the last.
+func (s *suite) listAll(t *testing.T, query models.PostQuery) []models.Post {
+	t.Helper()
+	var posts []models.Post
+	for pages := 0; ; pages++ {
+		if pages > 1000 {
+			t.Fatal("pagination does not end")
+		}
+		page := s.page(t, query)
+		posts = append(posts, page.Posts...)
+		if !page.HasMore {
+			return posts
+		}
+		if len(page.Posts) == 0 {
+			t.Fatal("empty page claims to have more")
+		}
+		query.Cursor = page.NextCursor
+	}
+}
+
+func ids(posts []models.Post) []int {
+	out := make([]int, len(posts))
+	for i, post := range posts {
+		out[i] = post.ID
+	}
+	return out
+}
+
+func sameIDs(a, b []int) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// checkPost compares the stored fields of two posts.
+func checkPost(t *testing.T, got *models.Post, want models.Post) {
+	t.Helper()
+	if got == nil {
+		t.Fatalf("post %d not found", want.ID)
+	}
+	if got.ID != want.ID || got.Title != want.Title || got.Content != want.Content ||
+		got.Author != want.Author || got.Slug != want.Slug {
+		t.Errorf("got post %+v, want %+v", *got, want)
+	}
+	if !got.CreatedAt.Equal(want.CreatedAt) || !got.UpdatedAt.Equal(want.UpdatedAt) {
+		t.Errorf("post %d timestamps are %v/%v, want %v/%v",
+			want.ID, got.CreatedAt, got.UpdatedAt, want.CreatedAt, want.UpdatedAt)
+	}
+}
+
+// ordered sorts posts the way a listing with sortBy and sortDir must: by
+// that field (titles by code point) with ID breaking ties.
+func ordered(posts []models.Post, sortBy, sortDir string) []models.Post {
+	out := append([]models.Post(nil), posts...)
+	sort.Slice(out, func(i, j int) bool {
+		a, b := out[i], out[j]
+		var c int
+		switch sortBy {
+		case "updated_at":
+			c = a.UpdatedAt.Compare(b.UpdatedAt)
+		case "title":
+			c = strings.Compare(a.Title, b.Title)
+		default:
+			c = a.CreatedAt.Compare(b.CreatedAt)
+		}
+		if c == 0 {
+			c = a.ID - b.ID
+		}
+		if sortDir == "asc" {
+			return c < 0
+		}
+		return c > 0
+	})
+	return out
+}
+
+func testCRUD(t *testing.T, s *suite) {
+	ctx := context.Background()
+
+	created := s.create(t, models.Post{Title: "Hello conformance " + token(t), Content: "First body"})
+	if created.ID <= 0 {
+		t.Fatalf("created post has ID %d", created.ID)
+	}
+	if created.Slug != models.Slugify(created.Title) {
+		t.Errorf("slug is %q, want %q", created.Slug, models.Slugify(created.Title))
+	}
+	if created.Title == "" || created.Content != "First body" || created.Author != s.author {
+		t.Errorf("created post is %+v", created)
+	}
+	if created.CreatedAt.IsZero() || created.UpdatedAt.Before(created.CreatedAt) {
+		t.Errorf("created post has timestamps %v/%v", created.CreatedAt, created.UpdatedAt)
+	}
+	checkPost(t, s.get(t, created.ID), created)
+
+	bySlug, err := s.store.GetBySlug(ctx, created.Slug)
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkPost(t, bySlug, created)
+
+	// Empty fields leave the stored values alone
+	updated := s.update(t, created.ID, models.Post{Content: "Second body"})
+	if updated.Title != created.Title || updated.Author != created.Author || updated.Slug != created.Slug {
+		t.Errorf("partial update changed other fields: %+v", updated)
+	}
+	if updated.Content != "Second body" {
+		t.Errorf("content is %q after update", updated.Content)
+	}
+	if !updated.CreatedAt.Equal(created.CreatedAt) || updated.UpdatedAt.Before(created.UpdatedAt) {
+		t.Errorf("update moved timestamps from %v/%v to %v/%v",
+			created.CreatedAt, created.UpdatedAt, updated.CreatedAt, updated.UpdatedAt)
+	}
+	checkPost(t, s.get(t, created.ID), updated)
+
+	all, err := s.store.GetAll(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	found := false
+	for _, post := range all {
+		found = found || post.ID == created.ID
+	}
+	if !found {
+		t.Errorf("GetAll does not include post %d", created.ID)
+	}
+
+	if err := s.store.Delete(ctx, created.ID); err != nil {
+		t.Fatal(err)
+	}
+	if post := s.get(t, created.ID); post != nil {
+		t.Errorf("deleted post is still returned: %+v", *post)
+	}
+	if post, err := s.store.GetBySlug(ctx, created.Slug); err != nil || post != nil {
+		t.Errorf("GetBySlug after delete = %v, %v; want nil, nil", post, err)
+	}
+	if posts := s.listAll(t, s.query("created_at", "desc", 10)); len(posts) != 0 {
+		t.Errorf("deleted post is still listed: %v", ids(posts))
+	}
+	if err := s.store.Delete(ctx, created.ID); err != nil {
+		t.Errorf("deleting twice: %v", err)
+	}
+}
+
+func testNotFound(t *testing.T, s *suite) {
+	ctx := context.Background()
+	missing := math.MaxInt32
+
+	if post := s.get(t, missing); post != nil {
+		t.Errorf("GetByID of a missing post = %+v", *post)
+	}
+	if post, err := s.store.GetBySlug(ctx, "no-such-post-"+token(t)); err != nil || post != nil {
+		t.Errorf("GetBySlug of a missing slug = %v, %v; want nil, nil", post, err)
+	}
+	if post, err := s.store.Update(ctx, missing, models.Post{Title: "Nope"}); err != nil || post != nil {
+		t.Errorf("Update of a missing post = %v, %v; want nil, nil", post, err)
+	}
+	if err := s.store.Delete(ctx, missing); err != nil {
+		t.Errorf("Delete of a missing post: %v", err)
+	}
+
+	page := s.page(t, s.query("created_at", "desc", 10))
+	if len(page.Posts) != 0 || page.HasMore || page.NextCursor != "" {
+		t.Errorf("listing an author with no posts = %+v", *page)
+	}
+}
+
+// testRemove covers the optional storage.PostRemover.
+func testRemove(t *testing.T, s *suite) {
+	remover, ok := s.store.(storage.PostRemover)
+	if !ok {
+		t.Skip("store does not implement PostRemover")
+	}
+	ctx := context.Background()
+	created := s.create(t, models.Post{Title: "Removed"})
+
+	removed, err := remover.Remove(ctx, created.ID)
+	if errors.Is(err, storage.ErrUnsupported) {
+		// Decorators implement it whatever the store they wrap
+		t.Skip("wrapped store does not implement PostRemover")
+	}
+	if err != nil || !removed {
+		t.Fatalf("Remove = %v, %v; want true, nil", removed, err)
+	}
+	if post := s.get(t, created.ID); post != nil {
+		t.Errorf("GetByID after Remove = %+v", *post)
+	}
+	if removed, err := remover.Remove(ctx, created.ID); err != nil || removed {
+		t.Errorf("removing twice = %v, %v; want false, nil", removed, err)
+	}
+}
+
+// testAuthorBatch covers the optional storage.AuthorBatchReader: its counts
+// and pages must match what CountPosts and GetPostsPaginated say per author.
+func testAuthorBatch(t *testing.T, s *suite) {
+	reader, ok := s.store.(storage.AuthorBatchReader)
+	if !ok {
+		t.Skip("store does not implement AuthorBatchReader")
+	}
+	ctx := context.Background()
+	other := &suite{store: s.store, author: s.author + " other"}
+	empty := s.author + " empty"
+	for i := 0; i < 3; i++ {
+		s.create(t, models.Post{Title: fmt.Sprintf("Mine %d", i)})
+	}
+	other.create(t, models.Post{Title: "Theirs"})
+	authors := []string{s.author, other.author, empty}
+
+	counts, err := reader.CountByAuthor(ctx, authors)
+	if errors.Is(err, storage.ErrUnsupported) {
+		t.Skip("wrapped store does not implement AuthorBatchReader")
+	}
+	if err != nil {
+		t.Fatalf("CountByAuthor: %v", err)
+	}
+	if counts[s.author] != 3 || counts[other.author] != 1 || counts[empty] != 0 {
+		t.Errorf("CountByAuthor = %v, want 3, 1 and 0", counts)
+	}
+
+	for _, order := range sorts {
+		query := s.query(order.by, order.dir, 2)
+		pages, err := reader.GetAuthorPages(ctx, authors, query)
+		if err != nil {
+			t.Fatalf("GetAuthorPages %s %s: %v", order.by, order.dir, err)
+		}
+		for _, author := range []string{s.author, other.author} {
+			query.Author = author
+			want := s.page(t, query)
+			got := pages[author]
+			if got == nil || !sameIDs(ids(got.Posts), ids(want.Posts)) || got.HasMore != want.HasMore || got.NextCursor != want.NextCursor {
+				t.Errorf("GetAuthorPages %s %s for %q = %+v, want %+v", order.by, order.dir, author, got, *want)
+			}
+		}
+		if page := pages[empty]; page != nil && len(page.Posts) != 0 {
+			t.Errorf("GetAuthorPages lists %v for an author with no posts", ids(page.Posts))
+		}
+	}
+}
+
+func testSlugs(t *testing.T, s *suite) {
+	ctx := context.Background()
+	title := "Same title " + token(t)
+	base := models.Slugify(title)
+
+	first := s.create(t, models.Post{Title: title})
+	second := s.create(t, models.Post{Title: title})
+	if first.Slug != base || second.Slug != base+"-2" {
+		t.Errorf("slugs for a repeated title are %q and %q, want %q and %q",
+			first.Slug, second.Slug, base, base+"-2")
+	}
+
+	if _, err := s.store.Create(ctx, models.Post{Title: "Other", Content: "x", Author: s.author, Slug: first.Slug}); !errors.Is(err, storage.ErrSlugTaken) {
+		t.Errorf("creating with a taken slug: got %v, want ErrSlugTaken", err)
+	}
+	if _, err := s.store.Update(ctx, second.ID, models.Post{Slug: first.Slug}); !errors.Is(err, storage.ErrSlugTaken) {
+		t.Errorf("updating to a taken slug: got %v, want ErrSlugTaken", err)
+	}
+
+	// The old slug keeps pointing at the post and stays reserved for it
+	renamed := base + "-renamed"
+	moved := s.update(t, first.ID, models.Post{Slug: renamed})
+	if moved.Slug != renamed {
+		t.Fatalf("slug is %q after update, want %q", moved.Slug, renamed)
+	}
+	post, err := s.store.GetBySlug(ctx, base)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if post == nil || post.ID != first.ID || post.Slug != renamed {
+		t.Errorf("old slug resolves to %+v, want post %d with slug %q", post, first.ID, renamed)
+	}
+	if _, err := s.store.Update(ctx, second.ID, models.Post{Slug: base}); !errors.Is(err, storage.ErrSlugTaken) {
+		t.Errorf("taking another post's old slug: got %v, want ErrSlugTaken", err)
+	}
+
+	if back := s.update(t, first.ID, models.Post{Slug: base}); back.Slug != base {
+		t.Errorf("moving back to its old slug gave %q", back.Slug)
+	}
+	post, err = s.store.GetBySlug(ctx, renamed)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if post == nil || post.ID != first.ID || post.Slug != base {
+		t.Errorf("slug %q resolves to %+v after moving back", renamed, post)
+	}
+}
+
+func testSortOrders(t *testing.T, s *suite) {
+	// Mixed case and accents: titles sort by code point, whatever the
+	// database locale
+	titles := []string{"banana", "Apple", "cherry", "apple", "Émile", "Zebra", "apple"}
+	var posts []models.Post
+	for _, title := range titles {
+		posts = append(posts, s.create(t, models.Post{Title: title, Slug: models.Slugify(title) + "-" + token(t)}))
+	}
+
+	// Updating the first posts again moves them to the front by updated_at.
+	// The pauses keep their timestamps apart on fast stores.
+	time.Sleep(2 * time.Millisecond)
+	posts[1] = s.update(t, posts[1].ID, models.Post{Content: "Edited"})
+	time.Sleep(2 * time.Millisecond)
+	posts[0] = s.update(t, posts[0].ID, models.Post{Content: "Edited again"})
+
+	for _, order := range sorts {
+		t.Run(order.by+"_"+order.dir, func(t *testing.T) {
+			want := ids(ordered(posts, order.by, order.dir))
+			for _, limit := range []int{1, 2, 3, 100} {
+				got := ids(s.listAll(t, s.query(order.by, order.dir, limit)))
+				if !sameIDs(got, want) {
+					t.Errorf("limit %d lists %v, want %v", limit, got, want)
+				}
+			}
+		})
+	}
+
+	byTitle := s.listAll(t, s.query("title", "asc", 100))
+	var got []string
+	for _, post := range byTitle {
+		got = append(got, post.Title)
+	}
+	want := []string{"Apple", "Zebra", "apple", "apple", "banana", "cherry", "Émile"}
+	if strings.Join(got, ",") != strings.Join(want, ",") {
+		t.Errorf("titles sort as %q, want %q", got, want)
+	}
+
+	byUpdate := s.listAll(t, s.query("updated_at", "desc", 100))
+	if len(byUpdate) < 2 || byUpdate[0].ID != posts[0].ID || byUpdate[1].ID != posts[1].ID {
+		t.Errorf("most recently updated posts do not come first: %v", ids(byUpdate))
+	}
+}
+
+func testAuthorFilter(t *testing.T, s *suite) {
+	other := &suite{store: s.store, author: s.author + " other"}
+	var mine []models.Post
+	for i := 0; i < 3; i++ {
+		mine = append(mine, s.create(t, models.Post{Title: fmt.Sprintf("Mine %d", i)}))
+		other.create(t, models.Post{Title: fmt.Sprintf("Theirs %d", i)})
+	}
+
+	got := ids(s.listAll(t, s.query("created_at", "asc", 2)))
+	if want := ids(mine); !sameIDs(got, want) {
+		t.Errorf("author filter lists %v, want %v", got, want)
+	}
+	if n := len(other.listAll(t, other.query("created_at", "asc", 2))); n != 3 {
+		t.Errorf("other author has %d posts listed, want 3", n)
+	}
+
+	// Authors match exactly
+	prefix := &suite{store: s.store, author: s.author[:len(s.author)-1]}
+	if n := len(prefix.listAll(t, prefix.query("created_at", "asc", 10))); n != 0 {
+		t.Errorf("author prefix matches %d posts", n)
+	}
+}
+
+func testSearch(t *testing.T, s *suite) {
+	word := "zq" + token(t)
+	inTitle := s.create(t, models.Post{Title: "About " + strings.ToUpper(word)})
+	inContent := s.create(t, models.Post{Title: "Plain", Content: "Mentions " + word + " once"})
+	s.create(t, models.Post{Title: "Unrelated", Content: "Nothing to see"})
+	percent := s.create(t, models.Post{Title: "Growth", Content: "Up 100% this year"})
+	s.create(t, models.Post{Title: "Visitors", Content: "1000 readers"})
+	underscore := s.create(t, models.Post{Title: "Names", Content: "snake_case names"})
+	s.create(t, models.Post{Title: "More names", Content: "snakeXcase names"})
+
+	tests := []struct {
+		search string
+		want   []models.Post
+	}{
+		// Case-insensitive, in title or content
+		{word, []models.Post{inTitle, inContent}},
+		{strings.ToUpper(word[:5]), []models.Post{inTitle, inContent}},
+		// Terms match literally, not as patterns
+		{"100%", []models.Post{percent}},
+		{"e_c", []models.Post{underscore}},
+		{"no such words", nil},
+	}
+	for _, tt := range tests {
+		query := s.query("created_at", "asc", 1)
+		query.Search = tt.search
+		got := ids(s.listAll(t, query))
+		if want := ids(tt.want); !sameIDs(got, want) {
+			t.Errorf("search %q lists %v, want %v", tt.search, got, want)
+		}
+	}
+}
+
+func testCursorRoundTrip(t *testing.T, s *suite) {
+	for i := 0; i < 5; i++ {
+		s.create(t, models.Post{Title: fmt.Sprintf("Cursor %d", i)})
+	}
+
+	for _, order := range sorts {
+		t.Run(order.by+"_"+order.dir, func(t *testing.T) {
+			query := s.query(order.by, order.dir, 2)
+			first := s.page(t, query)
+			if !first.HasMore {
+				t.Fatal("first of three pages has no more")
+			}
+
+			cursor, err := models.DecodeCursor(first.NextCursor)
+			if err != nil {
+				t.Fatalf("next_cursor %q does not decode: %v", first.NextCursor, err)
+			}
+			last := first.Posts[len(first.Posts)-1]
+			if cursor.ID != last.ID || cursor.SortBy != order.by {
+				t.Errorf("cursor is %+v, want ID %d sorted by %s", *cursor, last.ID, order.by)
+			}
+			want := models.NewCursor(last, order.by)
+			if value, ok := cursor.Value().(time.Time); ok {
+				if !value.Equal(want.Value().(time.Time)) {
+					t.Errorf("cursor holds %v, want %v", value, want.Value())
+				}
+			} else if cursor.Value() != want.Value() {
+				t.Errorf("cursor holds %v, want %v", cursor.Value(), want.Value())
+			}
+			if cursor.Encode() != first.NextCursor {
+				t.Errorf("cursor re-encodes as %q, want %q", cursor.Encode(), first.NextCursor)
+			}
+
+			// The same cursor gives the same next page
+			query.Cursor = first.NextCursor
+			second := s.page(t, query)
+			again := s.page(t, query)
+			if !sameIDs(ids(second.Posts), ids(again.Posts)) || second.NextCursor != again.NextCursor {
+				t.Errorf("cursor is not stable: %v then %v", ids(second.Posts), ids(again.Posts))
+			}
+			for _, post := range second.Posts {
+				for _, seen := range first.Posts {
+					if post.ID == seen.ID {
+						t.Errorf("post %d is on both pages", post.ID)
+					}
+				}
+			}
+
+			// Cursors belong to the sort they were issued for
+			for _, other := range sorts {
+				if other.by == order.by {
+					continue
+				}
+				q := s.query(other.by, other.dir, 2)
+				q.Cursor = first.NextCursor
+				if err := q.Validate(); err == nil {
+					t.Errorf("%s cursor accepted for sort_by=%s", order.by, other.by)
+				}
+			}
+		})
+	}
+}
+
+func testLastPage(t *testing.T, s *suite) {
+	for i := 0; i < 4; i++ {
+		s.create(t, models.Post{Title: fmt.Sprintf("Page %d", i)})
+	}
+
+	// Exactly full pages: the last one must not promise more
+	query := s.query("created_at", "desc", 2)
+	first := s.page(t, query)
+	if len(first.Posts) != 2 || !first.HasMore {
+		t.Fatalf("first page has %d posts, has_more %v", len(first.Posts), first.HasMore)
+	}
+	query.Cursor = first.NextCursor
+	second := s.page(t, query)
+	if len(second.Posts) != 2 || second.HasMore {
+		t.Errorf("last full page has %d posts, has_more %v", len(second.Posts), second.HasMore)
+	}
+
+	whole := s.page(t, s.query("created_at", "desc", 4))
+	if len(whole.Posts) != 4 || whole.HasMore {
+		t.Errorf("single full page has %d posts, has_more %v", len(whole.Posts), whole.HasMore)
+	}
+}
+
+// testConcurrentInserts pages through posts while new ones are written:
+// every post that existed beforehand must be listed exactly once.
+func testConcurrentInserts(t *testing.T, s *suite) {
+	var existing []models.Post
+	for i := 0; i < 12; i++ {
+		existing = append(existing, s.create(t, models.Post{Title: fmt.Sprintf("Before %02d", i)}))
+	}
+
+	for _, order := range sorts {
+		t.Run(order.by+"_"+order.dir, func(t *testing.T) {
+			stop := make(chan struct{})
+			var wg sync.WaitGroup
+			var insertErr error
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				for i := 0; i < 30; i++ {
+					select {
+					case <-stop:
+						return
+					default:
+					}
+					_, err := s.store.Create(context.Background(), models.Post{
+						Title:   fmt.Sprintf("During %s %d", order.by, i),
+						Content: "Inserted while paging",
+						Author:  s.author,
+					})
+					if err != nil {
+						insertErr = err
+						return
+					}
+				}
+			}()
+
+			seen := make(map[int]int)
+			query := s.query(order.by, order.dir, 3)
+			for pages := 0; pages < 1000; pages++ {
+				page := s.page(t, query)
+				for _, post := range page.Posts {
+					seen[post.ID]++
+				}
+				if !page.HasMore {
+					break
+				}
+				query.Cursor = page.NextCursor
+			}
+			close(stop)
+			wg.Wait()
+			if insertErr != nil {
+				t.Fatal(insertErr)
+			}
+
+			for id, n := range seen {
+				if n > 1 {
+					t.Errorf("post %d listed %d times", id, n)
+				}
+			}
+			for _, post := range existing {
+				if seen[post.ID] != 1 {
+					t.Errorf("post %d (%q) listed %d times, want once", post.ID, post.Title, seen[post.ID])
+				}
+			}
+		})
+	}
+}
@@ -1,13 +1,198 @@
 package storage
 
-import "blog-api/models"
+import (
+	"blog-api/models"
+	"context"
+	"errors"
+	"time"
+)
 
 type PostStore interface {
-	GetPostsPaginated(query models.PostQuery) (*models.PaginatedPosts, error)
-	GetAll() ([]models.Post, error)
-	GetByID(id int) (*models.Post, error)
-	Create(post models.Post) (*models.Post, error)
-	Update(id int, post models.Post) (*models.Post, error)
-	Delete(id int) error
+	GetPostsPaginated(ctx context.Context, query models.PostQuery) (*models.PaginatedPosts, error)
+	GetAll(ctx context.Context) ([]models.Post, error)
+	GetByID(ctx context.Context, id int) (*models.Post, error)
+	// GetBySlug also resolves old slugs; the returned post then has a
+	// different (current) Slug than the one asked for.
+	GetBySlug(ctx context.Context, slug string) (*models.Post, error)
+	Create(ctx context.Context, post models.Post) (*models.Post, error)
+	Update(ctx context.Context, id int, post models.Post) (*models.Post, error)
+	Delete(ctx context.Context, id int) error
 	Close() error
-}
\ No newline at end of file
+}
+
+// HealthChecker is implemented by stores backed by a database that can be
+// probed for readiness.
+type HealthChecker interface {
+	Ping(ctx context.Context) error
+	MigrationVersion(ctx context.Context) (int, error)
+}
+
+// PostImporter is implemented by stores that can insert posts in bulk.
+type PostImporter interface {
+	BeginImport(ctx context.Context) (ImportTx, error)
+}
+
+// ImportTx is a bulk insert transaction. Nothing inserted through it is
+// visible until Commit. A failed Insert inserts none of its posts and
+// leaves the transaction usable, so the caller can retry them one by one.
+type ImportTx interface {
+	Insert(posts []models.Post) ([]models.Post, error)
+	Commit() error
+	Rollback() error
+}
+
+// ErrUnsupported is returned when the underlying store lacks an optional
+// capability.
+var ErrUnsupported = errors.New("operation not supported by this store")
+
+// beginImport starts an import on next if it supports one.
+func beginImport(ctx context.Context, next PostStore) (ImportTx, error) {
+	importer, ok := next.(PostImporter)
+	if !ok {
+		return nil, ErrUnsupported
+	}
+	return importer.BeginImport(ctx)
+}
+
+// PostExporter is implemented by stores that can stream every post matching
+// a query without loading them all into memory. Cursor and Limit are
+// ignored; fn is called once per post in query order.
+type PostExporter interface {
+	ExportPosts(ctx context.Context, query models.PostQuery, fn func(models.Post) error) error
+}
+
+// exportPosts streams from next if it supports exports.
+func exportPosts(ctx context.Context, next PostStore, query models.PostQuery, fn func(models.Post) error) error {
+	exporter, ok := next.(PostExporter)
+	if !ok {
+		return ErrUnsupported
+	}
+	return exporter.ExportPosts(ctx, query, fn)
+}
+
+// PostRangeReader is implemented by stores that can stream posts by id
+// range, so callers can split every post into fixed pages and seek straight
+// to one instead of skipping the rows before it.
+type PostRangeReader interface {
+	// MaxPostID returns the largest id of a post, or 0 if there are none.
+	MaxPostID(ctx context.Context) (int, error)
+	// ExportRange calls fn for every post with after < ID <= through, in
+	// id order.
+	ExportRange(ctx context.Context, after, through int, fn func(models.Post) error) error
+}
+
+// rangeReaderOf returns next's range reads if it has them.
+func rangeReaderOf(next PostStore) (PostRangeReader, error) {
+	reader, ok := next.(PostRangeReader)
+	if !ok {
+		return nil, ErrUnsupported
+	}
+	return reader, nil
+}
+
+// PostCounter is implemented by stores that can count matching posts without
+// fetching them. Cursor and Limit are ignored.
+type PostCounter interface {
+	CountPosts(ctx context.Context, query models.PostQuery) (int, error)
+}
+
+// countPosts counts through next if it supports counting.
+func countPosts(ctx context.Context, next PostStore, query models.PostQuery) (int, error) {
+	counter, ok := next.(PostCounter)
+	if !ok {
+		return 0, ErrUnsupported
+	}
+	return counter.CountPosts(ctx, query)
+}
+
+// PostChangeFeed is implemented by stores that can list changes to posts,
+// including deletions, since a position in the feed.
+type PostChangeFeed interface {
+	PostChanges(ctx context.Context, since models.ChangeToken, limit int) (*models.ChangeSet, error)
+}
+
+// postChanges reads the change feed of next if it has one.
+func postChanges(ctx context.Context, next PostStore, since models.ChangeToken, limit int) (*models.ChangeSet, error) {
+	feed, ok := next.(PostChangeFeed)
+	if !ok {
+		return nil, ErrUnsupported
+	}
+	return feed.PostChanges(ctx, since, limit)
+}
+
+// PostTrash is implemented by stores where Delete moves posts to a trash
+// from which they can be restored or purged for good.
+type PostTrash interface {
+	// ListTrash returns trashed posts, most recently deleted first.
+	ListTrash(ctx context.Context, limit int) ([]models.Post, error)
+	// Restore takes a post out of the trash. It returns nil if the post is
+	// not in the trash.
+	Restore(ctx context.Context, id int) (*models.Post, error)
+	// Purge permanently deletes a trashed post and reports whether there
+	// was one.
+	Purge(ctx context.Context, id int) (bool, error)
+	// LastDeleted returns when a post still in the trash was last moved
+	// there, or the zero time if the trash is empty.
+	LastDeleted(ctx context.Context) (time.Time, error)
+}
+
+// trashOf returns next's trash if it has one.
+func trashOf(next PostStore) (PostTrash, error) {
+	trash, ok := next.(PostTrash)
+	if !ok {
+		return nil, ErrUnsupported
+	}
+	return trash, nil
+}
+
+// PostBatchReader is implemented by stores that can fetch several posts by
+// id in one round trip. Missing ids are left out of the result.
+type PostBatchReader interface {
+	GetByIDs(ctx context.Context, ids []int) ([]models.Post, error)
+}
+
+// getByIDs fetches through next if it supports batch reads.
+func getByIDs(ctx context.Context, next PostStore, ids []int) ([]models.Post, error) {
+	reader, ok := next.(PostBatchReader)
+	if !ok {
+		return nil, ErrUnsupported
+	}
+	return reader.GetByIDs(ctx, ids)
+}
+
+// AuthorBatchReader is implemented by stores that can answer per-author
+// questions for several authors in one round trip, as the GraphQL author
+// fields need.
+type AuthorBatchReader interface {
+	// CountByAuthor counts each author's posts. Authors without posts are
+	// left out.
+	CountByAuthor(ctx context.Context, authors []string) (map[string]int, error)
+	// GetAuthorPages returns, for each author with posts, the page query
+	// selects from that author's posts. query.Author is ignored.
+	GetAuthorPages(ctx context.Context, authors []string, query models.PostQuery) (map[string]*models.PaginatedPosts, error)
+}
+
+// authorReaderOf returns next's batch author reads if it has them.
+func authorReaderOf(next PostStore) (AuthorBatchReader, error) {
+	reader, ok := next.(AuthorBatchReader)
+	if !ok {
+		return nil, ErrUnsupported
+	}
+	return reader, nil
+}
+
+// PostRemover is implemented by stores whose Delete can report whether
+// there was a post to delete.
+type PostRemover interface {
+	// Remove is Delete that reports whether the post existed.
+	Remove(ctx context.Context, id int) (bool, error)
+}
+
+// removePost removes through next if it can report the outcome.
+func removePost(ctx context.Context, next PostStore, id int) (bool, error) {
+	remover, ok := next.(PostRemover)
+	if !ok {
+		return false, ErrUnsupported
+	}
+	return remover.Remove(ctx, id)
+}
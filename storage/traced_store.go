@@ -0,0 +1,294 @@
+package storage
+
+import (
+	"blog-api/models"
+	"context"
+	"time"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const tracerName = "blog-api/storage"
+
+// TracedStore wraps a PostStore and records a child span for every call,
+// tagged with the SQL statement type the call issues.
+type TracedStore struct {
+	next   PostStore
+	tracer trace.Tracer
+}
+
+func NewTracedStore(next PostStore) *TracedStore {
+	return &TracedStore{
+		next:   next,
+		tracer: otel.Tracer(tracerName),
+	}
+}
+
+func (s *TracedStore) start(ctx context.Context, method, statement string, attrs ...attribute.KeyValue) (context.Context, trace.Span) {
+	attrs = append(attrs,
+		attribute.String("db.system", "postgresql"),
+		attribute.String("db.operation", statement),
+		attribute.String("db.sql.table", "posts"),
+	)
+	return s.tracer.Start(ctx, "PostStore."+method,
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(attrs...),
+	)
+}
+
+func finish(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
+
+func (s *TracedStore) GetPostsPaginated(ctx context.Context, query models.PostQuery) (*models.PaginatedPosts, error) {
+	ctx, span := s.start(ctx, "GetPostsPaginated", "SELECT",
+		attribute.Int("posts.limit", query.Limit),
+		attribute.String("posts.sort_by", query.SortBy),
+		attribute.Bool("posts.has_cursor", query.Cursor != ""),
+		attribute.Bool("posts.has_search", query.Search != ""),
+	)
+	result, err := s.next.GetPostsPaginated(ctx, query)
+	if result != nil {
+		span.SetAttributes(attribute.Int("posts.returned", len(result.Posts)))
+	}
+	finish(span, err)
+	return result, err
+}
+
+func (s *TracedStore) GetAll(ctx context.Context) ([]models.Post, error) {
+	ctx, span := s.start(ctx, "GetAll", "SELECT")
+	posts, err := s.next.GetAll(ctx)
+	span.SetAttributes(attribute.Int("posts.returned", len(posts)))
+	finish(span, err)
+	return posts, err
+}
+
+func (s *TracedStore) GetByID(ctx context.Context, id int) (*models.Post, error) {
+	ctx, span := s.start(ctx, "GetByID", "SELECT", attribute.Int("post.id", id))
+	post, err := s.next.GetByID(ctx, id)
+	finish(span, err)
+	return post, err
+}
+
+func (s *TracedStore) GetByIDs(ctx context.Context, ids []int) ([]models.Post, error) {
+	ctx, span := s.start(ctx, "GetByIDs", "SELECT", attribute.Int("posts.requested", len(ids)))
+	posts, err := getByIDs(ctx, s.next, ids)
+	span.SetAttributes(attribute.Int("posts.returned", len(posts)))
+	finish(span, err)
+	return posts, err
+}
+
+func (s *TracedStore) GetBySlug(ctx context.Context, slug string) (*models.Post, error) {
+	ctx, span := s.start(ctx, "GetBySlug", "SELECT", attribute.String("post.slug", slug))
+	post, err := s.next.GetBySlug(ctx, slug)
+	finish(span, err)
+	return post, err
+}
+
+func (s *TracedStore) Create(ctx context.Context, post models.Post) (*models.Post, error) {
+	ctx, span := s.start(ctx, "Create", "INSERT")
+	created, err := s.next.Create(ctx, post)
+	if created != nil {
+		span.SetAttributes(attribute.Int("post.id", created.ID))
+	}
+	finish(span, err)
+	return created, err
+}
+
+func (s *TracedStore) Update(ctx context.Context, id int, post models.Post) (*models.Post, error) {
+	ctx, span := s.start(ctx, "Update", "UPDATE", attribute.Int("post.id", id))
+	updated, err := s.next.Update(ctx, id, post)
+	finish(span, err)
+	return updated, err
+}
+
+// Delete and Remove move the post to the trash, which is an UPDATE; only
+// Purge deletes rows.
+func (s *TracedStore) Delete(ctx context.Context, id int) error {
+	ctx, span := s.start(ctx, "Delete", "UPDATE", attribute.Int("post.id", id))
+	err := s.next.Delete(ctx, id)
+	finish(span, err)
+	return err
+}
+
+func (s *TracedStore) Remove(ctx context.Context, id int) (bool, error) {
+	ctx, span := s.start(ctx, "Remove", "UPDATE", attribute.Int("post.id", id))
+	removed, err := removePost(ctx, s.next, id)
+	finish(span, err)
+	return removed, err
+}
+
+func (s *TracedStore) Close() error {
+	return s.next.Close()
+}
+
+func (s *TracedStore) BeginImport(ctx context.Context) (ImportTx, error) {
+	tx, err := beginImport(ctx, s.next)
+	if err != nil {
+		return nil, err
+	}
+	return &tracedImportTx{ImportTx: tx, ctx: ctx, store: s}, nil
+}
+
+type tracedImportTx struct {
+	ImportTx
+	ctx   context.Context
+	store *TracedStore
+}
+
+func (t *tracedImportTx) Insert(posts []models.Post) ([]models.Post, error) {
+	_, span := t.store.start(t.ctx, "Import", "INSERT", attribute.Int("posts.batch_size", len(posts)))
+	inserted, err := t.ImportTx.Insert(posts)
+	finish(span, err)
+	return inserted, err
+}
+
+func (s *TracedStore) ExportPosts(ctx context.Context, query models.PostQuery, fn func(models.Post) error) error {
+	ctx, span := s.start(ctx, "ExportPosts", "SELECT",
+		attribute.Bool("posts.has_search", query.Search != ""),
+	)
+	n := 0
+	err := exportPosts(ctx, s.next, query, func(post models.Post) error {
+		n++
+		return fn(post)
+	})
+	span.SetAttributes(attribute.Int("posts.returned", n))
+	finish(span, err)
+	return err
+}
+
+func (s *TracedStore) MaxPostID(ctx context.Context) (int, error) {
+	ctx, span := s.start(ctx, "MaxPostID", "SELECT")
+	reader, err := rangeReaderOf(s.next)
+	if err != nil {
+		finish(span, err)
+		return 0, err
+	}
+	id, err := reader.MaxPostID(ctx)
+	finish(span, err)
+	return id, err
+}
+
+func (s *TracedStore) ExportRange(ctx context.Context, after, through int, fn func(models.Post) error) error {
+	ctx, span := s.start(ctx, "ExportRange", "SELECT",
+		attribute.Int("posts.after_id", after),
+		attribute.Int("posts.through_id", through),
+	)
+	reader, err := rangeReaderOf(s.next)
+	if err != nil {
+		finish(span, err)
+		return err
+	}
+	n := 0
+	err = reader.ExportRange(ctx, after, through, func(post models.Post) error {
+		n++
+		return fn(post)
+	})
+	span.SetAttributes(attribute.Int("posts.returned", n))
+	finish(span, err)
+	return err
+}
+
+func (s *TracedStore) CountPosts(ctx context.Context, query models.PostQuery) (int, error) {
+	ctx, span := s.start(ctx, "CountPosts", "SELECT",
+		attribute.Bool("posts.has_search", query.Search != ""),
+	)
+	n, err := countPosts(ctx, s.next, query)
+	finish(span, err)
+	return n, err
+}
+
+func (s *TracedStore) CountByAuthor(ctx context.Context, authors []string) (map[string]int, error) {
+	ctx, span := s.start(ctx, "CountByAuthor", "SELECT", attribute.Int("authors.requested", len(authors)))
+	reader, err := authorReaderOf(s.next)
+	if err != nil {
+		finish(span, err)
+		return nil, err
+	}
+	counts, err := reader.CountByAuthor(ctx, authors)
+	finish(span, err)
+	return counts, err
+}
+
+func (s *TracedStore) GetAuthorPages(ctx context.Context, authors []string, query models.PostQuery) (map[string]*models.PaginatedPosts, error) {
+	ctx, span := s.start(ctx, "GetAuthorPages", "SELECT",
+		attribute.Int("authors.requested", len(authors)),
+		attribute.Int("posts.limit", query.Limit),
+	)
+	reader, err := authorReaderOf(s.next)
+	if err != nil {
+		finish(span, err)
+		return nil, err
+	}
+	pages, err := reader.GetAuthorPages(ctx, authors, query)
+	finish(span, err)
+	return pages, err
+}
+
+func (s *TracedStore) PostChanges(ctx context.Context, since models.ChangeToken, limit int) (*models.ChangeSet, error) {
+	ctx, span := s.start(ctx, "PostChanges", "SELECT",
+		attribute.Int("posts.limit", limit),
+	)
+	set, err := postChanges(ctx, s.next, since, limit)
+	if set != nil {
+		span.SetAttributes(attribute.Int("posts.returned", len(set.Changes)))
+	}
+	finish(span, err)
+	return set, err
+}
+
+func (s *TracedStore) ListTrash(ctx context.Context, limit int) ([]models.Post, error) {
+	ctx, span := s.start(ctx, "ListTrash", "SELECT", attribute.Int("posts.limit", limit))
+	trash, err := trashOf(s.next)
+	if err != nil {
+		finish(span, err)
+		return nil, err
+	}
+	posts, err := trash.ListTrash(ctx, limit)
+	span.SetAttributes(attribute.Int("posts.returned", len(posts)))
+	finish(span, err)
+	return posts, err
+}
+
+func (s *TracedStore) Restore(ctx context.Context, id int) (*models.Post, error) {
+	ctx, span := s.start(ctx, "Restore", "UPDATE", attribute.Int("post.id", id))
+	trash, err := trashOf(s.next)
+	if err != nil {
+		finish(span, err)
+		return nil, err
+	}
+	post, err := trash.Restore(ctx, id)
+	finish(span, err)
+	return post, err
+}
+
+func (s *TracedStore) Purge(ctx context.Context, id int) (bool, error) {
+	ctx, span := s.start(ctx, "Purge", "DELETE", attribute.Int("post.id", id))
+	trash, err := trashOf(s.next)
+	if err != nil {
+		finish(span, err)
+		return false, err
+	}
+	purged, err := trash.Purge(ctx, id)
+	finish(span, err)
+	return purged, err
+}
+
+func (s *TracedStore) LastDeleted(ctx context.Context) (time.Time, error) {
+	ctx, span := s.start(ctx, "LastDeleted", "SELECT")
+	trash, err := trashOf(s.next)
+	if err != nil {
+		finish(span, err)
+		return time.Time{}, err
+	}
+	last, err := trash.LastDeleted(ctx)
+	finish(span, err)
+	return last, err
+}
@@ -0,0 +1,41 @@
+package storage
+
+import (
+	"blog-api/models"
+	"context"
+	"time"
+)
+
+// WebhookStore persists webhook subscriptions and their delivery log.
+type WebhookStore interface {
+	CreateSubscription(ctx context.Context, sub models.WebhookSubscription) (*models.WebhookSubscription, error)
+	ListSubscriptions(ctx context.Context) ([]models.WebhookSubscription, error)
+	GetSubscription(ctx context.Context, id int) (*models.WebhookSubscription, error)
+	UpdateSubscription(ctx context.Context, id int, req models.UpdateWebhookRequest) (*models.WebhookSubscription, error)
+	// DeleteSubscription reports whether there was a subscription to delete.
+	DeleteSubscription(ctx context.Context, id int) (bool, error)
+
+	// EnqueueDeliveries queues payload for every active subscription to
+	// the event type and returns how many deliveries were queued. Calling
+	// it again with the same eventID queues nothing new.
+	EnqueueDeliveries(ctx context.Context, eventID, eventType string, payload []byte) (int, error)
+
+	// ClaimDeliveries leases up to limit due deliveries to the caller. A
+	// claimed delivery is not handed out again until lease has passed,
+	// so a crashed sender's work is picked up by another instance.
+	ClaimDeliveries(ctx context.Context, limit int, lease time.Duration) ([]models.WebhookDelivery, error)
+	RecordAttempt(ctx context.Context, id int64, result DeliveryResult) error
+
+	ListDeliveries(ctx context.Context, subscriptionID, limit int) ([]models.WebhookDelivery, error)
+	// Redeliver queues a copy of an earlier delivery.
+	Redeliver(ctx context.Context, subscriptionID int, deliveryID int64) (*models.WebhookDelivery, error)
+}
+
+// DeliveryResult is the outcome of one attempt to send a delivery. A failed
+// attempt with a zero RetryAt is final.
+type DeliveryResult struct {
+	Succeeded  bool
+	StatusCode int
+	Error      string
+	RetryAt    time.Time
+}
@@ -0,0 +1,33 @@
+package stream
+
+import (
+	"blog-api/models"
+	"blog-api/storage"
+	"context"
+	"log"
+)
+
+// OutboxReader loads an event that was too large for its notification.
+type OutboxReader interface {
+	OutboxEvent(ctx context.Context, id int64) (*models.PostEvent, error)
+}
+
+// PublishNotice publishes the event from a post_events notification. The
+// event carries the ID and post snapshot written with the change, so it is
+// the same event webhooks and other sinks receive. Every instance receives
+// the notifications, so its subscribers see changes made through any
+// instance.
+func (h *Hub) PublishNotice(ctx context.Context, outbox OutboxReader, notice storage.PostEventNotice) {
+	event := notice.PostEvent
+	if notice.OutboxID != 0 {
+		loaded, err := outbox.OutboxEvent(ctx, notice.OutboxID)
+		if err != nil || loaded == nil {
+			// Subscribers would silently miss it; have them reload instead
+			log.Printf("Stream: failed to load event %d from the outbox: %v", notice.OutboxID, err)
+			h.Reset()
+			return
+		}
+		event = *loaded
+	}
+	h.Publish(event)
+}
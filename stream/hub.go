@@ -0,0 +1,181 @@
+// Package stream fans post events out to long-lived subscribers such as
+// Server-Sent Events connections.
+package stream
+
+import (
+	"blog-api/models"
+	"crypto/rand"
+	"encoding/hex"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Event is a post event numbered in the order this process saw it.
+// StreamID, "<epoch>.<seq>", is what clients resume from; the epoch is
+// random per hub, so IDs from another instance or from before a restart
+// are recognised as such.
+type Event struct {
+	Seq      uint64
+	StreamID string
+	models.PostEvent
+}
+
+// subscriberBuffer is how many events may queue for a slow subscriber
+// before it is disconnected.
+const subscriberBuffer = 64
+
+// Hub keeps the most recent events in a ring buffer for resuming clients and
+// broadcasts new ones to every subscriber.
+type Hub struct {
+	mu     sync.Mutex
+	epoch  string
+	ring   []Event
+	next   int    // ring index the next event is written to
+	seq    uint64 // sequence number of the newest event
+	subs   map[*Subscription]struct{}
+	closed bool
+}
+
+// NewHub creates a hub that can replay the last bufferSize events.
+func NewHub(bufferSize int) *Hub {
+	return &Hub{
+		epoch: newEpoch(),
+		ring:  make([]Event, 0, bufferSize),
+		subs:  make(map[*Subscription]struct{}),
+	}
+}
+
+func newEpoch() string {
+	b := make([]byte, 6)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// Subscription receives events until it is closed, the hub shuts down or it
+// falls too far behind.
+type Subscription struct {
+	hub    *Hub
+	events chan Event
+	once   sync.Once
+}
+
+// Events is closed when the subscription ends.
+func (s *Subscription) Events() <-chan Event {
+	return s.events
+}
+
+func (s *Subscription) Close() {
+	s.hub.mu.Lock()
+	defer s.hub.mu.Unlock()
+	s.hub.drop(s)
+}
+
+// drop must be called with hub.mu held.
+func (h *Hub) drop(s *Subscription) {
+	delete(h.subs, s)
+	s.once.Do(func() { close(s.events) })
+}
+
+// Publish numbers event, stores it for replay and sends it to subscribers.
+// Subscribers whose queue is full are disconnected rather than blocking the
+// publisher; they can resume with their last event ID.
+func (h *Hub) Publish(event models.PostEvent) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.closed {
+		return
+	}
+
+	h.seq++
+	e := Event{Seq: h.seq, StreamID: h.epoch + "." + strconv.FormatUint(h.seq, 10), PostEvent: event}
+	if len(h.ring) < cap(h.ring) {
+		h.ring = append(h.ring, e)
+	} else if cap(h.ring) > 0 {
+		h.ring[h.next] = e
+		h.next = (h.next + 1) % cap(h.ring)
+	}
+
+	for s := range h.subs {
+		select {
+		case s.events <- e:
+		default:
+			h.drop(s)
+		}
+	}
+}
+
+// Subscribe starts a subscription. With a lastID, the events after it still
+// in the buffer are returned for replay; complete is false when events
+// after it may be missing, because they were evicted or lastID is from
+// another instance or epoch, and the caller should tell the client to
+// reload.
+func (h *Hub) Subscribe(lastID string) (sub *Subscription, replay []Event, complete bool) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	sub = &Subscription{hub: h, events: make(chan Event, subscriberBuffer)}
+	if h.closed {
+		close(sub.events)
+		return sub, nil, true
+	}
+	h.subs[sub] = struct{}{}
+
+	if lastID == "" {
+		return sub, nil, true
+	}
+	lastSeq, ok := h.parseID(lastID)
+	if !ok {
+		return sub, nil, false
+	}
+
+	// Oldest to newest
+	ordered := append(append([]Event(nil), h.ring[h.next:]...), h.ring[:h.next]...)
+	complete = lastSeq == h.seq || (len(ordered) > 0 && ordered[0].Seq <= lastSeq+1)
+	for _, e := range ordered {
+		if e.Seq > lastSeq {
+			replay = append(replay, e)
+		}
+	}
+	return sub, replay, complete
+}
+
+// parseID returns the sequence number of a stream ID from this hub's
+// current epoch that it has issued.
+func (h *Hub) parseID(id string) (uint64, bool) {
+	epoch, seq, ok := strings.Cut(id, ".")
+	if !ok || epoch != h.epoch {
+		return 0, false
+	}
+	n, err := strconv.ParseUint(seq, 10, 64)
+	if err != nil || n > h.seq {
+		return 0, false
+	}
+	return n, true
+}
+
+// Reset starts a new epoch and ends every subscription. Call it when
+// events may have been missed, e.g. while the notification connection was
+// down: clients reconnect, find their ID no longer resumable and reload.
+func (h *Hub) Reset() {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.epoch = newEpoch()
+	h.ring = h.ring[:0]
+	h.next = 0
+	h.seq = 0
+	for s := range h.subs {
+		h.drop(s)
+	}
+}
+
+// Close ends every subscription and rejects new ones. Call it when the
+// server shuts down so streaming handlers return.
+func (h *Hub) Close() {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.closed = true
+	for s := range h.subs {
+		h.drop(s)
+	}
+}
@@ -0,0 +1,244 @@
+// Package synth generates realistic-looking posts for load and performance
+// testing: a skewed set of authors, Markdown bodies of varying length and
+// creation times spread over a configurable span.
+package synth
+
+import (
+	"blog-api/models"
+	crand "crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"math"
+	"math/rand/v2"
+	"strings"
+	"time"
+)
+
+// Options shapes the generated posts. Zero values take the defaults noted.
+type Options struct {
+	// Authors is how many distinct authors write the posts (1000).
+	Authors int
+	// AuthorSkew above 1 makes a few authors write most posts, following
+	// a Zipf distribution with this exponent; 1 or less is uniform.
+	AuthorSkew float64
+
+	// Content lengths in words are spread log-uniformly between these
+	// (50 and 2000), so short posts are more common than long ones.
+	MinWords int
+	MaxWords int
+
+	// Posts are created at random times in the Spread (one year) before
+	// End (now). About a third are updated again later.
+	Spread time.Duration
+	End    time.Time
+
+	// Seed makes the output repeatable (random). Slugs also carry a nonce
+	// drawn for every Generator, so runs with the same seed do not collide.
+	Seed uint64
+}
+
+func (o *Options) setDefaults() {
+	if o.Authors <= 0 {
+		o.Authors = 1000
+	}
+	if o.MinWords <= 0 {
+		o.MinWords = 50
+	}
+	if o.MaxWords < o.MinWords {
+		o.MaxWords = max(2000, o.MinWords)
+	}
+	if o.Spread <= 0 {
+		o.Spread = 365 * 24 * time.Hour
+	}
+	if o.End.IsZero() {
+		o.End = time.Now()
+	}
+	if o.Seed == 0 {
+		o.Seed = rand.Uint64()
+	}
+}
+
+// Generator produces posts. It is not safe for concurrent use.
+type Generator struct {
+	opts Options
+	rng  *rand.Rand
+	zipf *rand.Zipf
+	run  string // random per Generator, keeps runs' slugs apart
+	n    int
+}
+
+func New(opts Options) *Generator {
+	opts.setDefaults()
+	rng := rand.New(rand.NewPCG(opts.Seed, opts.Seed>>32|1))
+
+	g := &Generator{
+		opts: opts,
+		rng:  rng,
+		run:  newRun(),
+	}
+	if opts.AuthorSkew > 1 && opts.Authors > 1 {
+		g.zipf = rand.NewZipf(rng, opts.AuthorSkew, 1, uint64(opts.Authors-1))
+	}
+	return g
+}
+
+// newRun returns a random nonce for slugs, independent of the seed.
+func newRun() string {
+	b := make([]byte, 4)
+	crand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// Options returns the options in use, with defaults filled in.
+func (g *Generator) Options() Options {
+	return g.opts
+}
+
+// Next returns a new post with its slug and timestamps set. Slugs are
+// unique within a run and, short of a nonce collision, across runs.
+func (g *Generator) Next() models.Post {
+	g.n++
+
+	topic := pick(g.rng, topics)
+	title := fmt.Sprintf(pick(g.rng, titlePatterns), topic)
+	created := g.opts.End.Add(-time.Duration(g.rng.Int64N(int64(g.opts.Spread))))
+	updated := created
+	if g.rng.IntN(3) == 0 {
+		updated = created.Add(time.Duration(g.rng.Int64N(int64(g.opts.End.Sub(created)) + 1)))
+	}
+
+	return models.Post{
+		Title:     title,
+		Content:   g.content(title, topic),
+		Author:    g.Author(),
+		Slug:      fmt.Sprintf("%s-%s-%d", models.Slugify(title), g.run, g.n),
+		CreatedAt: created,
+		UpdatedAt: updated,
+	}
+}
+
+// Author picks an author following the configured distribution.
+func (g *Generator) Author() string {
+	if g.zipf != nil {
+		return AuthorName(int(g.zipf.Uint64()))
+	}
+	return AuthorName(g.rng.IntN(g.opts.Authors))
+}
+
+// Word returns a random word from the vocabulary posts are written in,
+// e.g. as a search term that matches some of them.
+func (g *Generator) Word() string {
+	return pick(g.rng, words)
+}
+
+// AuthorName is the name of the i-th author. Names are unique for the first
+// len(firstNames)*len(lastNames) authors and numbered after that.
+func AuthorName(i int) string {
+	first := firstNames[i%len(firstNames)]
+	last := lastNames[(i/len(firstNames))%len(lastNames)]
+	if round := i / (len(firstNames) * len(lastNames)); round > 0 {
+		return fmt.Sprintf("%s %s %d", first, last, round+1)
+	}
+	return first + " " + last
+}
+
+// content writes a Markdown body with a log-uniform number of words.
+func (g *Generator) content(title, topic string) string {
+	lo, hi := math.Log(float64(g.opts.MinWords)), math.Log(float64(g.opts.MaxWords))
+	total := int(math.Exp(lo + g.rng.Float64()*(hi-lo)))
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "# %s\n\n", title)
+	written := 0
+	heading := true
+	for written < total {
+		block := g.rng.IntN(8)
+		if block == 0 && heading {
+			block = 3
+		}
+		heading = block == 0
+
+		switch block {
+		case 0:
+			fmt.Fprintf(&b, "## More on %s\n\n", strings.ToLower(topic))
+		case 1:
+			for i := 0; i < 3 && written < total; i++ {
+				b.WriteString("- ")
+				written += g.sentence(&b, 4+g.rng.IntN(6))
+				b.WriteString("\n")
+			}
+			b.WriteString("\n")
+		case 2:
+			fmt.Fprintf(&b, "```go\nfunc %s() error {\n\treturn nil\n}\n```\n\n", pick(g.rng, words))
+			written += 4
+		default:
+			n := min(30+g.rng.IntN(90), total-written)
+			for n > 0 {
+				k := min(6+g.rng.IntN(14), n)
+				g.sentence(&b, k)
+				b.WriteString(" ")
+				n -= k
+				written += k
+			}
+			b.WriteString("\n\n")
+		}
+	}
+	return strings.TrimSpace(b.String()) + "\n"
+}
+
+// sentence writes n words as a sentence and returns n.
+func (g *Generator) sentence(b *strings.Builder, n int) int {
+	for i := 0; i < n; i++ {
+		w := pick(g.rng, words)
+		if i == 0 {
+			w = strings.ToUpper(w[:1]) + w[1:]
+		} else {
+			b.WriteString(" ")
+		}
+		if g.rng.IntN(25) == 0 {
+			w = "**" + w + "**"
+		}
+		b.WriteString(w)
+	}
+	b.WriteString(".")
+	return n
+}
+
+func pick(rng *rand.Rand, list []string) string {
+	return list[rng.IntN(len(list))]
+}
+
+var (
+	firstNames = []string{
+		"Ada", "Grace", "Alan", "Barbara", "Ken", "Dennis", "Margaret", "Linus", "Radia", "Edsger",
+		"Frances", "John", "Katherine", "Donald", "Leslie", "Hedy", "Niklaus", "Sophie", "Guido", "Rob",
+	}
+	lastNames = []string{
+		"Lovelace", "Hopper", "Turing", "Liskov", "Thompson", "Ritchie", "Hamilton", "Torvalds", "Perlman", "Dijkstra",
+		"Allen", "McCarthy", "Johnson", "Knuth", "Lamport", "Lamarr", "Wirth", "Wilson", "Rossum", "Pike",
+		"Kernighan", "Stroustrup", "Backus", "Hoare", "Cerf", "Kahn", "Berners-Lee", "Goldberg", "Kay", "Engelbart",
+		"Shannon", "Neumann", "Babbage", "Hollerith", "Codd", "Gray", "Stonebraker", "Bachman", "Chen", "Boyce",
+		"Diffie", "Hellman", "Rivest", "Shamir", "Adleman", "Merkle", "Lampson", "Sutherland", "Floyd", "Tarjan",
+	}
+	topics = []string{
+		"Go", "PostgreSQL", "HTTP caching", "Markdown", "webhooks", "pagination", "testing", "tracing",
+		"indexes", "connection pools", "replication", "rate limiting", "GraphQL", "OpenAPI", "Kubernetes",
+		"observability", "feature flags", "code review", "on-call", "data modelling",
+	}
+	titlePatterns = []string{
+		"Getting started with %s", "%s in production", "What I learned about %s", "%s tips and tricks",
+		"A closer look at %s", "Why we moved to %s", "%s, revisited", "Debugging %s at scale",
+		"The case against %s", "Ten years of %s",
+	}
+	words = []string{
+		"system", "query", "latency", "index", "request", "server", "client", "cache", "table", "row",
+		"page", "cursor", "author", "post", "deploy", "release", "metric", "trace", "span", "error",
+		"retry", "timeout", "buffer", "stream", "event", "queue", "worker", "schema", "migration", "backup",
+		"replica", "primary", "lock", "transaction", "commit", "rollback", "plan", "scan", "join", "filter",
+		"sort", "limit", "offset", "token", "header", "payload", "signature", "secret", "config", "flag",
+		"simple", "fast", "slow", "careful", "robust", "fragile", "subtle", "obvious", "hidden", "shared",
+		"build", "measure", "learn", "debug", "profile", "refactor", "rewrite", "ship", "observe", "tune",
+		"we", "they", "it", "this", "that", "every", "some", "most", "few", "many",
+		"because", "although", "when", "while", "after", "before", "until", "unless", "so", "then",
+	}
+)
@@ -0,0 +1,221 @@
+package webhooks
+
+import (
+	"blog-api/models"
+	"blog-api/storage"
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// EventPing is sent by Ping to check a subscription's receiver.
+const EventPing = "ping"
+
+// Options tunes delivery. Zero values use the defaults below.
+type Options struct {
+	PollInterval time.Duration // how often due deliveries are claimed
+	Timeout      time.Duration // per-request timeout
+	MaxAttempts  int           // attempts before a delivery is marked failed
+	BatchSize    int           // deliveries claimed and sent concurrently
+
+	// AllowPrivateTargets lets subscriptions point at loopback and private
+	// addresses, e.g. a receiver on localhost during development.
+	AllowPrivateTargets bool
+}
+
+const (
+	defaultPollInterval = 2 * time.Second
+	defaultTimeout      = 10 * time.Second
+	defaultMaxAttempts  = 8
+	defaultBatchSize    = 20
+)
+
+// retryBackoff spaces attempts from ~30s up to an hour apart.
+var retryBackoff = storage.Backoff{Initial: 30 * time.Second, Max: time.Hour}
+
+// Dispatcher queues post events for matching subscriptions and sends them,
+// retrying failures with exponential backoff. Deliveries live in the
+// database, so pending retries survive restarts.
+type Dispatcher struct {
+	store  storage.WebhookStore
+	client *http.Client
+	opts   Options
+}
+
+func NewDispatcher(store storage.WebhookStore, opts Options) *Dispatcher {
+	if opts.PollInterval <= 0 {
+		opts.PollInterval = defaultPollInterval
+	}
+	if opts.Timeout <= 0 {
+		opts.Timeout = defaultTimeout
+	}
+	if opts.MaxAttempts <= 0 {
+		opts.MaxAttempts = defaultMaxAttempts
+	}
+	if opts.BatchSize <= 0 {
+		opts.BatchSize = defaultBatchSize
+	}
+
+	return &Dispatcher{
+		store: store,
+		client: &http.Client{
+			Timeout:   opts.Timeout,
+			Transport: newTransport(opts),
+			// Redirects could carry the signed payload somewhere unexpected
+			CheckRedirect: func(*http.Request, []*http.Request) error { return http.ErrUseLastResponse },
+		},
+		opts: opts,
+	}
+}
+
+// NewSecret returns a random signing secret.
+func NewSecret() string {
+	b := make([]byte, 24)
+	rand.Read(b)
+	return "whsec_" + hex.EncodeToString(b)
+}
+
+// Publish queues event for every subscription to its type.
+func (d *Dispatcher) Publish(ctx context.Context, event models.PostEvent) error {
+	payload, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+	_, err = d.store.EnqueueDeliveries(ctx, event.ID, event.Type, payload)
+	return err
+}
+
+// Run sends due deliveries until ctx is cancelled.
+func (d *Dispatcher) Run(ctx context.Context) {
+	ticker := time.NewTicker(d.opts.PollInterval)
+	defer ticker.Stop()
+
+	for {
+		// Keep draining while there is a backlog
+		for d.sendBatch(ctx) == d.opts.BatchSize {
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// sendBatch claims and sends one batch, returning how many were claimed.
+func (d *Dispatcher) sendBatch(ctx context.Context) int {
+	if ctx.Err() != nil {
+		return 0
+	}
+
+	// The lease outlasts every attempt in the batch, so nothing is sent twice
+	// unless this instance dies mid-batch.
+	deliveries, err := d.store.ClaimDeliveries(ctx, d.opts.BatchSize, 2*d.opts.Timeout)
+	if err != nil {
+		if ctx.Err() == nil {
+			log.Printf("Webhook claim failed: %v", err)
+		}
+		return 0
+	}
+
+	var wg sync.WaitGroup
+	for _, delivery := range deliveries {
+		wg.Add(1)
+		go func(delivery models.WebhookDelivery) {
+			defer wg.Done()
+			result := d.attempt(ctx, delivery)
+			if err := d.store.RecordAttempt(context.WithoutCancel(ctx), delivery.ID, result); err != nil {
+				log.Printf("Failed to record webhook delivery %d: %v", delivery.ID, err)
+			}
+		}(delivery)
+	}
+	wg.Wait()
+	return len(deliveries)
+}
+
+func (d *Dispatcher) attempt(ctx context.Context, delivery models.WebhookDelivery) storage.DeliveryResult {
+	status, err := d.send(ctx, delivery.URL, delivery.Secret, delivery.EventID, delivery.Event,
+		strconv.FormatInt(delivery.ID, 10), delivery.Payload)
+	if err == nil {
+		return storage.DeliveryResult{Succeeded: true, StatusCode: status}
+	}
+
+	result := storage.DeliveryResult{StatusCode: status, Error: err.Error()}
+	if delivery.Attempts+1 < d.opts.MaxAttempts {
+		result.RetryAt = time.Now().Add(retryBackoff.Delay(delivery.Attempts))
+	}
+	return result
+}
+
+// send POSTs one signed payload. Any 2xx response counts as delivered.
+func (d *Dispatcher) send(ctx context.Context, url, secret, eventID, eventType, deliveryID string, payload []byte) (int, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(payload))
+	if err != nil {
+		return 0, err
+	}
+
+	ts := time.Now().Unix()
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "blog-api-webhooks/1.0")
+	req.Header.Set(HeaderEventID, eventID)
+	req.Header.Set(HeaderDelivery, deliveryID)
+	req.Header.Set(HeaderEvent, eventType)
+	req.Header.Set(HeaderTimestamp, strconv.FormatInt(ts, 10))
+	req.Header.Set(HeaderSignature, Sign(secret, ts, payload))
+
+	resp, err := d.client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+
+	// The body is never reported back: ping results and the delivery log
+	// would otherwise show whatever the receiver URL returns
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 4096))
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return resp.StatusCode, fmt.Errorf("receiver responded %d", resp.StatusCode)
+	}
+	return resp.StatusCode, nil
+}
+
+// PingResult reports a synchronous test delivery.
+type PingResult struct {
+	Delivered  bool   `json:"delivered"`
+	StatusCode int    `json:"status_code,omitempty"`
+	Error      string `json:"error,omitempty"`
+	DurationMS int64  `json:"duration_ms"`
+}
+
+// Ping sends a signed ping event to sub right away, bypassing the queue, so
+// a receiver can be checked while it is being set up.
+func (d *Dispatcher) Ping(ctx context.Context, sub models.WebhookSubscription) PingResult {
+	eventID := storage.NewEventID()
+	payload, _ := json.Marshal(map[string]interface{}{
+		"id":              eventID,
+		"type":            EventPing,
+		"occurred_at":     time.Now().UTC(),
+		"subscription_id": sub.ID,
+	})
+
+	start := time.Now()
+	status, err := d.send(ctx, sub.URL, sub.Secret, eventID, EventPing, "ping", payload)
+	result := PingResult{
+		Delivered:  err == nil,
+		StatusCode: status,
+		DurationMS: time.Since(start).Milliseconds(),
+	}
+	if err != nil {
+		result.Error = err.Error()
+	}
+	return result
+}
@@ -0,0 +1,60 @@
+package webhooks
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Headers sent with every delivery.
+const (
+	HeaderEventID   = "X-Webhook-Id"
+	HeaderDelivery  = "X-Webhook-Delivery"
+	HeaderEvent     = "X-Webhook-Event"
+	HeaderTimestamp = "X-Webhook-Timestamp"
+	HeaderSignature = "X-Webhook-Signature"
+)
+
+var (
+	ErrMissingSignature = errors.New("missing webhook signature headers")
+	ErrStaleTimestamp   = errors.New("webhook timestamp outside tolerance")
+	ErrBadSignature     = errors.New("webhook signature mismatch")
+)
+
+// Sign returns the X-Webhook-Signature value for body sent at timestamp:
+// "sha256=" followed by the hex HMAC-SHA256 of "<timestamp>.<body>" keyed
+// with the subscription secret. Covering the timestamp stops replays of old
+// deliveries.
+func Sign(secret string, timestamp int64, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(strconv.FormatInt(timestamp, 10)))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// Verify checks a received delivery's signature and that its timestamp is
+// within tolerance of now. Receivers written in Go can use it directly.
+func Verify(secret string, header http.Header, body []byte, tolerance time.Duration) error {
+	signature := header.Get(HeaderSignature)
+	ts, err := strconv.ParseInt(header.Get(HeaderTimestamp), 10, 64)
+	if signature == "" || err != nil {
+		return ErrMissingSignature
+	}
+
+	age := time.Since(time.Unix(ts, 0))
+	if age > tolerance || age < -tolerance {
+		return ErrStaleTimestamp
+	}
+
+	expected := Sign(secret, ts, body)
+	if !hmac.Equal([]byte(strings.TrimSpace(signature)), []byte(expected)) {
+		return ErrBadSignature
+	}
+	return nil
+}
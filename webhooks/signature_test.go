@@ -0,0 +1,56 @@
+package webhooks
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestSign(t *testing.T) {
+	// HMAC-SHA256 of "1700000000.{}" keyed with "secret"
+	want := "sha256=b8569b78799ff9e3cbff0fc2d63a33a2b57f3282abd07c37ae5e8e7d79a5f163"
+	if got := Sign("secret", 1700000000, []byte("{}")); got != want {
+		t.Errorf("Sign = %q, want %q", got, want)
+	}
+	if Sign("secret", 1700000001, []byte("{}")) == want {
+		t.Error("signature does not cover the timestamp")
+	}
+}
+
+func TestVerify(t *testing.T) {
+	body := []byte(`{"type":"post.created"}`)
+	now := time.Now().Unix()
+
+	signed := func(secret string, ts int64, body []byte) http.Header {
+		h := http.Header{}
+		h.Set(HeaderTimestamp, strconv.FormatInt(ts, 10))
+		h.Set(HeaderSignature, Sign(secret, ts, body))
+		return h
+	}
+
+	tests := []struct {
+		name   string
+		header http.Header
+		body   []byte
+		want   error
+	}{
+		{"valid", signed("secret", now, body), body, nil},
+		{"within tolerance", signed("secret", now-60, body), body, nil},
+		{"no headers", http.Header{}, body, ErrMissingSignature},
+		{"bad timestamp", http.Header{HeaderTimestamp: {"soon"}, HeaderSignature: {"sha256=00"}}, body, ErrMissingSignature},
+		{"too old", signed("secret", now-600, body), body, ErrStaleTimestamp},
+		{"from the future", signed("secret", now+600, body), body, ErrStaleTimestamp},
+		{"wrong secret", signed("other", now, body), body, ErrBadSignature},
+		{"tampered body", signed("secret", now, body), []byte(`{"type":"post.deleted"}`), ErrBadSignature},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := Verify("secret", tt.header, tt.body, 5*time.Minute)
+			if !errors.Is(err, tt.want) {
+				t.Errorf("Verify = %v, want %v", err, tt.want)
+			}
+		})
+	}
+}
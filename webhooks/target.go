@@ -0,0 +1,83 @@
+package webhooks
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/netip"
+	"net/url"
+	"syscall"
+	"time"
+)
+
+// ErrForbiddenTarget is returned for a receiver on a loopback, private,
+// link-local or otherwise internal address. Webhooks are configured
+// through the API, so without this check anyone who can create one could
+// make the server call its own network.
+var ErrForbiddenTarget = errors.New("webhook url must not point to a loopback, private or link-local address")
+
+// sharedAddressSpace is carrier-grade NAT (RFC 6598), which netip does not
+// count as private.
+var sharedAddressSpace = netip.MustParsePrefix("100.64.0.0/10")
+
+// publicAddr reports whether addr may receive webhook deliveries.
+func publicAddr(addr netip.Addr) bool {
+	addr = addr.Unmap()
+	return addr.IsValid() &&
+		!addr.IsUnspecified() &&
+		!addr.IsLoopback() &&
+		!addr.IsPrivate() &&
+		!addr.IsLinkLocalUnicast() &&
+		!addr.IsLinkLocalMulticast() &&
+		!addr.IsInterfaceLocalMulticast() &&
+		!addr.IsMulticast() &&
+		!sharedAddressSpace.Contains(addr)
+}
+
+// CheckURL resolves the host of a subscription URL and returns
+// ErrForbiddenTarget if any of its addresses is internal. Deliveries are
+// checked again when they connect, since DNS can change in between.
+func (d *Dispatcher) CheckURL(ctx context.Context, raw string) error {
+	if d.opts.AllowPrivateTargets {
+		return nil
+	}
+	u, err := url.Parse(raw)
+	if err != nil {
+		return err
+	}
+
+	addrs, err := net.DefaultResolver.LookupNetIP(ctx, "ip", u.Hostname())
+	if err != nil {
+		return fmt.Errorf("webhook url host %q could not be resolved", u.Hostname())
+	}
+	for _, addr := range addrs {
+		if !publicAddr(addr) {
+			return ErrForbiddenTarget
+		}
+	}
+	return nil
+}
+
+// newTransport returns the transport deliveries are sent with. Unless
+// private targets are allowed, it refuses to connect to internal addresses
+// whatever the host name resolved to, and it ignores proxy settings so
+// that check sees the real destination.
+func newTransport(opts Options) *http.Transport {
+	dialer := &net.Dialer{Timeout: opts.Timeout, KeepAlive: 30 * time.Second}
+	if !opts.AllowPrivateTargets {
+		dialer.Control = func(network, address string, _ syscall.RawConn) error {
+			addrPort, err := netip.ParseAddrPort(address)
+			if err != nil || !publicAddr(addrPort.Addr()) {
+				return ErrForbiddenTarget
+			}
+			return nil
+		}
+	}
+
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.Proxy = nil
+	transport.DialContext = dialer.DialContext
+	return transport
+}